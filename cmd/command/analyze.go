@@ -1,199 +1,188 @@
 package command
 
 import (
-	"encoding/json"
 	"fmt"
 	"os"
+	"time"
 
+	"github.com/common-fate/access-inspector/pkg/findings"
+	"github.com/common-fate/access-inspector/pkg/remediation"
+	"github.com/common-fate/access-inspector/pkg/report"
+	"github.com/common-fate/access-inspector/pkg/requests"
+	"github.com/common-fate/access-inspector/pkg/usage"
 	"github.com/common-fate/clio"
-	"github.com/common-fate/provider-registry-sdk-go/pkg/providerregistrysdk"
-	"github.com/jmoiron/sqlx"
 	"github.com/joho/godotenv"
 	_ "github.com/mattn/go-sqlite3"
-	"github.com/pkg/errors"
 	"github.com/urfave/cli/v2"
 )
 
-type groupAssignment struct {
-	AccountAssignmentID string `db:"id"`
-	Account             string `db:"account"`
-	AccountName         string `db:"account_name"`
-	PermissionSetARN    string `db:"permission_set_arn"`
-	PermissionSetName   string `db:"permission_set_name"`
-	GroupName           string `db:"group_name"`
-	UserEmail           string `db:"email"`
+// loadAccessRequests reads a file written by the dump-requests command, returning the
+// requests with active grants. Files with the full request history can be used too.
+func loadAccessRequests(requestsFile string) ([]requests.Entry, error) {
+	entries, err := requests.Load(requestsFile)
+	if err != nil {
+		return nil, err
+	}
+	return requests.Active(entries, time.Now()), nil
 }
 
-type userAssignment struct {
-	AccountAssignmentID string `db:"id"`
-	Account             string `db:"account"`
-	AccountName         string `db:"account_name"`
-	PermissionSetARN    string `db:"permission_set_arn"`
-	PermissionSetName   string `db:"permission_set_name"`
-	UserEmail           string `db:"email"`
-	UserID              string `db:"user_id"`
-}
-
-// accessViaCF is an account assignment which is created through Common Fate,
-// and shouldn't be removed
-type accessViaCF struct {
-	UserEmail        string
-	PermissionSetARN string
-	AccountID        string
-}
-
-// Key combines the fields into a single string, so that we can store the
-// fields in a map to be looked up against.
-func (a accessViaCF) Key() string {
-	return fmt.Sprintf("%s+%s+%s", a.UserEmail, a.AccountID, a.PermissionSetARN)
+// commonFateAccessMap returns a map of the entitlements granted by active access requests,
+// keyed by remediation.Key(). The values of the map are the Access Request IDs.
+func commonFateAccessMap(accessRequests []requests.Entry, mappers *requests.Registry) map[string]string {
+	commonFateAccessMap := map[string]string{}
+	providers := remediation.Supported()
+
+	for _, req := range accessRequests {
+		for _, g := range mappers.Granted(req) {
+			p, ok := providers[g.Kind]
+			if !ok {
+				clio.Debugw("skipping entitlement which can't be remediated", "request", req.Request.ID, "kind", g.Kind)
+				continue
+			}
+			commonFateAccessMap[remediation.Key(p, req.User.Email, g.Fields)] = req.Request.ID
+		}
+	}
+	return commonFateAccessMap
 }
 
 var Analyze = cli.Command{
 	Name: "analyze",
-	Flags: []cli.Flag{
+	Flags: append([]cli.Flag{
 		&cli.PathFlag{Name: "report", Required: true},
-		&cli.PathFlag{Name: "requests", Required: true},
+		&cli.PathFlag{Name: "requests", Usage: "active Access Requests written by the dump-requests command (defaults to the requests imported into the report)"},
+		targetMappingsFlag,
 		&cli.BoolFlag{Name: "no-dry-run", Usage: "actually remove entitlements"},
-	},
+		&cli.IntFlag{Name: "unused-days", Usage: "only remove assignments which haven't been used in this many days (requires the usage command to have been run)"},
+		&cli.PathFlag{Name: "restore-script", Usage: "also write a script which restores the removed account assignments, in case they need to be rolled back"},
+	}, riskFlags...),
 	Action: func(c *cli.Context) error {
 		_ = godotenv.Load()
 
-		db, err := sqlx.Open("sqlite3", c.Path("report"))
+		db, err := report.Open(c.Path("report"))
 		if err != nil {
 			return err
 		}
 
-		var groupAssignments []groupAssignment
-
-		clio.Infof("finding AWS SSO entitlements assigned to groups")
-
-		err = db.Select(&groupAssignments, `
-SELECT
-    accountassignment.id,
-    accountassignment.account,
-    account.name as account_name,
-    accountassignment.permission_set as permission_set_arn,
-    permissionset.name as permission_set_name,
-	"group".name as group_name,
-    user.email
-FROM accountassignment
-INNER JOIN account ON accountassignment.account = account.id
-INNER JOIN permissionset ON accountassignment.permission_set = permissionset.id
-INNER JOIN groupmembership ON accountassignment."group" = groupmembership."group"
-INNER JOIN user ON groupmembership."user" = user.id
-INNER JOIN "group" ON groupmembership."group" = "group".id
-		`)
+		sso, err := ssoScriptOptions(db)
 		if err != nil {
 			return err
 		}
 
-		clio.Debugw("group assignments", "assignments", groupAssignments)
-
-		var userAssignments []userAssignment
-
-		clio.Infof("finding AWS SSO entitlements assigned to users")
-
-		err = db.Select(&userAssignments, `
-SELECT
-    accountassignment.id,
-    accountassignment.account,
-    account.name as account_name,
-    accountassignment.permission_set as permission_set_arn,
-    permissionset.name as permission_set_name,
-    user.email,
-	user.id as user_id
-FROM accountassignment
-INNER JOIN account ON accountassignment.account = account.id
-INNER JOIN permissionset ON accountassignment.permission_set = permissionset.id
-INNER JOIN user ON accountassignment."user" = user.id
-		`)
+		scorer, err := loadScorer(c, db)
 		if err != nil {
 			return err
 		}
 
-		var describeStr string
+		var accessRequests []requests.Entry
+		if requestsFile := c.Path("requests"); requestsFile != "" {
+			clio.Infof("loading active Access Requests in Common Fate from %s", requestsFile)
 
-		err = db.QueryRow("SELECT describe from __common_fate_meta LIMIT 1").Scan(&describeStr)
-		if err != nil {
-			return errors.Wrap(err, "querying for provider describe data")
+			accessRequests, err = loadAccessRequests(requestsFile)
+			if err != nil {
+				return err
+			}
+		} else {
+			stored, err := requests.Stored(db)
+			if err != nil {
+				return err
+			}
+			if stored == nil {
+				return fmt.Errorf("the report doesn't contain Access Requests: pass --requests, or import them with the import-requests command")
+			}
+			clio.Infof("using the Access Requests imported into %s", c.Path("report"))
+			accessRequests = requests.Active(stored, time.Now())
 		}
 
-		var describe providerregistrysdk.DescribeResponse
-		err = json.Unmarshal([]byte(describeStr), &describe)
+		mappers, err := loadMappers(c)
 		if err != nil {
 			return err
 		}
 
-		clio.Debugw("user assignments", "assignments", userAssignments)
+		opts := findings.Options{
+			// a map of active access requests.
+			// These need to be ignored when deprovisioning access, as they are
+			// managed by Common Fate.
+			CommonFateAccess: commonFateAccessMap(accessRequests, mappers),
+			UnusedDays:       c.Int("unused-days"),
+		}
 
-		var accessRequests []accessRequestWithDetail
+		if opts.UnusedDays > 0 {
+			opts.Usage, err = usage.Load(db)
+			if err != nil {
+				return err
+			}
+			if opts.Usage == nil {
+				return fmt.Errorf("the report doesn't contain usage data: run the usage command before using --unused-days")
+			}
+		}
 
-		requestsFile := c.Path("requests")
-		clio.Infof("loading active Access Requests in Common Fate from %s", requestsFile)
+		clio.Infof("finding AWS SSO entitlements assigned to groups and users")
 
-		accessRequestsBytes, err := os.ReadFile(requestsFile)
-		if err != nil {
-			return err
-		}
-		err = json.Unmarshal(accessRequestsBytes, &accessRequests)
+		results, err := findings.Compute(db, scorer, opts)
 		if err != nil {
 			return err
 		}
 
-		// a map of active access requests.
-		// These need to be ignored when deprovisioning access, as they are
-		// managed by Common Fate.
-		commonFateAccessMap := map[string]string{}
+		clio.Debugw("findings", "findings", results)
 
-		for _, req := range accessRequests {
-			if req.Request.AccessRule.Target.Provider.Type != "aws-sso" {
+		var userFindings []findings.Finding
+		for _, f := range results {
+			if f.Status == findings.StatusGroup {
+				// Common Fate only manages individual user access, but log these for informational purposes
+				clio.Infof("SKIPPING: user %s has access to %s (%v) with role %s because of group %s (risk %s) - this tool only removes individual user account assignments", f.UserEmail, f.AccountName, f.AccountID, f.PermissionSetName, f.GroupName, f.Risk)
 				continue
 			}
-
-			ps := req.Request.Arguments.AdditionalProperties["permissionSetArn"].Value
-			accountID := req.Request.Arguments.AdditionalProperties["accountId"].Value
-
-			access := accessViaCF{
-				UserEmail:        req.User.Email,
-				PermissionSetARN: ps,
-				AccountID:        accountID,
-			}
-
-			commonFateAccessMap[access.Key()] = req.Request.ID
+			userFindings = append(userFindings, f)
 		}
 
-		for _, ga := range groupAssignments {
-			// Common Fate only manages individual user access, but log these for informational purposes
-			clio.Infof("SKIPPING: user %s has access to %s (%v) with role %s because of group %s - this tool only removes individual user account assignments", ga.UserEmail, ga.AccountName, ga.Account, ga.PermissionSetName, ga.GroupName)
+		// the config values of the scan are used to generate the bash script used to remove assignments
+		err = remediation.WriteHeader(os.Stdout, sso.InstanceARN, sso.Region)
+		if err != nil {
+			return err
 		}
 
-		fmt.Println("#!/bin/bash")
-
-		// look up the config values which will be used to generate the bash script used to remove assignments
-		instanceARN := describe.Config["sso_instance_arn"].(string)
-		ssoRegion := describe.Config["sso_region"].(string)
-
-		fmt.Printf("SSO_INSTANCE_ARN=%s\n", instanceARN)
-		fmt.Printf("SSO_REGION=%s\n\n", ssoRegion)
-
-		for i, ua := range userAssignments {
-			access := accessViaCF{
-				UserEmail:        ua.UserEmail,
-				PermissionSetARN: ua.PermissionSetARN,
-				AccountID:        ua.Account,
+		var removed []remediation.Entitlement
+		for i, f := range userFindings {
+			switch f.Status {
+			case findings.StatusCommonFate:
+				clio.Infof("SKIPPING: user %s has access to %s (%v) with role %s via Common Fate (Access Request %s) - this account assignment will not be removed", f.UserEmail, f.AccountName, f.AccountID, f.PermissionSetName, f.RequestID)
+				continue
+			case findings.StatusRecentlyUsed:
+				clio.Infof("SKIPPING: user %s has access to %s (%v) with role %s which they last used at %s, within the last %d days - this account assignment will not be removed", f.UserEmail, f.AccountName, f.AccountID, f.PermissionSetName, f.LastUsed.Format(time.RFC3339), opts.UnusedDays)
+				continue
 			}
 
-			key := access.Key()
+			// need to remove this account assignment
+			clio.Infof("WILL BE REMOVED: user %s has access to %s (%v) with role %s (risk %s)", f.UserEmail, f.AccountName, f.AccountID, f.PermissionSetName, f.Risk)
+			removal := remediation.Removal{
+				PrincipalType:     remediation.PrincipalUser,
+				PrincipalID:       f.UserID,
+				PrincipalName:     f.UserEmail,
+				AccountID:         f.AccountID,
+				AccountName:       f.AccountName,
+				PermissionSetARN:  f.PermissionSetARN,
+				PermissionSetName: f.PermissionSetName,
+				Comment:           fmt.Sprintf("risk %s", f.Risk),
+			}
+			err = remediation.WriteRemoval(os.Stdout, sso, removal, i+1, len(userFindings))
+			if err != nil {
+				return err
+			}
+			removed = append(removed, removal.Entitlement())
+		}
 
-			if requestID, ok := commonFateAccessMap[key]; ok {
-				clio.Infof("SKIPPING: user %s has access to %s (%v) with role %s via Common Fate (Access Request %s) - this account assignment will not be removed", ua.UserEmail, ua.AccountName, ua.Account, ua.PermissionSetName, requestID)
-				continue
+		if restorePath := c.Path("restore-script"); restorePath != "" {
+			f, err := os.OpenFile(restorePath, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, 0755)
+			if err != nil {
+				return err
 			}
+			defer f.Close()
 
-			// need to remove this account assignment
-			clio.Infof("WILL BE REMOVED: user %s has access to %s (%v) with role %s", ua.UserEmail, ua.AccountName, ua.Account, ua.PermissionSetName)
-			fmt.Printf("echo \"(%d/%d) removing user %s access to %s (%v) with role %s\"\n", i+1, len(userAssignments), ua.UserEmail, ua.AccountName, ua.Account, ua.PermissionSetName)
-			fmt.Printf("aws sso-admin delete-account-assignment --instance-arn $SSO_INSTANCE_ARN --region $SSO_REGION --target-type AWS_ACCOUNT --target-id %s --permission-set-arn %s --principal-type USER --principal-id %s\n\n", ua.Account, ua.PermissionSetARN, ua.UserID)
+			err = remediation.WriteEntitlementScript(c.Context, f, remediation.ScriptOptions{Action: remediation.ActionRestore, InstanceARN: sso.InstanceARN, Region: sso.Region, Instances: sso.Instances}, removed)
+			if err != nil {
+				return err
+			}
+			clio.Successf("wrote a script restoring %d account assignments to %s", len(removed), restorePath)
 		}
 
 		return nil
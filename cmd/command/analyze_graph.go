@@ -2,150 +2,106 @@ package command
 
 import (
 	"bytes"
-	"fmt"
 	"os"
 
-	"github.com/common-fate/access-inspector/pkg/loader"
+	"github.com/common-fate/access-inspector/pkg/accessgraph"
+	"github.com/common-fate/access-inspector/pkg/report"
 	"github.com/common-fate/clio"
-	"github.com/common-fate/provider-registry-sdk-go/pkg/handlerclient"
-	"github.com/common-fate/provider-registry-sdk-go/pkg/msg"
-	"github.com/dominikbraun/graph"
-	"github.com/dominikbraun/graph/draw"
-	"github.com/joho/godotenv"
 	_ "github.com/mattn/go-sqlite3"
 	"github.com/pkg/errors"
 	"github.com/urfave/cli/v2"
 )
 
-var Hash = func(n msg.Resource) string {
-	return n.Type + "/" + n.ID
-}
+// loadReportGraph builds the entitlement graph from the resources stored in a report.
+func loadReportGraph(reportPath string) (accessgraph.Graph, error) {
+	db, err := report.OpenReadOnly(reportPath)
+	if err != nil {
+		return nil, err
+	}
+	defer db.Close()
 
-type Report struct {
-	G graph.Graph[string, msg.Resource]
+	g, unresolved, err := accessgraph.FromReport(db)
+	if err != nil {
+		return nil, err
+	}
+	for _, u := range unresolved {
+		clio.Warnf("unresolved relation: %s", u)
+	}
+	return g, nil
 }
 
-func resourceLabel(r msg.Resource) string {
-	if r.Name == "" {
-		return r.Type + "/" + r.ID
+// writeOutput writes to a file, or to stdout if the path is '-'.
+func writeOutput(output string, b []byte) error {
+	if output == "-" {
+		_, err := os.Stdout.Write(b)
+		return err
 	}
-	return r.Type + "/" + r.Name
+	return os.WriteFile(output, b, 0644)
 }
 
 var AnalyzeGraph = cli.Command{
-	Name: "analyze",
+	Name:  "graph",
+	Usage: "Export the entitlement graph",
 	Flags: []cli.Flag{
-		&cli.StringFlag{Name: "report", Required: true},
+		&cli.PathFlag{Name: "report", Required: true},
+		&cli.PathFlag{Name: "output", Required: true, Usage: "the file to write the graph to, or '-' for stdout"},
+		&cli.StringFlag{Name: "format", Usage: "the graph format (dot, graphml, gexf, mermaid or json). Inferred from the output file extension if not set"},
+		&cli.StringSliceFlag{Name: "from", Usage: "only export resources connected to this resource, given as <type>/<ID, name or email> (can be repeated)"},
+		&cli.IntFlag{Name: "depth", Value: accessgraph.DefaultDepth, Usage: "the number of relations to follow from the --from resources (-1 for no limit)"},
+		&cli.StringSliceFlag{Name: "include-type", Usage: "only export resources of this type (can be repeated)"},
+		&cli.StringSliceFlag{Name: "exclude-type", Usage: "don't export resources of this type (can be repeated)"},
+		&cli.BoolFlag{Name: "collapse-groups", Usage: "replace group memberships and intermediate nested groups with a single edge from each member to its groups"},
 	},
 	Action: func(c *cli.Context) error {
-		ctx := c.Context
-		_ = godotenv.Load()
-
-		hc := handlerclient.Client{
-			Executor: handlerclient.Local{
-				Dir: c.String("report"),
-			},
+		output := c.Path("output")
+		format := accessgraph.FormatFromPath(output)
+		if f := c.String("format"); f != "" {
+			var err error
+			format, err = accessgraph.ParseFormat(f)
+			if err != nil {
+				return err
+			}
 		}
 
-		describe, err := hc.Describe(ctx)
+		g, err := loadReportGraph(c.Path("report"))
 		if err != nil {
 			return err
 		}
 
-		var tasks []string
-		for task := range describe.Schema.Resources.Loaders {
-			tasks = append(tasks, task)
+		filter := accessgraph.Filter{
+			Depth:          c.Int("depth"),
+			IncludeTypes:   c.StringSlice("include-type"),
+			ExcludeTypes:   c.StringSlice("exclude-type"),
+			CollapseGroups: c.Bool("collapse-groups"),
+		}
+		for _, from := range c.StringSlice("from") {
+			v, err := accessgraph.FindVertex(g, from)
+			if err != nil {
+				return errors.Wrap(err, "invalid --from")
+			}
+			filter.Start = append(filter.Start, v)
 		}
 
-		fetcher := loader.NewResourceFetcher(&hc)
-
-		clio.Infow("loading resources", "tasks", tasks)
-
-		resources, err := fetcher.LoadResources(ctx, tasks)
+		g, err = accessgraph.Subgraph(g, accessgraph.AWSSSO, filter)
 		if err != nil {
 			return err
 		}
 
-		report := Report{G: graph.New(Hash)}
-
-		var users []string
-
-		for _, r := range resources {
-			clio.Debugw("adding node", "resource", r)
-			label := resourceLabel(r)
-			err = report.G.AddVertex(r, graph.VertexAttribute("label", label))
-			// ok if edge already exists as it would have been inserted from a related field
-			if err == graph.ErrVertexAlreadyExists {
-				_, props, err := report.G.VertexWithProperties(Hash(r))
-				if err != nil {
-					return err
-				}
-				// ensure the label is set
-				props.Attributes["label"] = label
-			} else if err != nil {
-				return err
-			}
-
-			resourceSchema := describe.Schema.Resources.Types[r.Type]
-			resourceSchemaMap := resourceSchema.(map[string]any)
-			resourceProps := resourceSchemaMap["properties"].(map[string]any)
-
-			// if the type is User, add it to the list of users to analyse
-			if r.Type == "User" {
-				users = append(users, Hash(r))
-			}
-
-			for k, v := range r.Data {
-				if v == nil {
-					continue
-				}
-
-				resourcePropsData := resourceProps["data"].(map[string]any)
-				schema := resourcePropsData[k].(map[string]any)
-				relation, ok := schema["relation"]
-				if !ok {
-					continue
-				}
-
-				relationStr := relation.(string)
-
-				vStr, ok := v.(string)
-				if !ok {
-					return fmt.Errorf("could not cast field %s to string (%+v)", k, v)
-				}
-
-				// create an edge to the related field
-				to := msg.Resource{
-					Type: relationStr,
-					ID:   vStr,
-				}
-
-				err = report.G.AddVertex(to)
-				if err != nil && err != graph.ErrVertexAlreadyExists {
-					return errors.Wrap(err, "adding connected resource vertex")
-				}
+		var b bytes.Buffer
 
-				err = report.G.AddEdge(Hash(r), Hash(to))
-				if err != nil {
-					return err
-				}
-			}
+		err = accessgraph.Write(&b, g, format)
+		if err != nil {
+			return err
 		}
 
-		for _, u := range users {
-			clio.Infow("analysing access", "user", u)
-			graph.BFS(report.G, u, func(k string) bool {
-				return false
-			})
-
+		err = writeOutput(output, b.Bytes())
+		if err != nil {
+			return err
 		}
 
-		var b bytes.Buffer
-
-		draw.DOT(report.G, &b)
-
-		os.WriteFile("result", b.Bytes(), 0755)
-
+		if output != "-" {
+			clio.Successf("wrote %s graph to %s", format, output)
+		}
 		return nil
 	},
 }
@@ -0,0 +1,86 @@
+package command
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+
+	"github.com/common-fate/access-inspector/pkg/accessgraph"
+	"github.com/urfave/cli/v2"
+)
+
+var BlastRadius = cli.Command{
+	Name:  "blast-radius",
+	Usage: "List every user, account and permission set which gets access through a resource",
+	Flags: []cli.Flag{
+		&cli.PathFlag{Name: "report", Required: true},
+		&cli.StringFlag{Name: "resource", Required: true, Usage: "the resource, given as <type>/<ID, name or email>, for example Group/Admins"},
+		&cli.PathFlag{Name: "requests", Usage: "include active Access Requests written by the dump-requests command"},
+		targetMappingsFlag,
+		&cli.StringFlag{Name: "format", Value: "text", Usage: "the output format (text or json)"},
+	},
+	Action: func(c *cli.Context) error {
+		format := c.String("format")
+		if format != "text" && format != "json" {
+			return fmt.Errorf("unsupported format %q: must be text or json", format)
+		}
+
+		g, err := loadReportGraph(c.Path("report"))
+		if err != nil {
+			return err
+		}
+
+		model := accessgraph.AWSSSO
+
+		if requestsFile := c.Path("requests"); requestsFile != "" {
+			mappers, err := loadMappers(c)
+			if err != nil {
+				return err
+			}
+			err = addCommonFateGrants(g, model, requestsFile, mappers)
+			if err != nil {
+				return err
+			}
+		}
+
+		v, err := accessgraph.FindVertex(g, c.String("resource"))
+		if err != nil {
+			return err
+		}
+
+		br, err := accessgraph.ComputeBlastRadius(g, model, v)
+		if err != nil {
+			return err
+		}
+
+		if format == "json" {
+			enc := json.NewEncoder(os.Stdout)
+			enc.SetIndent("", "  ")
+			return enc.Encode(br)
+		}
+
+		fmt.Printf("%s is on %d paths granting access to %d users in %d accounts\n", br.Resource, len(br.Access), len(br.Users), len(br.Accounts))
+
+		sections := []struct {
+			title string
+			hops  []accessgraph.Hop
+		}{
+			{"Users", br.Users},
+			{"Accounts", br.Accounts},
+			{"Permission sets", br.PermissionSets},
+		}
+		for _, s := range sections {
+			fmt.Printf("\n%s (%d):\n", s.title, len(s.hops))
+			for _, h := range s.hops {
+				fmt.Printf("  %s\n", h)
+			}
+		}
+
+		fmt.Printf("\nBy account:\n")
+		for _, a := range br.ByAccount {
+			fmt.Printf("  %s: %d users, %d permission sets, %d paths\n", a.Account, a.Users, a.PermissionSets, a.Paths)
+		}
+
+		return nil
+	},
+}
@@ -1,123 +1,186 @@
 package command
 
 import (
-	"encoding/json"
+	"fmt"
+	"io"
 	"os"
-	"sync"
 	"time"
 
+	"github.com/common-fate/access-inspector/pkg/requests"
 	"github.com/common-fate/cli/pkg/client"
 	"github.com/common-fate/cli/pkg/config"
 	"github.com/common-fate/clio"
-	"github.com/common-fate/common-fate/pkg/types"
 	_ "github.com/mattn/go-sqlite3"
 	"github.com/urfave/cli/v2"
-	"golang.org/x/sync/errgroup"
 )
 
-type accessRequestWithDetail struct {
-	Request types.RequestDetail `json:"request"`
-	User    types.User          `json:"user"`
-}
-
 var DumpRequests = cli.Command{
 	Name: "dump-requests",
 	Flags: []cli.Flag{
-		&cli.PathFlag{Name: "output", Required: true},
+		&cli.PathFlag{Name: "output", Usage: "the file to write the Access Requests to, or - for stdout"},
+		&cli.StringFlag{Name: "format", Value: "json", Usage: "the output format (json or ndjson)"},
+		&cli.PathFlag{Name: "report", Usage: "import the Access Requests into tables in a report database"},
+		&cli.BoolFlag{Name: "history", Usage: "export every Access Request in any status, with its events, rather than only the requests with active grants"},
+		&cli.StringSliceFlag{Name: "provider-type", Usage: "only export requests for this provider type, such as aws-sso"},
+		&cli.StringSliceFlag{Name: "access-rule", Usage: "only export requests made through this access rule ID"},
+		&cli.StringSliceFlag{Name: "requestor", Usage: "only export requests made by this user ID or email address"},
+		&cli.StringFlag{Name: "grants-from", Usage: "only export requests with grants active after this date or time"},
+		&cli.StringFlag{Name: "grants-to", Usage: "only export requests with grants active before this date or time"},
+		&cli.PathFlag{Name: "cursor", Usage: "only export the requests changed since the last export with the same cursor file, appending them to --output. Every page of requests is still listed (requires --history and --format=ndjson)"},
+		&cli.IntFlag{Name: "concurrency", Value: 5, Usage: "the number of Access Requests to fetch at once"},
+		&cli.IntFlag{Name: "max-attempts", Value: requests.DefaultRetry.MaxAttempts, Usage: "the number of times to make API calls which are rate limited or fail with a server error"},
 	},
 	Action: func(c *cli.Context) error {
 		ctx := c.Context
-		cfg, err := config.Load()
-		if err != nil {
-			return err
-		}
 
-		cf, err := client.FromConfig(ctx, cfg)
+		output, reportPath, cursorPath := c.Path("output"), c.Path("report"), c.Path("cursor")
+		if output == "" && reportPath == "" {
+			return fmt.Errorf("at least one of --output or --report must be given")
+		}
+		if c.Int("concurrency") < 1 {
+			return fmt.Errorf("--concurrency must be at least 1")
+		}
+		format, err := requests.ParseFormat(c.String("format"))
 		if err != nil {
 			return err
 		}
 
-		var accessRequests []types.Request
-
-		var done bool
-		var nextToken *string
-		var i int
+		history := c.Bool("history")
 
-		approved := types.AdminListRequestsParamsStatus("APPROVED")
+		if cursorPath != "" {
+			// incremental exports only contain the changed requests, so they are appended to the previous ones
+			if !history || format != requests.NDJSON || output == "" || output == "-" {
+				return fmt.Errorf("--cursor requires --history, --format=ndjson and an --output file to append to")
+			}
+			if reportPath != "" {
+				return fmt.Errorf("--cursor can't be used with --report, as only the changed requests would be imported: import the --output file with the import-requests command instead")
+			}
+		}
 
-		for !done {
-			i++
-			clio.Infof("finding active Access Requests in Common Fate (page %d)", i)
+		filter := requests.Filter{
+			ProviderTypes: c.StringSlice("provider-type"),
+			AccessRuleIDs: c.StringSlice("access-rule"),
+			Requestors:    c.StringSlice("requestor"),
+		}
+		filter.GrantsFrom, err = parseTime("grants-from", c.String("grants-from"))
+		if err != nil {
+			return err
+		}
+		filter.GrantsTo, err = parseTime("grants-to", c.String("grants-to"))
+		if err != nil {
+			return err
+		}
 
-			res, err := cf.AdminListRequestsWithResponse(ctx, &types.AdminListRequestsParams{Status: &approved, NextToken: nextToken})
+		var cursor requests.Cursor
+		if cursorPath != "" {
+			cursor, err = requests.LoadCursor(cursorPath)
 			if err != nil {
 				return err
 			}
-
-			now := time.Now()
-
-			for _, req := range res.JSON200.Requests {
-				if req.Grant != nil &&
-					req.Grant.Status == types.GrantStatusACTIVE &&
-					req.Grant.Start.Before(now) && req.Grant.End.After(now) {
-					accessRequests = append(accessRequests, req)
-				}
+			if !cursor.UpdatedAt.IsZero() {
+				clio.Infof("exporting Access Requests updated after %s", cursor.UpdatedAt.Format(time.RFC3339))
 			}
-
-			if res.JSON200.Next == nil {
-				done = true
-			}
-
-			nextToken = res.JSON200.Next
 		}
 
-		var mu sync.Mutex
-		var accessRequestsWithDetail []accessRequestWithDetail
-		var g errgroup.Group
+		cfg, err := config.Load()
+		if err != nil {
+			return err
+		}
 
-		for _, req := range accessRequests {
-			reqID := req.ID
+		cf, err := client.FromConfig(ctx, cfg)
+		if err != nil {
+			return err
+		}
 
-			g.Go(func() error {
-				res, err := cf.AdminGetRequestWithResponse(ctx, reqID)
-				if err != nil {
-					return err
+		d := requests.Dumper{
+			API:         cf,
+			Concurrency: c.Int("concurrency"),
+			Retry:       requests.DefaultRetry,
+			History:     history,
+			Filter:      filter,
+			Since:       cursor.UpdatedAt,
+		}
+		d.Retry.MaxAttempts = c.Int("max-attempts")
+
+		var w *requests.Writer
+		if output != "" {
+			var out io.Writer = os.Stdout
+			if output != "-" {
+				flags := os.O_WRONLY | os.O_CREATE | os.O_TRUNC
+				if cursorPath != "" {
+					flags = os.O_WRONLY | os.O_CREATE | os.O_APPEND
 				}
-				user, err := cf.UserGetUserWithResponse(ctx, res.JSON200.Requestor)
+				f, err := os.OpenFile(output, flags, 0755)
 				if err != nil {
 					return err
 				}
-				mu.Lock()
-				defer mu.Unlock()
-				detail := accessRequestWithDetail{
-					Request: *res.JSON200,
-					User:    *user.JSON200,
-				}
-				accessRequestsWithDetail = append(accessRequestsWithDetail, detail)
-				return nil
-			})
+				defer f.Close()
+				out = f
+			}
+			w = requests.NewWriter(out, format)
 		}
 
-		err = g.Wait()
+		// requests are only held in memory when they are imported into a report
+		var imported []requests.Entry
+
+		err = d.Stream(ctx, func(e requests.Entry) error {
+			clio.Debugw("found Access Request", "request", e)
+			if reportPath != "" {
+				imported = append(imported, e)
+			}
+			if w != nil {
+				return w.Write(e)
+			}
+			return nil
+		})
 		if err != nil {
 			return err
 		}
 
-		clio.Debugw("found active Access Requests", "requests", accessRequestsWithDetail)
-
-		requestsBytes, err := json.Marshal(accessRequestsWithDetail)
-		if err != nil {
-			return err
+		if w != nil {
+			err = w.Close()
+			if err != nil {
+				return err
+			}
+			if output != "-" {
+				if history {
+					clio.Successf("wrote %d Access Requests to %s", w.Count(), output)
+				} else {
+					clio.Successf("wrote %d active Access Requests to %s", w.Count(), output)
+				}
+			}
 		}
 
-		output := c.String("output")
-		err = os.WriteFile(output, requestsBytes, 0755)
-		if err != nil {
-			return err
+		// the cursor is only moved on once the requests have been written, so a failed export is retried in full
+		if cursorPath != "" && d.Latest.After(cursor.UpdatedAt) {
+			err = requests.SaveCursor(cursorPath, requests.Cursor{UpdatedAt: d.Latest})
+			if err != nil {
+				return err
+			}
 		}
 
-		clio.Successf("wrote active Access Requests to %s", output)
+		if reportPath != "" {
+			err = importRequests(reportPath, imported)
+			if err != nil {
+				return err
+			}
+		}
 
 		return nil
 	},
 }
+
+// parseTime parses a flag given as an RFC3339 time or as a date.
+func parseTime(flag string, s string) (time.Time, error) {
+	if s == "" {
+		return time.Time{}, nil
+	}
+	if t, err := time.Parse(time.RFC3339, s); err == nil {
+		return t, nil
+	}
+	t, err := time.Parse("2006-01-02", s)
+	if err != nil {
+		return time.Time{}, fmt.Errorf("--%s must be a date such as 2023-01-02 or an RFC3339 time such as 2023-01-02T15:04:05Z: %s", flag, s)
+	}
+	return t, nil
+}
@@ -0,0 +1,84 @@
+package command
+
+import (
+	"bytes"
+	"errors"
+
+	"github.com/common-fate/access-inspector/pkg/accessgraph"
+	"github.com/common-fate/access-inspector/pkg/report"
+	"github.com/common-fate/clio"
+	"github.com/urfave/cli/v2"
+)
+
+var EffectiveAccess = cli.Command{
+	Name:  "effective-access",
+	Usage: "Work out every account and permission set each user can reach through the entitlement graph",
+	Flags: []cli.Flag{
+		&cli.PathFlag{Name: "report", Required: true},
+		&cli.BoolFlag{Name: "save", Usage: "save the effective access to the effective_access table in the report"},
+		&cli.PathFlag{Name: "matrix", Usage: "write a CSV matrix of users against accounts and permission sets to this file, or '-' for stdout"},
+		&cli.PathFlag{Name: "requests", Usage: "include active Access Requests written by the dump-requests command"},
+		targetMappingsFlag,
+	},
+	Action: func(c *cli.Context) error {
+		reportPath := c.Path("report")
+		matrix := c.Path("matrix")
+		if !c.Bool("save") && matrix == "" {
+			return errors.New("at least one of --save or --matrix must be provided")
+		}
+
+		g, err := loadReportGraph(reportPath)
+		if err != nil {
+			return err
+		}
+
+		model := accessgraph.AWSSSO
+
+		if requestsFile := c.Path("requests"); requestsFile != "" {
+			mappers, err := loadMappers(c)
+			if err != nil {
+				return err
+			}
+			err = addCommonFateGrants(g, model, requestsFile, mappers)
+			if err != nil {
+				return err
+			}
+		}
+
+		access, err := accessgraph.AllEffectiveAccess(g, model)
+		if err != nil {
+			return err
+		}
+
+		clio.Infof("found %d paths granting access", len(access))
+
+		if c.Bool("save") {
+			db, err := report.Open(reportPath)
+			if err != nil {
+				return err
+			}
+			err = accessgraph.SaveEffectiveAccess(db, model, access)
+			if err != nil {
+				return err
+			}
+			clio.Successf("saved effective access to the %s table in %s", accessgraph.EffectiveAccessTable, reportPath)
+		}
+
+		if matrix != "" {
+			var b bytes.Buffer
+			err = accessgraph.WriteMatrix(&b, g, model, access)
+			if err != nil {
+				return err
+			}
+			err = writeOutput(matrix, b.Bytes())
+			if err != nil {
+				return err
+			}
+			if matrix != "-" {
+				clio.Successf("wrote effective access matrix to %s", matrix)
+			}
+		}
+
+		return nil
+	},
+}
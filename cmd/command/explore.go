@@ -0,0 +1,73 @@
+package command
+
+import (
+	"fmt"
+	"os"
+
+	"github.com/common-fate/access-inspector/pkg/accessgraph"
+	"github.com/common-fate/access-inspector/pkg/explore"
+	"github.com/common-fate/access-inspector/pkg/report"
+	"github.com/urfave/cli/v2"
+)
+
+var Explore = cli.Command{
+	Name:  "explore",
+	Usage: "Browse users, groups, accounts and permission sets in a report, and mark access for removal",
+	Flags: []cli.Flag{
+		&cli.PathFlag{Name: "report", Required: true},
+		&cli.PathFlag{Name: "requests", Usage: "include active Access Requests written by the dump-requests command"},
+		targetMappingsFlag,
+		&cli.PathFlag{Name: "script", Value: "remediation.sh", Usage: "the default file to export the remediation script for marked access to"},
+	},
+	Action: func(c *cli.Context) error {
+		reportPath := c.Path("report")
+
+		db, err := report.Open(reportPath)
+		if err != nil {
+			return err
+		}
+		sso, err := ssoScriptOptions(db)
+		db.Close()
+		if err != nil {
+			return err
+		}
+
+		g, err := loadReportGraph(reportPath)
+		if err != nil {
+			return err
+		}
+
+		model := accessgraph.AWSSSO
+
+		if requestsFile := c.Path("requests"); requestsFile != "" {
+			mappers, err := loadMappers(c)
+			if err != nil {
+				return err
+			}
+			err = addCommonFateGrants(g, model, requestsFile, mappers)
+			if err != nil {
+				return err
+			}
+		}
+
+		e, err := explore.New(g, model)
+		if err != nil {
+			return err
+		}
+
+		err = explore.Run(e, os.Stdin, os.Stdout, explore.Options{
+			InstanceARN: sso.InstanceARN,
+			Region:      sso.Region,
+			Instances:   sso.Instances,
+			ScriptPath:  c.Path("script"),
+		})
+		if err != nil {
+			return err
+		}
+
+		if marked := e.Marked(); len(marked) > 0 {
+			fmt.Fprintf(os.Stderr, "%d account assignments were marked for removal\n", len(marked))
+		}
+		return nil
+	},
+}
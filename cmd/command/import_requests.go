@@ -0,0 +1,41 @@
+package command
+
+import (
+	"github.com/common-fate/access-inspector/pkg/report"
+	"github.com/common-fate/access-inspector/pkg/requests"
+	"github.com/common-fate/clio"
+	"github.com/urfave/cli/v2"
+)
+
+// importRequests replaces the Access Requests stored in a report.
+func importRequests(reportPath string, entries []requests.Entry) error {
+	db, err := report.Open(reportPath)
+	if err != nil {
+		return err
+	}
+	defer db.Close()
+
+	err = requests.Save(db, entries)
+	if err != nil {
+		return err
+	}
+
+	clio.Successf("imported %d Access Requests into %s", len(entries), reportPath)
+	return nil
+}
+
+var ImportRequests = cli.Command{
+	Name:  "import-requests",
+	Usage: "Import Access Requests written by the dump-requests command into tables in a report",
+	Flags: []cli.Flag{
+		&cli.PathFlag{Name: "report", Required: true},
+		&cli.PathFlag{Name: "requests", Required: true, Usage: "Access Requests written by the dump-requests command, with or without --history"},
+	},
+	Action: func(c *cli.Context) error {
+		entries, err := requests.Load(c.Path("requests"))
+		if err != nil {
+			return err
+		}
+		return importRequests(c.Path("report"), entries)
+	},
+}
@@ -0,0 +1,86 @@
+package command
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"sort"
+	"strings"
+
+	"github.com/common-fate/access-inspector/pkg/report"
+	"github.com/pkg/errors"
+)
+
+// scanInstance is a named provider configuration, such as one of several AWS SSO instances.
+type scanInstance struct {
+	Name string `json:"name"`
+	// Config are the provider config values, which are passed to the provider as PROVIDER_CONFIG_* environment variables.
+	Config map[string]string `json:"config"`
+	// Env are other environment variables for the provider, such as the AWS_PROFILE to use for the instance.
+	Env map[string]string `json:"env"`
+}
+
+// instancesConfig is the format of the instances file.
+//
+//	{"instances": [{"name": "org-a", "config": {"sso_instance_arn": "...", "sso_region": "us-east-1", "sso_identity_store_id": "...", "sso_role_arn": ""}, "env": {"AWS_PROFILE": "org-a"}}]}
+type instancesConfig struct {
+	Instances []scanInstance `json:"instances"`
+}
+
+// environ returns the environment of the provider process, which is the environment
+// of the scan command with the instance's environment variables and config added.
+func (i scanInstance) environ() []string {
+	env := os.Environ()
+
+	var keys []string
+	for k := range i.Env {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	for _, k := range keys {
+		env = append(env, k+"="+i.Env[k])
+	}
+
+	keys = nil
+	for k := range i.Config {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	for _, k := range keys {
+		// later values take precedence, so the instance config replaces any PROVIDER_CONFIG_* variables which are set
+		env = append(env, "PROVIDER_CONFIG_"+strings.ToUpper(k)+"="+i.Config[k])
+	}
+	return env
+}
+
+// loadInstances reads the instances file. If path is empty, the default instance is scanned.
+func loadInstances(path string) ([]scanInstance, error) {
+	if path == "" {
+		return []scanInstance{{Name: report.DefaultInstance}}, nil
+	}
+
+	b, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	var cfg instancesConfig
+	err = json.Unmarshal(b, &cfg)
+	if err != nil {
+		return nil, errors.Wrapf(err, "parsing instances file %s", path)
+	}
+	if len(cfg.Instances) == 0 {
+		return nil, fmt.Errorf("instances file %s doesn't contain any instances", path)
+	}
+
+	seen := map[string]bool{}
+	for _, i := range cfg.Instances {
+		if i.Name == "" {
+			return nil, fmt.Errorf("parsing instances file %s: instances must have a name", path)
+		}
+		if seen[i.Name] {
+			return nil, fmt.Errorf("parsing instances file %s: there is more than one instance named %s", path, i.Name)
+		}
+		seen[i.Name] = true
+	}
+	return cfg.Instances, nil
+}
@@ -0,0 +1,14 @@
+package command
+
+import (
+	"github.com/common-fate/access-inspector/pkg/requests"
+	"github.com/urfave/cli/v2"
+)
+
+// targetMappingsFlag declares how Access Requests for targets without a built-in mapper grant entitlements.
+var targetMappingsFlag = &cli.PathFlag{Name: "target-mappings", Usage: "a JSON file declaring the entitlements granted by Access Requests for each provider or target group"}
+
+// loadMappers creates the registry which maps Access Requests to the entitlements they grant.
+func loadMappers(c *cli.Context) (*requests.Registry, error) {
+	return requests.LoadMappers(c.Path("target-mappings"))
+}
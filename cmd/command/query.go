@@ -0,0 +1,111 @@
+package command
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+	"strings"
+	"text/tabwriter"
+
+	"github.com/common-fate/access-inspector/pkg/query"
+	"github.com/common-fate/access-inspector/pkg/report"
+	"github.com/urfave/cli/v2"
+)
+
+// parseQueryParams parses query parameters given as --name value or --name=value.
+func parseQueryParams(args []string) (map[string]string, error) {
+	params := map[string]string{}
+	for i := 0; i < len(args); i++ {
+		arg := args[i]
+		if !strings.HasPrefix(arg, "-") {
+			return nil, fmt.Errorf("unexpected argument %q: query parameters must be given as --name value", arg)
+		}
+		name := strings.TrimLeft(arg, "-")
+		if k, v, ok := strings.Cut(name, "="); ok {
+			params[k] = v
+			continue
+		}
+		if i+1 >= len(args) {
+			return nil, fmt.Errorf("no value given for query parameter %s", arg)
+		}
+		params[name] = args[i+1]
+		i++
+	}
+	return params, nil
+}
+
+var Query = cli.Command{
+	Name:      "query",
+	Usage:     "Run a named query against a report",
+	ArgsUsage: "<query name> [--<param> <value> ...]",
+	Flags: []cli.Flag{
+		&cli.PathFlag{Name: "report", Usage: "the report to query (not required with --list)"},
+		&cli.PathFlag{Name: "queries-dir", Usage: "a directory of .sql files to load in addition to the built-in queries"},
+		&cli.StringFlag{Name: "format", Value: "table", Usage: "the output format (table, json or csv)"},
+		&cli.PathFlag{Name: "output", Value: "-", Usage: "the file to write the results to, or '-' for stdout"},
+		&cli.BoolFlag{Name: "list", Usage: "list the available queries and their parameters"},
+	},
+	Action: func(c *cli.Context) error {
+		lib, err := query.Load(c.Path("queries-dir"))
+		if err != nil {
+			return err
+		}
+
+		if c.Bool("list") {
+			tw := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+			for _, q := range lib.Sorted() {
+				var params []string
+				for _, p := range q.Params {
+					if p.Required {
+						params = append(params, fmt.Sprintf("--%s <value>", p.Name))
+					} else {
+						params = append(params, fmt.Sprintf("[--%s <value>]", p.Name))
+					}
+				}
+				fmt.Fprintf(tw, "%s\t%s\t%s\n", strings.TrimSpace(q.Name+" "+strings.Join(params, " ")), q.Description, q.Source)
+			}
+			return tw.Flush()
+		}
+
+		if c.NArg() == 0 {
+			return fmt.Errorf("a query name must be provided: run with --list to see the available queries")
+		}
+
+		format, err := query.ParseFormat(c.String("format"))
+		if err != nil {
+			return err
+		}
+
+		q, err := lib.Get(c.Args().First())
+		if err != nil {
+			return err
+		}
+
+		params, err := parseQueryParams(c.Args().Tail())
+		if err != nil {
+			return err
+		}
+
+		reportPath := c.Path("report")
+		if reportPath == "" {
+			return fmt.Errorf("--report must be provided")
+		}
+		db, err := report.Open(reportPath)
+		if err != nil {
+			return err
+		}
+		defer db.Close()
+
+		t, err := query.Run(db, q, params)
+		if err != nil {
+			return err
+		}
+
+		var b bytes.Buffer
+		err = query.Write(&b, t, format)
+		if err != nil {
+			return err
+		}
+		return writeOutput(c.Path("output"), b.Bytes())
+	},
+}
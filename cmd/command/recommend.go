@@ -0,0 +1,89 @@
+package command
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/common-fate/access-inspector/pkg/cloudtrail"
+	"github.com/common-fate/access-inspector/pkg/recommend"
+	"github.com/common-fate/access-inspector/pkg/report"
+	"github.com/common-fate/access-inspector/pkg/usage"
+	"github.com/common-fate/clio"
+	"github.com/urfave/cli/v2"
+)
+
+var Recommend = cli.Command{
+	Name:  "recommend",
+	Usage: "Recommend less privileged permission sets based on API activity in exported CloudTrail logs",
+	Flags: append([]cli.Flag{
+		&cli.PathFlag{Name: "report", Required: true},
+		&cli.PathFlag{Name: "cloudtrail", Required: true, Usage: "a directory of exported CloudTrail JSON logs"},
+		&cli.StringFlag{Name: "format", Value: "text", Usage: "the output format (text or json)"},
+	}, riskFlags...),
+	Action: func(c *cli.Context) error {
+		db, err := report.Open(c.Path("report"))
+		if err != nil {
+			return err
+		}
+
+		dir := c.Path("cloudtrail")
+		clio.Infof("loading CloudTrail logs from %s", dir)
+
+		events, err := cloudtrail.Load(dir)
+		if err != nil {
+			return err
+		}
+
+		resolver, err := usage.NewResolver(db)
+		if err != nil {
+			return err
+		}
+
+		scorer, err := loadScorer(c, db)
+		if err != nil {
+			return err
+		}
+
+		recommendations := recommend.Compute(scorer, resolver, events)
+
+		switch c.String("format") {
+		case "json":
+			enc := json.NewEncoder(os.Stdout)
+			enc.SetIndent("", "  ")
+			return enc.Encode(recommendations)
+		case "text":
+		default:
+			return fmt.Errorf("unsupported format %q: must be text or json", c.String("format"))
+		}
+
+		users, err := report.Users(db)
+		if err != nil {
+			return err
+		}
+		emails := map[string]string{}
+		for _, u := range users {
+			emails[u.ID] = u.Email
+		}
+
+		accountNames, err := report.Names(db, "account")
+		if err != nil {
+			return err
+		}
+
+		for _, r := range recommendations {
+			fmt.Printf("downgrade %s in %s (%s) from %s to %s\n", emails[r.UserID], accountNames[r.AccountID], r.AccountID, r.Current.Name, r.Recommended.Name)
+			fmt.Printf("  observed actions: %s\n", strings.Join(r.ObservedActions, ", "))
+
+			var names []string
+			for _, c := range r.Candidates {
+				names = append(names, c.Name)
+			}
+			fmt.Printf("  permission sets covering these actions: %s\n\n", strings.Join(names, ", "))
+		}
+
+		clio.Successf("found %d downgrade recommendations", len(recommendations))
+		return nil
+	},
+}
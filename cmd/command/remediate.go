@@ -0,0 +1,110 @@
+package command
+
+import (
+	"encoding/json"
+	"io"
+	"os"
+
+	"github.com/common-fate/access-inspector/pkg/remediation"
+	"github.com/common-fate/access-inspector/pkg/report"
+	"github.com/common-fate/clio"
+	"github.com/jmoiron/sqlx"
+	"github.com/pkg/errors"
+	"github.com/urfave/cli/v2"
+)
+
+// ssoScriptOptions reads the AWS SSO instances in a report from the config of the scan. Account
+// assignments are in the first instance scanned, unless their permission set is in another.
+func ssoScriptOptions(db *sqlx.DB) (remediation.ScriptOptions, error) {
+	instances, err := report.Instances(db)
+	if err != nil {
+		return remediation.ScriptOptions{}, err
+	}
+	var opts remediation.ScriptOptions
+	if len(instances) > 0 {
+		opts.InstanceARN = instances[0].InstanceARN
+		opts.Region = instances[0].Region
+	}
+
+	byPermissionSet, err := report.PermissionSetInstances(db)
+	if err != nil {
+		return remediation.ScriptOptions{}, err
+	}
+	opts.Instances = map[string]remediation.SSOInstance{}
+	for arn, i := range byPermissionSet {
+		opts.Instances[arn] = remediation.SSOInstance{InstanceARN: i.InstanceARN, Region: i.Region}
+	}
+	return opts, nil
+}
+
+var Remediate = cli.Command{
+	Name:  "remediate",
+	Usage: "Write a script which removes or restores entitlements in AWS SSO, Okta, Azure AD or GCP",
+	Flags: []cli.Flag{
+		&cli.PathFlag{Name: "entitlements", Required: true, Usage: "a JSON file listing the entitlements"},
+		&cli.BoolFlag{Name: "restore", Usage: "restore the entitlements rather than removing them"},
+		&cli.PathFlag{Name: "output", Value: "-", Usage: "the file to write the script to, or - for stdout"},
+		&cli.PathFlag{Name: "report", Usage: "read the AWS SSO instances from the config of the scan which created this report"},
+		&cli.StringFlag{Name: "sso-instance-arn", Usage: "the AWS SSO instance ARN, if --report isn't given"},
+		&cli.StringFlag{Name: "sso-region", Usage: "the region of the AWS SSO instance, if --report isn't given"},
+	},
+	Action: func(c *cli.Context) error {
+		b, err := os.ReadFile(c.Path("entitlements"))
+		if err != nil {
+			return err
+		}
+		var entitlements []remediation.Entitlement
+		err = json.Unmarshal(b, &entitlements)
+		if err != nil {
+			return errors.Wrapf(err, "parsing entitlements file %s", c.Path("entitlements"))
+		}
+
+		opts := remediation.ScriptOptions{
+			Action:      remediation.ActionRemove,
+			InstanceARN: c.String("sso-instance-arn"),
+			Region:      c.String("sso-region"),
+		}
+		if c.Bool("restore") {
+			opts.Action = remediation.ActionRestore
+		}
+
+		if reportPath := c.Path("report"); reportPath != "" {
+			db, err := report.Open(reportPath)
+			if err != nil {
+				return err
+			}
+			defer db.Close()
+
+			sso, err := ssoScriptOptions(db)
+			if err != nil {
+				return err
+			}
+			opts.InstanceARN, opts.Region, opts.Instances = sso.InstanceARN, sso.Region, sso.Instances
+		}
+
+		// check the entitlements before creating the output file, so that a failed run doesn't leave an empty script
+		err = remediation.ValidateScript(opts, entitlements)
+		if err != nil {
+			return err
+		}
+
+		var out io.Writer = os.Stdout
+		if output := c.Path("output"); output != "-" {
+			f, err := os.OpenFile(output, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, 0755)
+			if err != nil {
+				return err
+			}
+			defer f.Close()
+			out = f
+		}
+
+		err = remediation.WriteEntitlementScript(c.Context, out, opts, entitlements)
+		if err != nil {
+			return err
+		}
+		if c.Path("output") != "-" {
+			clio.Successf("wrote a script to %s %d entitlements to %s", opts.Action, len(entitlements), c.Path("output"))
+		}
+		return nil
+	},
+}
@@ -0,0 +1,238 @@
+package command
+
+import (
+	"bytes"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/common-fate/access-inspector/pkg/htmlreport"
+	"github.com/common-fate/access-inspector/pkg/report"
+	"github.com/common-fate/clio"
+	"github.com/urfave/cli/v2"
+)
+
+var HTMLReport = cli.Command{
+	Name:  "report",
+	Usage: "Generate a self-contained HTML access report",
+	Flags: append([]cli.Flag{
+		&cli.PathFlag{Name: "report", Required: true},
+		&cli.PathFlag{Name: "requests", Usage: "active Access Requests written by the dump-requests command"},
+		targetMappingsFlag,
+		&cli.PathFlag{Name: "output", Value: "report.html"},
+		&cli.StringFlag{Name: "title", Value: "Access report"},
+	}, riskFlags...),
+	Action: func(c *cli.Context) error {
+		db, err := report.Open(c.Path("report"))
+		if err != nil {
+			return err
+		}
+
+		scorer, err := loadScorer(c, db)
+		if err != nil {
+			return err
+		}
+
+		data := htmlreport.Data{
+			Title:       c.String("title"),
+			GeneratedAt: time.Now(),
+		}
+
+		tables := []struct {
+			Table string
+			Label string
+		}{
+			{"user", "Users"},
+			{"group", "Groups"},
+			{"account", "Accounts"},
+			{"permissionset", "Permission sets"},
+		}
+
+		for _, t := range tables {
+			table, err := report.LoadTable(db, t.Table)
+			if err != nil {
+				return err
+			}
+			table.Name = t.Label
+			data.Tables = append(data.Tables, table)
+		}
+
+		permissionSetRisk := report.Table{
+			Name:    "Permission set risk",
+			Columns: []string{"Permission set", "ARN", "Score", "Reasons"},
+		}
+		for _, ps := range scorer.Ranked() {
+			permissionSetRisk.Rows = append(permissionSetRisk.Rows, []string{ps.Name, ps.ARN, strconv.Itoa(ps.Score), strings.Join(ps.Reasons, "; ")})
+		}
+		data.Tables = append(data.Tables, permissionSetRisk)
+
+		userAssignments, err := report.UserAssignments(db)
+		if err != nil {
+			return err
+		}
+
+		groupAssignments, err := report.GroupAssignments(db)
+		if err != nil {
+			return err
+		}
+
+		accountNames, err := report.Names(db, "account")
+		if err != nil {
+			return err
+		}
+
+		permissionSetNames, err := report.Names(db, "permissionset")
+		if err != nil {
+			return err
+		}
+
+		users, err := report.Users(db)
+		if err != nil {
+			return err
+		}
+
+		assignments := report.Table{
+			Name:    "Assignments",
+			Columns: []string{"Principal type", "Principal", "Account", "Account ID", "Permission set", "Assignment ID"},
+		}
+
+		// the effective access for each user, keyed by user ID
+		access := map[string][]htmlreport.Access{}
+
+		for _, ua := range userAssignments {
+			assignments.Rows = append(assignments.Rows, []string{"USER", ua.UserEmail, ua.AccountName, ua.Account, ua.PermissionSetName, ua.AccountAssignmentID})
+			access[ua.UserID] = append(access[ua.UserID], htmlreport.Access{
+				AccountID:         ua.Account,
+				AccountName:       ua.AccountName,
+				PermissionSetARN:  ua.PermissionSetARN,
+				PermissionSetName: ua.PermissionSetName,
+				Source:            htmlreport.SourceDirect,
+			})
+		}
+
+		// group assignments are returned once per member, so only add them to the assignments table once
+		seenGroupAssignment := map[string]bool{}
+
+		for _, ga := range groupAssignments {
+			if !seenGroupAssignment[ga.AccountAssignmentID] {
+				seenGroupAssignment[ga.AccountAssignmentID] = true
+				assignments.Rows = append(assignments.Rows, []string{"GROUP", ga.GroupName, ga.AccountName, ga.Account, ga.PermissionSetName, ga.AccountAssignmentID})
+			}
+			access[ga.UserID] = append(access[ga.UserID], htmlreport.Access{
+				AccountID:         ga.Account,
+				AccountName:       ga.AccountName,
+				PermissionSetARN:  ga.PermissionSetARN,
+				PermissionSetName: ga.PermissionSetName,
+				Source:            htmlreport.SourceGroup,
+				Via:               ga.GroupName,
+			})
+		}
+
+		data.Tables = append(data.Tables, assignments)
+
+		if requestsFile := c.Path("requests"); requestsFile != "" {
+			clio.Infof("loading active Access Requests in Common Fate from %s", requestsFile)
+
+			accessRequests, err := loadAccessRequests(requestsFile)
+			if err != nil {
+				return err
+			}
+
+			userIDs := map[string]string{}
+			for _, u := range users {
+				userIDs[u.Email] = u.ID
+			}
+
+			mappers, err := loadMappers(c)
+			if err != nil {
+				return err
+			}
+
+			for _, req := range accessRequests {
+				assignments := mappers.AccountAssignments(req)
+				if len(assignments) == 0 {
+					continue
+				}
+				userID, ok := userIDs[req.User.Email]
+				if !ok {
+					clio.Warnf("user %s in Access Request %s was not found in the report", req.User.Email, req.Request.ID)
+					continue
+				}
+
+				for _, a := range assignments {
+					access[userID] = append(access[userID], htmlreport.Access{
+						AccountID:         a.AccountID,
+						AccountName:       accountNames[a.AccountID],
+						PermissionSetARN:  a.PermissionSetARN,
+						PermissionSetName: permissionSetNames[a.PermissionSetARN],
+						Source:            htmlreport.SourceCommonFate,
+						Via:               req.Request.ID,
+					})
+				}
+			}
+		}
+
+		summaries := map[string]*htmlreport.AccountSummary{}
+		for id, name := range accountNames {
+			summaries[id] = &htmlreport.AccountSummary{ID: id, Name: name}
+		}
+
+		for _, u := range users {
+			ua := htmlreport.UserAccess{ID: u.ID, Email: u.Email, Access: access[u.ID]}
+
+			for i, a := range ua.Access {
+				score := scorer.Assignment(a.AccountID, a.PermissionSetARN)
+				ua.Access[i].Risk = score.Value
+				ua.Access[i].RiskReason = score.String()
+			}
+			sort.SliceStable(ua.Access, func(i, j int) bool {
+				return ua.Access[i].Risk > ua.Access[j].Risk
+			})
+			data.Users = append(data.Users, ua)
+
+			// a user may hold the same entitlement in several ways, so only count each entitlement once
+			standing := map[string]bool{}
+			jit := map[string]bool{}
+
+			for _, a := range ua.Access {
+				s, ok := summaries[a.AccountID]
+				if !ok {
+					s = &htmlreport.AccountSummary{ID: a.AccountID, Name: a.AccountName}
+					summaries[a.AccountID] = s
+				}
+				key := a.AccountID + "+" + a.PermissionSetARN
+				if a.JIT() && !jit[key] {
+					jit[key] = true
+					s.JIT++
+				} else if !a.JIT() && !standing[key] {
+					standing[key] = true
+					s.Standing++
+				}
+			}
+		}
+
+		for _, s := range summaries {
+			data.Accounts = append(data.Accounts, *s)
+		}
+		sort.Slice(data.Accounts, func(i, j int) bool {
+			return data.Accounts[i].Name < data.Accounts[j].Name
+		})
+
+		var b bytes.Buffer
+		err = htmlreport.Render(&b, data)
+		if err != nil {
+			return err
+		}
+
+		output := c.Path("output")
+		err = os.WriteFile(output, b.Bytes(), 0644)
+		if err != nil {
+			return err
+		}
+
+		clio.Successf("wrote access report to %s", output)
+		return nil
+	},
+}
@@ -0,0 +1,21 @@
+package command
+
+import (
+	"github.com/common-fate/access-inspector/pkg/risk"
+	"github.com/jmoiron/sqlx"
+	"github.com/urfave/cli/v2"
+)
+
+// riskFlags configure how account sensitivity is determined when scoring assignments.
+var riskFlags = []cli.Flag{
+	&cli.PathFlag{Name: "account-sensitivity", Usage: "a JSON file mapping account IDs or names to a sensitivity of low, medium, high or critical"},
+	&cli.StringFlag{Name: "sensitivity-tag", Value: "sensitivity", Usage: "the account tag to read the account sensitivity from"},
+}
+
+// loadScorer builds a risk scorer from the report and the risk flags.
+func loadScorer(c *cli.Context, db *sqlx.DB) (*risk.Scorer, error) {
+	return risk.Load(db, risk.Options{
+		SensitivityFile: c.Path("account-sensitivity"),
+		SensitivityTag:  c.String("sensitivity-tag"),
+	})
+}
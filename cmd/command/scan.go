@@ -1,24 +1,76 @@
 package command
 
 import (
-	"encoding/json"
+	"context"
 	"fmt"
-	"strings"
 
 	"github.com/common-fate/access-inspector/pkg/loader"
+	"github.com/common-fate/access-inspector/pkg/report"
 	"github.com/common-fate/clio"
 	"github.com/common-fate/provider-registry-sdk-go/pkg/handlerclient"
+	"github.com/common-fate/provider-registry-sdk-go/pkg/msg"
 	"github.com/common-fate/provider-registry-sdk-go/pkg/providerregistrysdk"
 	"github.com/jmoiron/sqlx"
 	"github.com/joho/godotenv"
 	_ "github.com/mattn/go-sqlite3"
 	"github.com/pkg/errors"
 	"github.com/urfave/cli/v2"
+	"golang.org/x/sync/errgroup"
 )
 
-// tableName gets the SQlite table name for a particular resource type
-func tableName(p providerregistrysdk.Provider, schemaVersion string, resourceType string) string {
-	return strings.ToLower(resourceType)
+// scanResult is the data loaded from the provider for an instance.
+type scanResult struct {
+	instance  scanInstance
+	describe  *providerregistrysdk.DescribeResponse
+	resources map[string]msg.Resource
+}
+
+// scanInstances loads the resources of each instance, scanning up to concurrency instances at once.
+func scanInstances(ctx context.Context, providerPath string, instances []scanInstance, concurrency int) ([]scanResult, error) {
+	results := make([]scanResult, len(instances))
+
+	g, gctx := errgroup.WithContext(ctx)
+	g.SetLimit(concurrency)
+
+	for i, instance := range instances {
+		i, instance := i, instance
+		g.Go(func() error {
+			hc := handlerclient.Client{
+				Executor: handlerclient.Local{
+					Dir: providerPath,
+					Env: instance.environ(),
+				},
+			}
+
+			describe, err := hc.Describe(gctx)
+			if err != nil {
+				return errors.Wrapf(err, "describing the provider for instance %s", instance.Name)
+			}
+
+			var tasks []string
+			for task := range describe.Schema.Resources.Loaders {
+				tasks = append(tasks, task)
+			}
+
+			fetcher := loader.NewResourceFetcher(&hc)
+
+			clio.Infow("loading resources", "instance", instance.Name, "tasks", tasks)
+
+			resources, err := fetcher.LoadResources(gctx, tasks)
+			if err != nil {
+				return errors.Wrapf(err, "loading resources for instance %s", instance.Name)
+			}
+
+			results[i] = scanResult{instance: instance, describe: describe, resources: resources}
+			return nil
+		})
+	}
+
+	err := g.Wait()
+	if err != nil {
+		return nil, err
+	}
+	return results, nil
 }
 
 var Scan = cli.Command{
@@ -26,27 +78,30 @@ var Scan = cli.Command{
 	Flags: []cli.Flag{
 		&cli.PathFlag{Name: "provider-local-path", Required: true},
 		&cli.PathFlag{Name: "output", Required: true},
+		&cli.PathFlag{Name: "instances", Usage: "a JSON file of named provider configs to scan, such as several AWS SSO instances, rather than reading the config from PROVIDER_CONFIG_* environment variables"},
+		&cli.IntFlag{Name: "concurrency", Value: 4, Usage: "the number of instances to scan at once"},
 	},
 	Action: func(c *cli.Context) error {
 		ctx := c.Context
 		_ = godotenv.Load()
 
-		hc := handlerclient.Client{
-			Executor: handlerclient.Local{
-				Dir: c.String("provider-local-path"),
-			},
+		if c.Int("concurrency") < 1 {
+			return fmt.Errorf("--concurrency must be at least 1")
 		}
 
-		describe, err := hc.Describe(ctx)
+		instances, err := loadInstances(c.Path("instances"))
 		if err != nil {
 			return err
 		}
 
-		var tasks []string
-		for task := range describe.Schema.Resources.Loaders {
-			tasks = append(tasks, task)
+		results, err := scanInstances(ctx, c.String("provider-local-path"), instances, c.Int("concurrency"))
+		if err != nil {
+			return err
 		}
 
+		// every instance is scanned with the same provider, so the tables are created from the schema of the first
+		describe := results[0].describe
+
 		dbfilename := fmt.Sprintf("file:%s", c.Path("output"))
 
 		db, err := sqlx.Open("sqlite3", dbfilename)
@@ -54,117 +109,40 @@ var Scan = cli.Command{
 			return err
 		}
 
-		// TODO: don't hardcode
-		// (local providers don't return version details at the moment when Describe is called
-		// so need to hardcode for now)
-		p := providerregistrysdk.Provider{
-			Name:      "aws",
-			Publisher: "common_fate",
-			Version:   "v0.4.0",
+		err = report.CreateTables(db, *describe)
+		if err != nil {
+			return err
 		}
-		schemaVersion := "v1" // hardcode for now
-
-		for resourceType, resource := range describe.Schema.Resources.Types {
 
-			table := tableName(p, schemaVersion, resourceType)
-
-			resourceData := resource.(map[string]any)
-			properties := resourceData["properties"].(map[string]any)
-
-			cols := []string{
-				`"id" TEXT PRIMARY KEY`,
-				`"name" TEXT`,
+		for _, result := range results {
+			err = report.AddInstance(db, result.instance.Name, *result.describe)
+			if err != nil {
+				return err
 			}
 
-			if _, ok := properties["data"]; ok {
-				dataProps := properties["data"].(map[string]any)
-
-				for property := range dataProps {
-					// default to TEXT columns for everything for now
-					col := fmt.Sprintf(`"%s" TEXT`, property)
-					cols = append(cols, col)
-				}
+			resources := make([]msg.Resource, 0, len(result.resources))
+			for _, r := range result.resources {
+				resources = append(resources, r)
 			}
 
-			stmt := fmt.Sprintf(`CREATE TABLE "%s" (%s)`, table, strings.Join(cols, ", "))
-
-			clio.Debugw("creating table", "sql", stmt)
-
-			_, err = db.Exec(stmt)
+			err = report.InsertResources(db, *describe, result.instance.Name, resources)
 			if err != nil {
-				return errors.Wrapf(err, "creating table %s", table)
+				return err
 			}
 		}
 
-		_, err = db.Exec("CREATE TABLE __common_fate_meta (describe TEXT)")
+		// create views joining the resource tables, for querying the report with other tools
+		err = report.CreateViews(db)
 		if err != nil {
 			return err
 		}
 
-		describeBytes, err := json.Marshal(describe)
+		// fill the entitlement table, which holds the access of every provider in the same shape
+		err = report.CreateEntitlements(db)
 		if err != nil {
 			return err
 		}
 
-		_, err = db.Exec("INSERT INTO __common_fate_meta (describe) VALUES ($1)", string(describeBytes))
-		if err != nil {
-			return err
-		}
-
-		fetcher := loader.NewResourceFetcher(&hc)
-
-		clio.Infow("loading resources", "tasks", tasks)
-
-		resources, err := fetcher.LoadResources(ctx, tasks)
-		if err != nil {
-			return err
-		}
-
-		for _, r := range resources {
-			table := tableName(p, schemaVersion, r.Type)
-
-			cols := []string{`"id"`, `"name"`}
-			vals := []string{`'` + r.ID + `'`, `'` + r.Name + `'`}
-
-			// add the data fields to the cols/vals
-			// so that they can be inserted into the database
-
-			for k, v := range r.Data {
-				if v == nil {
-					continue
-				}
-
-				cols = append(cols, `"`+k+`"`)
-
-				var valString string
-				switch val := v.(type) {
-				case string:
-					valString = `'` + val + `'`
-				default:
-					// JSON encode the value if it's not a string
-					valBytes, err := json.Marshal(val)
-					if err != nil {
-						return err
-					}
-					valString = `'` + string(valBytes) + `'`
-				}
-
-				vals = append(vals, valString)
-			}
-
-			stmt := fmt.Sprintf(`INSERT INTO "%s" (%s) VALUES (%s)`, table, strings.Join(cols, ", "), strings.Join(vals, ", "))
-
-			clio.Debugw("inserting data", "sql", stmt)
-
-			_, err = db.Exec(stmt)
-			if err != nil {
-				return errors.Wrapf(err, "inserting %+v into database", r)
-			}
-
-		}
-
-		// clio.Infow("got resources", resources)
-
 		return nil
 	},
 }
@@ -0,0 +1,114 @@
+package command
+
+import (
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/common-fate/access-inspector/pkg/accessgraph"
+	"github.com/common-fate/access-inspector/pkg/findings"
+	"github.com/common-fate/access-inspector/pkg/report"
+	"github.com/common-fate/access-inspector/pkg/requests"
+	"github.com/common-fate/access-inspector/pkg/server"
+	"github.com/common-fate/access-inspector/pkg/usage"
+	"github.com/common-fate/clio"
+	"github.com/urfave/cli/v2"
+)
+
+var Serve = cli.Command{
+	Name:  "serve",
+	Usage: "Serve a report through a read-only JSON API and a web UI",
+	Flags: append([]cli.Flag{
+		&cli.PathFlag{Name: "report", Required: true},
+		&cli.StringFlag{Name: "addr", Value: "localhost:8080", Usage: "the address to listen on"},
+		&cli.PathFlag{Name: "requests", Usage: "active Access Requests written by the dump-requests command, used for effective access and findings (defaults to the requests imported into the report)"},
+		targetMappingsFlag,
+		&cli.PathFlag{Name: "snapshots-dir", Usage: "a directory of other reports which the report can be compared against"},
+		&cli.IntFlag{Name: "unused-days", Usage: "report assignments used within this many days as recently used (requires the usage command to have been run)"},
+	}, riskFlags...),
+	Action: func(c *cli.Context) error {
+		reportPath := c.Path("report")
+
+		db, err := report.OpenReadOnly(reportPath)
+		if err != nil {
+			return err
+		}
+		defer db.Close()
+
+		g, err := loadReportGraph(reportPath)
+		if err != nil {
+			return err
+		}
+
+		scorer, err := loadScorer(c, db)
+		if err != nil {
+			return err
+		}
+
+		sso, err := ssoScriptOptions(db)
+		if err != nil {
+			return err
+		}
+
+		model := accessgraph.AWSSSO
+		opts := server.Options{
+			Model:        model,
+			Scorer:       scorer,
+			SnapshotsDir: c.Path("snapshots-dir"),
+			Findings:     findings.Options{UnusedDays: c.Int("unused-days")},
+			InstanceARN:  sso.InstanceARN,
+			Region:       sso.Region,
+			Instances:    sso.Instances,
+		}
+
+		var accessRequests []requests.Entry
+		if requestsFile := c.Path("requests"); requestsFile != "" {
+			clio.Infof("loading active Access Requests in Common Fate from %s", requestsFile)
+
+			accessRequests, err = loadAccessRequests(requestsFile)
+			if err != nil {
+				return err
+			}
+		} else {
+			stored, err := requests.Stored(db)
+			if err != nil {
+				return err
+			}
+			if stored != nil {
+				clio.Infof("using the Access Requests imported into %s", reportPath)
+				accessRequests = requests.Active(stored, time.Now())
+			}
+		}
+
+		if accessRequests != nil {
+			mappers, err := loadMappers(c)
+			if err != nil {
+				return err
+			}
+			err = addAccessRequestGrants(g, model, accessRequests, mappers)
+			if err != nil {
+				return err
+			}
+			opts.Findings.CommonFateAccess = commonFateAccessMap(accessRequests, mappers)
+		}
+
+		if opts.Findings.UnusedDays > 0 {
+			opts.Findings.Usage, err = usage.Load(db)
+			if err != nil {
+				return err
+			}
+			if opts.Findings.Usage == nil {
+				return fmt.Errorf("the report doesn't contain usage data: run the usage command before using --unused-days")
+			}
+		}
+
+		s, err := server.New(db, g, opts)
+		if err != nil {
+			return err
+		}
+
+		addr := c.String("addr")
+		clio.Infof("serving %s at http://%s (API at http://%s/api, OpenAPI document at http://%s/api/openapi.json)", reportPath, addr, addr, addr)
+		return http.ListenAndServe(addr, s.Routes())
+	},
+}
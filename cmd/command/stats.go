@@ -0,0 +1,159 @@
+package command
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/common-fate/access-inspector/pkg/report"
+	"github.com/common-fate/access-inspector/pkg/requests"
+	"github.com/common-fate/clio"
+	"github.com/jmoiron/sqlx"
+	"github.com/urfave/cli/v2"
+)
+
+// statsOutput is the output of the stats command in JSON format.
+type statsOutput struct {
+	Entitlements []requests.Stats     `json:"entitlements"`
+	Candidates   []requests.Candidate `json:"candidates,omitempty"`
+}
+
+var Stats = cli.Command{
+	Name:  "stats",
+	Usage: "Summarise the Access Request history, and find standing access which people also request through Common Fate",
+	Flags: []cli.Flag{
+		&cli.PathFlag{Name: "requests", Usage: "Access Requests written by the dump-requests command with --history (defaults to the requests imported into the report)"},
+		&cli.PathFlag{Name: "report", Usage: "find standing account assignments in the report which are also requested through Common Fate"},
+		targetMappingsFlag,
+		&cli.StringFlag{Name: "format", Value: "text", Usage: "the output format (text or json)"},
+	},
+	Action: func(c *cli.Context) error {
+		format := c.String("format")
+		if format != "text" && format != "json" {
+			return fmt.Errorf("unsupported format %q: must be text or json", format)
+		}
+
+		var db *sqlx.DB
+		var err error
+		if reportPath := c.Path("report"); reportPath != "" {
+			db, err = report.Open(reportPath)
+			if err != nil {
+				return err
+			}
+			defer db.Close()
+		}
+
+		source := c.Path("requests")
+		var entries []requests.Entry
+		switch {
+		case source != "":
+			entries, err = requests.Load(source)
+			if err != nil {
+				return err
+			}
+		case db != nil:
+			source = c.Path("report")
+			entries, err = requests.Stored(db)
+			if err != nil {
+				return err
+			}
+			if entries == nil {
+				return fmt.Errorf("the report doesn't contain Access Requests: pass --requests, or import them with the import-requests command")
+			}
+		default:
+			return fmt.Errorf("at least one of --requests or --report must be given")
+		}
+
+		hasEvents := false
+		for _, e := range entries {
+			if len(e.Events) > 0 {
+				hasEvents = true
+				break
+			}
+		}
+		if !hasEvents {
+			clio.Warnf("%s doesn't include Access Request events, so approval times aren't known: export the requests with dump-requests --history", source)
+		}
+
+		out := statsOutput{Entitlements: requests.Compute(entries)}
+
+		if db != nil {
+			direct, err := report.UserAssignments(db)
+			if err != nil {
+				return err
+			}
+			group, err := report.GroupAssignments(db)
+			if err != nil {
+				return err
+			}
+			mappers, err := loadMappers(c)
+			if err != nil {
+				return err
+			}
+			out.Candidates = requests.Candidates(direct, group, entries, mappers)
+		}
+
+		if format == "json" {
+			enc := json.NewEncoder(os.Stdout)
+			enc.SetIndent("", "  ")
+			return enc.Encode(out)
+		}
+
+		fmt.Printf("%d Access Requests for %d entitlements\n", len(entries), len(out.Entitlements))
+		for _, s := range out.Entitlements {
+			fmt.Printf("\n%s\n", s.Entitlement)
+			fmt.Printf("  %d requests by %d users: %d approved (%d automatically), %d declined, %d cancelled, %d pending\n", s.Requests, s.Requestors, s.Approved, s.AutoApproved, s.Declined, s.Cancelled, s.Pending)
+			var details []string
+			if s.Approved+s.Declined > 0 {
+				details = append(details, fmt.Sprintf("%.0f%% approved", s.ApprovalRate*100))
+			}
+			if s.MedianApprovalSeconds != nil {
+				details = append(details, "median approval time "+humanDuration(*s.MedianApprovalSeconds))
+			}
+			if s.MedianDurationSeconds != nil {
+				details = append(details, "typical duration "+humanDuration(*s.MedianDurationSeconds))
+			}
+			details = append(details, "last requested "+s.LastRequested.Format(time.RFC3339))
+			fmt.Printf("  %s\n", strings.Join(details, ", "))
+		}
+
+		if db == nil {
+			return nil
+		}
+
+		fmt.Printf("\nStanding access also requested through Common Fate (%d candidates for JIT):\n", len(out.Candidates))
+		for _, cand := range out.Candidates {
+			fmt.Printf("  %s %s: %s in %s (%s)\n", strings.ToLower(string(cand.PrincipalType)), cand.PrincipalName, cand.PermissionSetName, cand.AccountName, cand.AssignmentID)
+			fmt.Printf("    held by %d users, %d of whom requested it; requested %d times by %d users, %d approved\n", cand.StandingUsers, cand.HoldersRequested, cand.Requests, cand.Requestors, cand.Approved)
+		}
+
+		return nil
+	},
+}
+
+// humanDuration formats a number of seconds, such as "45s", "12m" or "2h30m".
+func humanDuration(seconds int) string {
+	d := time.Duration(seconds) * time.Second
+	switch {
+	case d < time.Minute:
+		return fmt.Sprintf("%ds", seconds)
+	case d < time.Hour:
+		return fmt.Sprintf("%dm", int(d.Minutes()))
+	case d < 24*time.Hour:
+		h := int(d.Hours())
+		m := int(d.Minutes()) - h*60
+		if m == 0 {
+			return fmt.Sprintf("%dh", h)
+		}
+		return fmt.Sprintf("%dh%dm", h, m)
+	default:
+		days := int(d.Hours()) / 24
+		h := int(d.Hours()) - days*24
+		if h == 0 {
+			return fmt.Sprintf("%dd", days)
+		}
+		return fmt.Sprintf("%dd%dh", days, h)
+	}
+}
@@ -0,0 +1,104 @@
+package command
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/common-fate/access-inspector/pkg/accessgraph"
+	"github.com/common-fate/access-inspector/pkg/toxic"
+	"github.com/common-fate/clio"
+	"github.com/urfave/cli/v2"
+)
+
+var ToxicCombinations = cli.Command{
+	Name:  "toxic-combinations",
+	Usage: "Find users whose combined effective access matches a forbidden combination in a rules file",
+	Flags: []cli.Flag{
+		&cli.PathFlag{Name: "report", Required: true},
+		&cli.PathFlag{Name: "rules", Required: true, Usage: "a JSON file of forbidden combinations of accounts and permission sets"},
+		&cli.PathFlag{Name: "requests", Usage: "include active Access Requests written by the dump-requests command"},
+		targetMappingsFlag,
+		&cli.StringFlag{Name: "format", Value: "text", Usage: "the output format (text or json)"},
+		&cli.BoolFlag{Name: "fail", Usage: "exit with an error if any user breaks a rule"},
+	},
+	Action: func(c *cli.Context) error {
+		format := c.String("format")
+		if format != "text" && format != "json" {
+			return fmt.Errorf("unsupported format %q: must be text or json", format)
+		}
+
+		rules, err := toxic.LoadRules(c.Path("rules"))
+		if err != nil {
+			return err
+		}
+
+		g, err := loadReportGraph(c.Path("report"))
+		if err != nil {
+			return err
+		}
+
+		model := accessgraph.AWSSSO
+
+		if requestsFile := c.Path("requests"); requestsFile != "" {
+			mappers, err := loadMappers(c)
+			if err != nil {
+				return err
+			}
+			err = addCommonFateGrants(g, model, requestsFile, mappers)
+			if err != nil {
+				return err
+			}
+		}
+
+		access, err := accessgraph.AllEffectiveAccess(g, model)
+		if err != nil {
+			return err
+		}
+
+		findings := toxic.Check(g, model, rules, access)
+
+		if format == "json" {
+			enc := json.NewEncoder(os.Stdout)
+			enc.SetIndent("", "  ")
+			err = enc.Encode(findings)
+			if err != nil {
+				return err
+			}
+		} else {
+			for _, f := range findings {
+				user := accessgraph.Hops(g, []string{accessgraph.Key(model.User, f.Instance, f.UserID)})[0]
+				fmt.Printf("%s breaks rule %s", user, f.Rule)
+				if f.Description != "" {
+					fmt.Printf(": %s", f.Description)
+				}
+				fmt.Println()
+				for _, p := range f.Parts {
+					fmt.Printf("  %s:\n", p.Match)
+					for _, a := range p.Paths {
+						var hops []string
+						for _, h := range accessgraph.Hops(g, a.Path) {
+							hops = append(hops, h.String())
+						}
+						permissionSet := accessgraph.Hops(g, []string{a.PermissionSetKey(model)})[0]
+						account := accessgraph.Hops(g, []string{a.AccountKey(model)})[0]
+						fmt.Printf("    %s in %s via %s\n", permissionSet, account, a.PathType)
+						fmt.Printf("      %s\n", strings.Join(hops, " -> "))
+					}
+				}
+				fmt.Println()
+			}
+		}
+
+		if len(findings) == 0 {
+			clio.Successf("no users break the %d rules", len(rules.Rules))
+			return nil
+		}
+		clio.Warnf("found %d rule violations", len(findings))
+		if c.Bool("fail") {
+			return fmt.Errorf("found %d toxic combinations", len(findings))
+		}
+		return nil
+	},
+}
@@ -0,0 +1,102 @@
+package command
+
+import (
+	"time"
+
+	"github.com/common-fate/access-inspector/pkg/cloudtrail"
+	"github.com/common-fate/access-inspector/pkg/report"
+	"github.com/common-fate/access-inspector/pkg/usage"
+	"github.com/common-fate/clio"
+	"github.com/jmoiron/sqlx"
+	"github.com/urfave/cli/v2"
+)
+
+var Usage = cli.Command{
+	Name:  "usage",
+	Usage: "Record when account assignments were last used, from exported CloudTrail logs",
+	Flags: []cli.Flag{
+		&cli.PathFlag{Name: "report", Required: true},
+		&cli.PathFlag{Name: "cloudtrail", Required: true, Usage: "a directory of exported CloudTrail JSON logs"},
+		&cli.IntFlag{Name: "unused-days", Value: 90, Usage: "report assignments which haven't been used in this many days"},
+	},
+	Action: func(c *cli.Context) error {
+		db, err := report.Open(c.Path("report"))
+		if err != nil {
+			return err
+		}
+
+		dir := c.Path("cloudtrail")
+		clio.Infof("loading CloudTrail logs from %s", dir)
+
+		events, err := cloudtrail.Load(dir)
+		if err != nil {
+			return err
+		}
+
+		// events counted by an earlier run, from logs which are loaded again, are skipped
+		unprocessed, err := usage.Unprocessed(db, events)
+		if err != nil {
+			return err
+		}
+		if skipped := len(events) - len(unprocessed); skipped > 0 {
+			clio.Infof("skipping %d CloudTrail events which were recorded by an earlier run", skipped)
+		}
+
+		resolver, err := usage.NewResolver(db)
+		if err != nil {
+			return err
+		}
+
+		res := usage.Compute(resolver, unprocessed)
+		if res.Unmatched > 0 {
+			clio.Warnf("%d Identity Center events could not be matched to a user and permission set in the report", res.Unmatched)
+		}
+
+		err = usage.Save(db, res)
+		if err != nil {
+			return err
+		}
+
+		clio.Successf("recorded usage of %d entitlements from %d CloudTrail events in the %s table", len(res.Usage), len(unprocessed), usage.Table)
+
+		// log unused access using everything stored, including the usage from logs loaded earlier
+		stored, err := usage.Load(db)
+		if err != nil {
+			return err
+		}
+		return logUnused(db, c.Int("unused-days"), stored)
+	},
+}
+
+// logUnused logs every assignment which hasn't been used in the given number of days.
+// byKey is the usage keyed by usage.Key().
+func logUnused(db *sqlx.DB, days int, byKey map[string]usage.Usage) error {
+	cutoff := time.Now().AddDate(0, 0, -days)
+
+	userAssignments, err := report.UserAssignments(db)
+	if err != nil {
+		return err
+	}
+	for _, ua := range userAssignments {
+		u, ok := byKey[usage.Key(ua.UserID, ua.Account, ua.PermissionSetARN)]
+		if !ok {
+			clio.Infof("UNUSED: user %s has never used %s in %s (%v)", ua.UserEmail, ua.PermissionSetName, ua.AccountName, ua.Account)
+		} else if u.LastUsed.Before(cutoff) {
+			clio.Infof("UNUSED: user %s last used %s in %s (%v) at %s", ua.UserEmail, ua.PermissionSetName, ua.AccountName, ua.Account, u.LastUsed.Format(time.RFC3339))
+		}
+	}
+
+	groupAssignments, err := report.GroupAssignments(db)
+	if err != nil {
+		return err
+	}
+	for _, ga := range groupAssignments {
+		u, ok := byKey[usage.Key(ga.UserID, ga.Account, ga.PermissionSetARN)]
+		if !ok {
+			clio.Infof("UNUSED: user %s has never used %s in %s (%v) which they have through group %s", ga.UserEmail, ga.PermissionSetName, ga.AccountName, ga.Account, ga.GroupName)
+		} else if u.LastUsed.Before(cutoff) {
+			clio.Infof("UNUSED: user %s last used %s in %s (%v) which they have through group %s at %s", ga.UserEmail, ga.PermissionSetName, ga.AccountName, ga.Account, ga.GroupName, u.LastUsed.Format(time.RFC3339))
+		}
+	}
+	return nil
+}
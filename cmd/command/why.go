@@ -0,0 +1,173 @@
+package command
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/common-fate/access-inspector/pkg/accessgraph"
+	"github.com/common-fate/access-inspector/pkg/requests"
+	"github.com/common-fate/clio"
+	"github.com/common-fate/provider-registry-sdk-go/pkg/msg"
+	"github.com/urfave/cli/v2"
+)
+
+// addCommonFateGrants adds the active Access Requests in a file written by dump-requests to the graph.
+func addCommonFateGrants(g accessgraph.Graph, m accessgraph.Model, requestsFile string, mappers *requests.Registry) error {
+	clio.Infof("loading active Access Requests in Common Fate from %s", requestsFile)
+
+	accessRequests, err := loadAccessRequests(requestsFile)
+	if err != nil {
+		return err
+	}
+	return addAccessRequestGrants(g, m, accessRequests, mappers)
+}
+
+// addAccessRequestGrants adds the grants of active Access Requests to the graph.
+func addAccessRequestGrants(g accessgraph.Graph, m accessgraph.Model, accessRequests []requests.Entry, mappers *requests.Registry) error {
+	var grants []accessgraph.Grant
+
+	for _, req := range accessRequests {
+		assignments := mappers.AccountAssignments(req)
+		if len(assignments) == 0 {
+			continue
+		}
+		for i, a := range assignments {
+			id := req.Request.ID
+			if len(assignments) > 1 {
+				// requests granting several assignments have a grant for each
+				id = fmt.Sprintf("%s/%d", id, i+1)
+			}
+
+			// the grant is in the instance holding the permission set, as permission set ARNs
+			// are unique to an instance. If it isn't in the report, the user is looked up in every instance.
+			var user msg.Resource
+			permissionSet, err := accessgraph.Find(g, m.PermissionSet, a.PermissionSetARN)
+			if err == nil {
+				user, err = accessgraph.FindIn(g, m.User, accessgraph.Instance(permissionSet), req.User.Email)
+			} else {
+				user, err = accessgraph.Find(g, m.User, req.User.Email)
+			}
+			if err != nil {
+				clio.Warnf("skipping Access Request %s: %s", id, err)
+				continue
+			}
+
+			grants = append(grants, accessgraph.Grant{
+				ID:              id,
+				Instance:        accessgraph.Instance(user),
+				UserID:          user.ID,
+				AccountID:       a.AccountID,
+				PermissionSetID: a.PermissionSetARN,
+			})
+		}
+	}
+
+	return accessgraph.AddGrants(g, m, grants)
+}
+
+// whyPath is a path granting access, as printed by the why command.
+type whyPath struct {
+	PermissionSet accessgraph.Hop      `json:"permissionSet"`
+	PathType      accessgraph.PathType `json:"pathType"`
+	Hops          []accessgraph.Hop    `json:"hops"`
+}
+
+var Why = cli.Command{
+	Name:  "why",
+	Usage: "Explain every path through which a user has access to an account",
+	Flags: []cli.Flag{
+		&cli.PathFlag{Name: "report", Required: true},
+		&cli.StringFlag{Name: "user", Required: true, Usage: "the user ID, name or email address"},
+		&cli.StringFlag{Name: "account", Required: true, Usage: "the account ID or name"},
+		&cli.StringFlag{Name: "permission-set", Usage: "only show paths granting this permission set (ARN or name)"},
+		&cli.PathFlag{Name: "requests", Usage: "active Access Requests written by the dump-requests command"},
+		targetMappingsFlag,
+		&cli.StringFlag{Name: "format", Value: "text", Usage: "the output format (text or json)"},
+	},
+	Action: func(c *cli.Context) error {
+		format := c.String("format")
+		if format != "text" && format != "json" {
+			return fmt.Errorf("unsupported format %q: must be text or json", format)
+		}
+
+		g, err := loadReportGraph(c.Path("report"))
+		if err != nil {
+			return err
+		}
+
+		model := accessgraph.AWSSSO
+
+		if requestsFile := c.Path("requests"); requestsFile != "" {
+			mappers, err := loadMappers(c)
+			if err != nil {
+				return err
+			}
+			err = addCommonFateGrants(g, model, requestsFile, mappers)
+			if err != nil {
+				return err
+			}
+		}
+
+		user, err := accessgraph.Find(g, model.User, c.String("user"))
+		if err != nil {
+			return err
+		}
+
+		// the account and permission set are in the same instance as the user
+		instance := accessgraph.Instance(user)
+
+		account, err := accessgraph.FindIn(g, model.Account, instance, c.String("account"))
+		if err != nil {
+			return err
+		}
+
+		var permissionSetID string
+		if ps := c.String("permission-set"); ps != "" {
+			permissionSet, err := accessgraph.FindIn(g, model.PermissionSet, instance, ps)
+			if err != nil {
+				return err
+			}
+			permissionSetID = permissionSet.ID
+		}
+
+		access, err := accessgraph.Why(g, model, accessgraph.Hash(user), account.ID, permissionSetID)
+		if err != nil {
+			return err
+		}
+
+		var paths []whyPath
+		for _, a := range access {
+			paths = append(paths, whyPath{
+				PermissionSet: accessgraph.Hops(g, []string{a.PermissionSetKey(model)})[0],
+				PathType:      a.PathType,
+				Hops:          accessgraph.Hops(g, a.Path),
+			})
+		}
+
+		if format == "json" {
+			enc := json.NewEncoder(os.Stdout)
+			enc.SetIndent("", "  ")
+			return enc.Encode(paths)
+		}
+
+		if len(paths) == 0 {
+			clio.Infof("%s has no access to %s", accessgraph.Label(user), accessgraph.Label(account))
+			return nil
+		}
+
+		fmt.Printf("%s has access to %s through %d paths:\n\n", accessgraph.Label(user), accessgraph.Label(account), len(paths))
+
+		for i, p := range paths {
+			fmt.Printf("%d. %s via %s\n", i+1, p.PermissionSet, p.PathType)
+			var hops []string
+			for _, h := range p.Hops {
+				hops = append(hops, h.String())
+			}
+			fmt.Printf("   %s\n\n", strings.Join(hops, "\n   -> "))
+		}
+
+		return nil
+	},
+}
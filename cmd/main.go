@@ -17,7 +17,7 @@ func main() {
 		Writer:    os.Stderr,
 		Usage:     "https://commonfate.io",
 		UsageText: "access-inspector [options] [command]",
-		Commands:  []*cli.Command{&command.Scan, &command.Analyze, &command.DumpRequests},
+		Commands:  []*cli.Command{&command.Scan, &command.Analyze, &command.DumpRequests, &command.HTMLReport, &command.Usage, &command.Recommend, &command.AnalyzeGraph, &command.EffectiveAccess, &command.Why, &command.BlastRadius, &command.ToxicCombinations, &command.Query, &command.Explore, &command.Serve, &command.Stats, &command.ImportRequests, &command.Remediate},
 	}
 	err := app.Run(os.Args)
 	if err != nil {
@@ -8,12 +8,14 @@ require (
 	github.com/common-fate/clio v1.1.0
 	github.com/common-fate/common-fate v0.15.0-alpha1.0.20230313123710-ce52f67312c9
 	github.com/common-fate/provider-registry-sdk-go v0.17.5
+	github.com/go-chi/chi/v5 v5.0.7
 	github.com/jmoiron/sqlx v1.3.5
 	github.com/joho/godotenv v1.5.1
 	github.com/mattn/go-sqlite3 v1.14.16
 	github.com/pkg/errors v0.9.1
 	github.com/urfave/cli/v2 v2.24.1
 	golang.org/x/sync v0.1.0
+	golang.org/x/term v0.5.0
 )
 
 require (
@@ -31,7 +33,6 @@ require (
 	github.com/segmentio/ksuid v1.0.4 // indirect
 	golang.org/x/net v0.7.0 // indirect
 	golang.org/x/oauth2 v0.4.0 // indirect
-	golang.org/x/term v0.5.0 // indirect
 	google.golang.org/appengine v1.6.7 // indirect
 	google.golang.org/protobuf v1.28.1 // indirect
 )
@@ -51,10 +52,9 @@ require (
 	github.com/aws/aws-sdk-go-v2/service/sts v1.18.7 // indirect
 	github.com/aws/smithy-go v1.13.5 // indirect
 	github.com/cpuguy83/go-md2man/v2 v2.0.2 // indirect
-	github.com/deepmap/oapi-codegen v1.11.0 // indirect
+	github.com/deepmap/oapi-codegen v1.11.0
 	github.com/dominikbraun/graph v0.16.2
 	github.com/getkin/kin-openapi v0.107.0 // indirect
-	github.com/go-chi/chi/v5 v5.0.7 // indirect
 	github.com/go-openapi/jsonpointer v0.19.5 // indirect
 	github.com/go-openapi/swag v0.22.0 // indirect
 	github.com/google/uuid v1.3.0 // indirect
@@ -0,0 +1,139 @@
+// Package reporttest writes reports for tests. The reports are written with the same
+// functions as the scan command, so that they have the instance column, the composite
+// primary keys, the views and the entitlement table of a scanned report.
+package reporttest
+
+import (
+	"encoding/json"
+	"path/filepath"
+	"testing"
+
+	"github.com/common-fate/access-inspector/pkg/report"
+	"github.com/common-fate/provider-registry-sdk-go/pkg/msg"
+	"github.com/common-fate/provider-registry-sdk-go/pkg/providerregistrysdk"
+	"github.com/jmoiron/sqlx"
+	_ "github.com/mattn/go-sqlite3"
+)
+
+// AWSDescribe is the describe data of the AWS provider.
+const AWSDescribe = `{"config": {"sso_instance_arn": "arn:aws:sso:::instance/ssoins-1", "sso_region": "us-east-1"}, "healthy": true,
+"provider": {"name": "aws", "publisher": "common-fate", "version": "v0.4.0"},
+"schema": {"resources": {"types": {
+	"User": {"type": "object", "properties": {"id": {"type": "string"}, "data": {"email": {"type": "string"}}}},
+	"Group": {"type": "object", "properties": {"id": {"type": "string"}, "data": {"description": {"type": "string"}}}},
+	"Account": {"type": "object", "properties": {"id": {"type": "string"}, "data": {"tags": {"type": "object"}}}},
+	"PermissionSet": {"type": "object", "properties": {"id": {"type": "string"}, "data": {"managed_policies": {"type": "array"}, "inline_policy": {"type": "string"}}}},
+	"AccountAssignment": {"type": "object", "properties": {"id": {"type": "string"}, "data": {"account": {"type": "string", "relation": "Account"}, "permission_set": {"type": "string", "relation": "PermissionSet"}, "user": {"type": "string", "relation": "User"}, "group": {"type": "string", "relation": "Group"}}}},
+	"GroupMembership": {"type": "object", "properties": {"id": {"type": "string"}, "data": {"user": {"type": "string", "relation": "User"}, "group": {"type": "string", "relation": "Group"}}}}
+}}}}`
+
+// Instance is a scanned provider instance and the resources loaded from it.
+type Instance struct {
+	Name      string
+	Resources []msg.Resource
+}
+
+// Default is an instance scanned from the PROVIDER_CONFIG_* environment variables, as when
+// the scan command isn't given an instances file.
+func Default(resources ...msg.Resource) Instance {
+	return Instance{Name: report.DefaultInstance, Resources: resources}
+}
+
+func describe(t testing.TB) providerregistrysdk.DescribeResponse {
+	t.Helper()
+	var d providerregistrysdk.DescribeResponse
+	err := json.Unmarshal([]byte(AWSDescribe), &d)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return d
+}
+
+// Write writes a report of AWS SSO instances to path.
+func Write(t testing.TB, path string, instances ...Instance) {
+	t.Helper()
+	db, err := sqlx.Open("sqlite3", "file:"+path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+
+	d := describe(t)
+	err = report.CreateTables(db, d)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, instance := range instances {
+		err = report.AddInstance(db, instance.Name, d)
+		if err != nil {
+			t.Fatal(err)
+		}
+		err = report.InsertResources(db, d, instance.Name, instance.Resources)
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+	err = report.CreateViews(db)
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = report.CreateEntitlements(db)
+	if err != nil {
+		t.Fatal(err)
+	}
+}
+
+// New writes a report of AWS SSO instances to a temporary directory and opens it.
+func New(t testing.TB, instances ...Instance) *sqlx.DB {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), "report.db")
+	Write(t, path, instances...)
+	db, err := sqlx.Open("sqlite3", "file:"+path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { db.Close() })
+	return db
+}
+
+// User is an identity store user.
+func User(id, name, email string) msg.Resource {
+	return msg.Resource{Type: "User", ID: id, Name: name, Data: map[string]any{"email": email}}
+}
+
+// Group is an identity store group.
+func Group(id, name string) msg.Resource {
+	return msg.Resource{Type: "Group", ID: id, Name: name, Data: map[string]any{"description": ""}}
+}
+
+// Account is an AWS account. Tags may be nil.
+func Account(id, name string, tags map[string]string) msg.Resource {
+	data := map[string]any{}
+	if tags != nil {
+		data["tags"] = tags
+	}
+	return msg.Resource{Type: "Account", ID: id, Name: name, Data: data}
+}
+
+// PermissionSet is a permission set with AWS managed policies attached.
+func PermissionSet(arn, name string, managedPolicies ...string) msg.Resource {
+	if managedPolicies == nil {
+		managedPolicies = []string{}
+	}
+	return msg.Resource{Type: "PermissionSet", ID: arn, Name: name, Data: map[string]any{"managed_policies": managedPolicies, "inline_policy": ""}}
+}
+
+// UserAssignment assigns a permission set in an account to a user.
+func UserAssignment(id, account, permissionSet, user string) msg.Resource {
+	return msg.Resource{Type: "AccountAssignment", ID: id, Data: map[string]any{"account": account, "permission_set": permissionSet, "user": user}}
+}
+
+// GroupAssignment assigns a permission set in an account to a group.
+func GroupAssignment(id, account, permissionSet, group string) msg.Resource {
+	return msg.Resource{Type: "AccountAssignment", ID: id, Data: map[string]any{"account": account, "permission_set": permissionSet, "group": group}}
+}
+
+// Membership adds a user to a group.
+func Membership(id, user, group string) msg.Resource {
+	return msg.Resource{Type: "GroupMembership", ID: id, Data: map[string]any{"user": user, "group": group}}
+}
@@ -0,0 +1,183 @@
+// Package accessgraph builds a graph of provider resources, where edges connect
+// resources to the resources they reference through relation fields in the provider schema.
+package accessgraph
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/common-fate/access-inspector/pkg/report"
+	"github.com/common-fate/clio"
+	"github.com/common-fate/provider-registry-sdk-go/pkg/msg"
+	"github.com/common-fate/provider-registry-sdk-go/pkg/providerregistrysdk"
+	"github.com/dominikbraun/graph"
+	"github.com/jmoiron/sqlx"
+	"github.com/pkg/errors"
+)
+
+// Graph is a directed graph of resources. An edge from A to B means
+// that resource A has a relation field referencing resource B.
+type Graph = graph.Graph[string, msg.Resource]
+
+// Hash returns the vertex key for a resource.
+func Hash(n msg.Resource) string {
+	return Key(n.Type, Instance(n), n.ID)
+}
+
+// Key returns the vertex key of a resource in an instance. Resources in the default instance
+// are keyed by <type>/<ID>, and resources in other instances by <type>@<instance>/<ID>, so
+// that resources with the same ID in different instances are different vertices.
+func Key(resourceType, instance, id string) string {
+	if instance == "" || instance == report.DefaultInstance {
+		return resourceType + "/" + id
+	}
+	return resourceType + "@" + instance + "/" + id
+}
+
+// SplitKey returns the type, instance and ID of a vertex key. The instance is empty
+// for resources in the default instance.
+func SplitKey(key string) (resourceType, instance, id string) {
+	head, id, _ := strings.Cut(key, "/")
+	resourceType, instance, _ = strings.Cut(head, "@")
+	return resourceType, instance, id
+}
+
+// Instance returns the instance a resource was scanned from, or an empty string
+// for resources in the default instance.
+func Instance(r msg.Resource) string {
+	instance, _ := r.Data[report.InstanceColumn].(string)
+	if instance == report.DefaultInstance {
+		return ""
+	}
+	return instance
+}
+
+// inInstance returns data holding the instance of a resource, for resources it references.
+func inInstance(instance string) map[string]any {
+	if instance == "" {
+		return nil
+	}
+	return map[string]any{report.InstanceColumn: instance}
+}
+
+// Label returns a human-readable label for a resource.
+func Label(r msg.Resource) string {
+	if r.Name == "" {
+		return Hash(r)
+	}
+	return Key(r.Type, Instance(r), r.Name)
+}
+
+// New creates an empty graph.
+func New() Graph {
+	return graph.New(Hash, graph.Directed())
+}
+
+// Unresolved is a relation field value which couldn't be connected in the graph.
+type Unresolved struct {
+	// From is the vertex key of the resource with the relation field.
+	From  string
+	Field string
+	// Reference is set if the value was a valid reference to a resource which isn't in the graph.
+	Reference *report.Reference
+	Err       error
+}
+
+func (u Unresolved) String() string {
+	if u.Reference != nil {
+		return fmt.Sprintf("%s field %s references %s/%s which was not found", u.From, u.Field, u.Reference.Type, u.Reference.ID)
+	}
+	return fmt.Sprintf("%s field %s: %s", u.From, u.Field, u.Err)
+}
+
+// Build creates a graph from resources, using the relation fields in the provider schema
+// to connect them. Relation fields may hold a single ID, an array of IDs, or objects holding
+// a type and an ID. Relations which can't be resolved to a resource are returned rather than
+// causing the build to fail, and references to missing resources are still added to the graph.
+func Build(describe providerregistrysdk.DescribeResponse, resources []msg.Resource) (Graph, []Unresolved, error) {
+	g := New()
+	var unresolved []Unresolved
+
+	// add resources in a consistent order so that exports are stable
+	sort.Slice(resources, func(i, j int) bool {
+		return Hash(resources[i]) < Hash(resources[j])
+	})
+
+	// add every resource first, so that related resources are stored with their names and data
+	for _, r := range resources {
+		clio.Debugw("adding node", "resource", r)
+		err := g.AddVertex(r, graph.VertexAttribute("label", Label(r)))
+		// ok if the vertex already exists as resources may be returned by more than one loader
+		if err != nil && err != graph.ErrVertexAlreadyExists {
+			return nil, nil, err
+		}
+	}
+
+	for _, r := range resources {
+		fields, err := report.DataFields(describe, r.Type)
+		if err != nil {
+			return nil, nil, err
+		}
+
+		// iterate over the fields in a consistent order so that unresolved relations are reported consistently
+		var keys []string
+		for k := range r.Data {
+			keys = append(keys, k)
+		}
+		sort.Strings(keys)
+
+		for _, k := range keys {
+			relationType, ok := report.RelationType(fields[k])
+			if !ok {
+				continue
+			}
+
+			refs, errs := report.References(relationType, r.Data[k])
+			for _, err := range errs {
+				unresolved = append(unresolved, Unresolved{From: Hash(r), Field: k, Err: err})
+			}
+
+			for _, ref := range refs {
+				// create an edge to the related field, which is in the same instance
+				to := msg.Resource{
+					Type: ref.Type,
+					ID:   ref.ID,
+					Data: inInstance(Instance(r)),
+				}
+
+				err = g.AddVertex(to, graph.VertexAttribute("label", Label(to)))
+				if err == nil {
+					ref := ref
+					unresolved = append(unresolved, Unresolved{From: Hash(r), Field: k, Reference: &ref})
+				} else if err != graph.ErrVertexAlreadyExists {
+					return nil, nil, errors.Wrap(err, "adding connected resource vertex")
+				}
+
+				err = g.AddEdge(Hash(r), Hash(to), graph.EdgeAttribute("label", k))
+				if err != nil && err != graph.ErrEdgeAlreadyExists {
+					return nil, nil, err
+				}
+			}
+		}
+	}
+
+	return g, unresolved, nil
+}
+
+// FromReport builds the graph from the resources stored in a report.
+func FromReport(db *sqlx.DB) (Graph, []Unresolved, error) {
+	describe, err := report.Describe(db)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	resources, err := report.Resources(db, describe)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	clio.Debugw("loaded resources from report", "count", len(resources))
+
+	return Build(describe, resources)
+}
@@ -0,0 +1,75 @@
+package accessgraph_test
+
+import (
+	"sort"
+	"testing"
+
+	"github.com/common-fate/access-inspector/internal/reporttest"
+	"github.com/common-fate/access-inspector/pkg/accessgraph"
+	"github.com/common-fate/provider-registry-sdk-go/pkg/msg"
+)
+
+// TestInstancesAreSeparateVertices scans two instances which use the same IDs for different
+// users, groups and assignments, and checks that they aren't merged in the graph.
+func TestInstancesAreSeparateVertices(t *testing.T) {
+	db := reporttest.New(t,
+		reporttest.Instance{Name: "org-a", Resources: []msg.Resource{
+			reporttest.User("u-1", "Alice", "alice@a.example.com"),
+			reporttest.Group("g-1", "admins"),
+			reporttest.Account("111111111111", "prod", nil),
+			reporttest.PermissionSet("ps-admin", "AdministratorAccess"),
+			reporttest.GroupAssignment("aa-1", "111111111111", "ps-admin", "g-1"),
+			reporttest.Membership("gm-1", "u-1", "g-1"),
+		}},
+		reporttest.Instance{Name: "org-b", Resources: []msg.Resource{
+			reporttest.User("u-1", "Bob", "bob@b.example.com"),
+			reporttest.Group("g-1", "developers"),
+			reporttest.Account("222222222222", "sandbox", nil),
+			reporttest.PermissionSet("ps-ro", "ReadOnlyAccess"),
+			reporttest.GroupAssignment("aa-1", "222222222222", "ps-ro", "g-1"),
+			reporttest.Membership("gm-1", "u-1", "g-1"),
+		}},
+	)
+
+	g, unresolved, err := accessgraph.FromReport(db)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(unresolved) != 0 {
+		t.Fatalf("got unresolved relations %v", unresolved)
+	}
+
+	users, err := accessgraph.Lookup(g, accessgraph.AWSSSO.User, "u-1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []string{"User@org-a/u-1", "User@org-b/u-1"}
+	if len(users) != len(want) || users[0] != want[0] || users[1] != want[1] {
+		t.Fatalf("got user vertices %v, want %v", users, want)
+	}
+
+	access, err := accessgraph.AllEffectiveAccess(g, accessgraph.AWSSSO)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var got []string
+	for _, a := range access {
+		got = append(got, a.Key())
+	}
+	sort.Strings(got)
+	wantAccess := []string{"org-a+u-1+111111111111+ps-admin", "org-b+u-1+222222222222+ps-ro"}
+	if len(got) != len(wantAccess) || got[0] != wantAccess[0] || got[1] != wantAccess[1] {
+		t.Errorf("got effective access %v, want %v", got, wantAccess)
+	}
+
+	v, err := accessgraph.FindVertex(g, "User@org-b/bob@b.example.com")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if v != "User@org-b/u-1" {
+		t.Errorf("got vertex %s for a user in org-b, want User@org-b/u-1", v)
+	}
+	if _, err := accessgraph.FindVertex(g, "User/u-1"); err == nil {
+		t.Error("expected an error finding an ID which is in both instances")
+	}
+}
@@ -0,0 +1,103 @@
+package accessgraph
+
+import (
+	"sort"
+
+	"github.com/pkg/errors"
+)
+
+// AccountImpact summarises the access to one account which passes through a resource.
+type AccountImpact struct {
+	Account        Hop `json:"account"`
+	Users          int `json:"users"`
+	PermissionSets int `json:"permissionSets"`
+	Paths          int `json:"paths"`
+}
+
+// BlastRadius is everything which gets access through a resource.
+type BlastRadius struct {
+	Resource       Hop             `json:"resource"`
+	Users          []Hop           `json:"users"`
+	Accounts       []Hop           `json:"accounts"`
+	PermissionSets []Hop           `json:"permissionSets"`
+	ByAccount      []AccountImpact `json:"byAccount"`
+	// Access is every path granting access which passes through the resource.
+	Access []Access `json:"access"`
+}
+
+// ComputeBlastRadius finds every user, account and permission set affected by a resource.
+// It is the reverse of effective access: a path is affected if the resource is on it, or if
+// the resource is the account or permission set being granted.
+func ComputeBlastRadius(g Graph, m Model, vertex string) (BlastRadius, error) {
+	if _, err := g.Vertex(vertex); err != nil {
+		return BlastRadius{}, errors.Wrapf(err, "finding %s", vertex)
+	}
+
+	all, err := AllEffectiveAccess(g, m)
+	if err != nil {
+		return BlastRadius{}, err
+	}
+
+	br := BlastRadius{Resource: Hops(g, []string{vertex})[0]}
+
+	users := map[string]bool{}
+	accounts := map[string]bool{}
+	permissionSets := map[string]bool{}
+	type accountTotals struct {
+		users, permissionSets map[string]bool
+		paths                 int
+	}
+	byAccount := map[string]*accountTotals{}
+
+	for _, a := range all {
+		account := a.AccountKey(m)
+		permissionSet := a.PermissionSetKey(m)
+		if !contains(a.Path, vertex) && account != vertex && permissionSet != vertex {
+			continue
+		}
+		br.Access = append(br.Access, a)
+
+		users[a.UserKey(m)] = true
+		accounts[account] = true
+		permissionSets[permissionSet] = true
+
+		t, ok := byAccount[account]
+		if !ok {
+			t = &accountTotals{users: map[string]bool{}, permissionSets: map[string]bool{}}
+			byAccount[account] = t
+		}
+		t.users[a.UserKey(m)] = true
+		t.permissionSets[permissionSet] = true
+		t.paths++
+	}
+
+	br.Users = Hops(g, sortedKeys(users))
+	br.Accounts = Hops(g, sortedKeys(accounts))
+	br.PermissionSets = Hops(g, sortedKeys(permissionSets))
+
+	for _, account := range sortedKeys(accounts) {
+		t := byAccount[account]
+		br.ByAccount = append(br.ByAccount, AccountImpact{
+			Account:        Hops(g, []string{account})[0],
+			Users:          len(t.users),
+			PermissionSets: len(t.permissionSets),
+			Paths:          t.paths,
+		})
+	}
+
+	// list the most affected accounts first
+	sort.SliceStable(br.ByAccount, func(i, j int) bool {
+		return br.ByAccount[i].Users > br.ByAccount[j].Users
+	})
+
+	return br, nil
+}
+
+func sortedKeys(m map[string]bool) []string {
+	var keys []string
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
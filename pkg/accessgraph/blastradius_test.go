@@ -0,0 +1,136 @@
+package accessgraph_test
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/common-fate/access-inspector/pkg/accessgraph"
+)
+
+// fanOut is the devs group with three members, one of them through the nested eng group,
+// assigned to two accounts. alice also has a direct assignment to prod.
+func fanOut(t *testing.T) accessgraph.Graph {
+	return graphOf(t, edges(
+		membership("gm-alice", "user", "User/alice", "Group/devs"),
+		membership("gm-bob", "user", "User/bob", "Group/devs"),
+		membership("gm-carol", "user", "User/carol", "Group/eng"),
+		membership("gm-eng", "member", "Group/eng", "Group/devs"),
+		assignment("aa-devs-prod", "group", "Group/devs", "prod", "admin"),
+		assignment("aa-devs-dev", "group", "Group/devs", "dev", "read-only"),
+		assignment("aa-alice-prod", "user", "User/alice", "prod", "admin"),
+		assignment("aa-dave-sandbox", "user", "User/dave", "sandbox", "admin"),
+	)...)
+}
+
+func ids(hops []accessgraph.Hop) []string {
+	var s []string
+	for _, h := range hops {
+		s = append(s, h.ID)
+	}
+	return s
+}
+
+func TestBlastRadius(t *testing.T) {
+	tests := []struct {
+		name               string
+		vertex             string
+		wantUsers          []string
+		wantAccounts       []string
+		wantPermissionSets []string
+		wantByAccount      []accessgraph.AccountImpact
+		wantPaths          int
+	}{
+		{
+			// every member of the group, including the members of nested groups
+			name:               "group",
+			vertex:             "Group/devs",
+			wantUsers:          []string{"alice", "bob", "carol"},
+			wantAccounts:       []string{"dev", "prod"},
+			wantPermissionSets: []string{"admin", "read-only"},
+			wantByAccount: []accessgraph.AccountImpact{
+				{Account: accessgraph.Hop{Type: "Account", ID: "dev", Name: "dev"}, Users: 3, PermissionSets: 1, Paths: 3},
+				{Account: accessgraph.Hop{Type: "Account", ID: "prod", Name: "prod"}, Users: 3, PermissionSets: 1, Paths: 3},
+			},
+			wantPaths: 6,
+		},
+		{
+			name:               "nested group",
+			vertex:             "Group/eng",
+			wantUsers:          []string{"carol"},
+			wantAccounts:       []string{"dev", "prod"},
+			wantPermissionSets: []string{"admin", "read-only"},
+			wantByAccount: []accessgraph.AccountImpact{
+				{Account: accessgraph.Hop{Type: "Account", ID: "dev", Name: "dev"}, Users: 1, PermissionSets: 1, Paths: 1},
+				{Account: accessgraph.Hop{Type: "Account", ID: "prod", Name: "prod"}, Users: 1, PermissionSets: 1, Paths: 1},
+			},
+			wantPaths: 2,
+		},
+		{
+			// alice reaches prod directly and through devs, and is only counted once
+			name:               "account",
+			vertex:             "Account/prod",
+			wantUsers:          []string{"alice", "bob", "carol"},
+			wantAccounts:       []string{"prod"},
+			wantPermissionSets: []string{"admin"},
+			wantByAccount: []accessgraph.AccountImpact{
+				{Account: accessgraph.Hop{Type: "Account", ID: "prod", Name: "prod"}, Users: 3, PermissionSets: 1, Paths: 4},
+			},
+			wantPaths: 4,
+		},
+		{
+			// the most affected accounts are listed first
+			name:               "permission set",
+			vertex:             "PermissionSet/admin",
+			wantUsers:          []string{"alice", "bob", "carol", "dave"},
+			wantAccounts:       []string{"prod", "sandbox"},
+			wantPermissionSets: []string{"admin"},
+			wantByAccount: []accessgraph.AccountImpact{
+				{Account: accessgraph.Hop{Type: "Account", ID: "prod", Name: "prod"}, Users: 3, PermissionSets: 1, Paths: 4},
+				{Account: accessgraph.Hop{Type: "Account", ID: "sandbox", Name: "sandbox"}, Users: 1, PermissionSets: 1, Paths: 1},
+			},
+			wantPaths: 5,
+		},
+		{
+			name:               "user",
+			vertex:             "User/bob",
+			wantUsers:          []string{"bob"},
+			wantAccounts:       []string{"dev", "prod"},
+			wantPermissionSets: []string{"admin", "read-only"},
+			wantByAccount: []accessgraph.AccountImpact{
+				{Account: accessgraph.Hop{Type: "Account", ID: "dev", Name: "dev"}, Users: 1, PermissionSets: 1, Paths: 1},
+				{Account: accessgraph.Hop{Type: "Account", ID: "prod", Name: "prod"}, Users: 1, PermissionSets: 1, Paths: 1},
+			},
+			wantPaths: 2,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			br, err := accessgraph.ComputeBlastRadius(fanOut(t), accessgraph.AWSSSO, tt.vertex)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got := ids(br.Users); !reflect.DeepEqual(got, tt.wantUsers) {
+				t.Errorf("got users %v, want %v", got, tt.wantUsers)
+			}
+			if got := ids(br.Accounts); !reflect.DeepEqual(got, tt.wantAccounts) {
+				t.Errorf("got accounts %v, want %v", got, tt.wantAccounts)
+			}
+			if got := ids(br.PermissionSets); !reflect.DeepEqual(got, tt.wantPermissionSets) {
+				t.Errorf("got permission sets %v, want %v", got, tt.wantPermissionSets)
+			}
+			if !reflect.DeepEqual(br.ByAccount, tt.wantByAccount) {
+				t.Errorf("got by account %+v, want %+v", br.ByAccount, tt.wantByAccount)
+			}
+			if len(br.Access) != tt.wantPaths {
+				t.Errorf("got %d paths, want %d", len(br.Access), tt.wantPaths)
+			}
+		})
+	}
+}
+
+func TestBlastRadiusUnknownResource(t *testing.T) {
+	_, err := accessgraph.ComputeBlastRadius(fanOut(t), accessgraph.AWSSSO, "Group/admins")
+	if err == nil {
+		t.Error("expected an error for a resource which isn't in the graph")
+	}
+}
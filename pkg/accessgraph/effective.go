@@ -0,0 +1,327 @@
+package accessgraph
+
+import (
+	"sort"
+	"strings"
+
+	"github.com/jmoiron/sqlx"
+	"github.com/pkg/errors"
+)
+
+// Model describes which resource types in the graph represent principals, and what they can be granted.
+type Model struct {
+	User          string
+	Group         string
+	Account       string
+	PermissionSet string
+	// MembershipGroupField is the relation field on a membership resource which references
+	// the group being joined. Other principals referenced by the membership are its members.
+	MembershipGroupField string
+	// Grant is the resource type used for just-in-time grants added with AddGrants.
+	Grant string
+}
+
+// AWSSSO is the model used by the AWS IAM Identity Center provider.
+var AWSSSO = Model{
+	User:                 "User",
+	Group:                "Group",
+	Account:              "Account",
+	PermissionSet:        "PermissionSet",
+	MembershipGroupField: "group",
+	Grant:                "CommonFateGrant",
+}
+
+// PathType describes how a user reaches an entitlement.
+type PathType string
+
+const (
+	// PathDirect is an entitlement assigned directly to the user.
+	PathDirect PathType = "direct"
+	// PathGroup is an entitlement assigned to a group the user is a member of.
+	PathGroup PathType = "group"
+	// PathNestedGroup is an entitlement assigned to a group which the user is a member of
+	// through one or more other groups.
+	PathNestedGroup PathType = "nested-group"
+	// PathJIT is an entitlement granted just-in-time through an active Common Fate Access Request.
+	PathJIT PathType = "jit"
+)
+
+// Access is a single path through the graph which grants a user a permission set in an account.
+type Access struct {
+	UserID          string   `json:"userId" db:"user"`
+	AccountID       string   `json:"accountId" db:"account"`
+	PermissionSetID string   `json:"permissionSetId" db:"permission_set"`
+	PathType        PathType `json:"pathType" db:"path_type"`
+	// AssignmentID is the ID of the resource which assigns the permission set.
+	AssignmentID string `json:"assignmentId" db:"assignment"`
+	// Instance is the instance holding the user and the assignment. It's empty for the default instance.
+	Instance string `json:"instance,omitempty" db:"instance"`
+	// Path is the vertex keys from the user to the assignment, including any
+	// memberships and groups along the way.
+	Path []string `json:"path" db:"-"`
+}
+
+// Groups returns the vertex keys of the groups on the path, from the group the user
+// is directly a member of to the group the access is assigned to.
+func (a Access) Groups(m Model) []string {
+	var groups []string
+	for _, v := range a.Path {
+		if isType(v, m.Group) {
+			groups = append(groups, v)
+		}
+	}
+	return groups
+}
+
+// Key identifies the user, account and permission set, ignoring the path.
+func (a Access) Key() string {
+	key := a.UserID + "+" + a.AccountID + "+" + a.PermissionSetID
+	if a.Instance != "" {
+		key = a.Instance + "+" + key
+	}
+	return key
+}
+
+// UserKey returns the vertex key of the user.
+func (a Access) UserKey(m Model) string {
+	return Key(m.User, a.Instance, a.UserID)
+}
+
+// AccountKey returns the vertex key of the account.
+func (a Access) AccountKey(m Model) string {
+	return Key(m.Account, a.Instance, a.AccountID)
+}
+
+// PermissionSetKey returns the vertex key of the permission set.
+func (a Access) PermissionSetKey(m Model) string {
+	return Key(m.PermissionSet, a.Instance, a.PermissionSetID)
+}
+
+// walker finds the entitlements reachable from principals in a graph.
+type walker struct {
+	g     Graph
+	m     Model
+	preds map[string]map[string]bool
+	// succs maps a vertex to its successors, keyed by the relation field of the edge
+	succs map[string]map[string]string
+}
+
+func newWalker(g Graph, m Model) (*walker, error) {
+	adjacency, err := g.AdjacencyMap()
+	if err != nil {
+		return nil, err
+	}
+	w := walker{g: g, m: m, preds: map[string]map[string]bool{}, succs: map[string]map[string]string{}}
+	for source, targets := range adjacency {
+		w.succs[source] = map[string]string{}
+		for target, e := range targets {
+			w.succs[source][target] = e.Properties.Attributes["label"]
+			if w.preds[target] == nil {
+				w.preds[target] = map[string]bool{}
+			}
+			w.preds[target][source] = true
+		}
+	}
+	return &w, nil
+}
+
+func isType(hash string, t string) bool {
+	resourceType, _, _ := SplitKey(hash)
+	return resourceType == t
+}
+
+func idOf(hash string) string {
+	_, _, id := SplitKey(hash)
+	return id
+}
+
+func instanceOf(hash string) string {
+	_, instance, _ := SplitKey(hash)
+	return instance
+}
+
+// assignment returns the accounts and permission sets granted by a vertex.
+// ok is false if the vertex doesn't reference both an account and a permission set.
+func (w *walker) assignment(v string) (accounts []string, permissionSets []string, ok bool) {
+	for s := range w.succs[v] {
+		switch {
+		case isType(s, w.m.Account):
+			accounts = append(accounts, s)
+		case isType(s, w.m.PermissionSet):
+			permissionSets = append(permissionSets, s)
+		}
+	}
+	sort.Strings(accounts)
+	sort.Strings(permissionSets)
+	return accounts, permissionSets, len(accounts) > 0 && len(permissionSets) > 0
+}
+
+// joins returns the groups joined through a membership vertex.
+func (w *walker) joins(v string, member string) []string {
+	// the member must not be referenced as the group being joined
+	if w.succs[v][member] == w.m.MembershipGroupField {
+		return nil
+	}
+	var groups []string
+	for s, field := range w.succs[v] {
+		if field == w.m.MembershipGroupField && isType(s, w.m.Group) {
+			groups = append(groups, s)
+		}
+	}
+	sort.Strings(groups)
+	return groups
+}
+
+// walk finds every entitlement reachable from principal, where path is the path
+// from the user to the principal.
+func (w *walker) walk(user string, principal string, path []string, depth int, visit func(Access) bool) bool {
+	var preds []string
+	for p := range w.preds[principal] {
+		preds = append(preds, p)
+	}
+	sort.Strings(preds)
+
+	for _, p := range preds {
+		if accounts, permissionSets, ok := w.assignment(p); ok {
+			pathType := PathDirect
+			if isType(p, w.m.Grant) {
+				pathType = PathJIT
+			} else if depth == 1 {
+				pathType = PathGroup
+			} else if depth > 1 {
+				pathType = PathNestedGroup
+			}
+			for _, a := range accounts {
+				for _, ps := range permissionSets {
+					fullPath := append(append([]string{}, path...), p)
+					cont := visit(Access{
+						UserID:          idOf(user),
+						AccountID:       idOf(a),
+						PermissionSetID: idOf(ps),
+						PathType:        pathType,
+						AssignmentID:    idOf(p),
+						Instance:        instanceOf(user),
+						Path:            fullPath,
+					})
+					if !cont {
+						return false
+					}
+				}
+			}
+			continue
+		}
+
+		for _, group := range w.joins(p, principal) {
+			// don't follow cycles in nested group memberships
+			if contains(path, group) {
+				continue
+			}
+			next := append(append([]string{}, path...), p, group)
+			if !w.walk(user, group, next, depth+1, visit) {
+				return false
+			}
+		}
+	}
+	return true
+}
+
+func contains(s []string, v string) bool {
+	for _, x := range s {
+		if x == v {
+			return true
+		}
+	}
+	return false
+}
+
+// EffectiveAccess returns every path which grants the user access, whether directly
+// or through any chain of groups. If users in several instances have the ID, the
+// access of each of them is returned.
+func EffectiveAccess(g Graph, m Model, userID string) ([]Access, error) {
+	w, err := newWalker(g, m)
+	if err != nil {
+		return nil, err
+	}
+	users, err := Lookup(g, m.User, userID)
+	if err != nil {
+		return nil, err
+	}
+	var access []Access
+	for _, u := range users {
+		access = append(access, w.effectiveAccess(u)...)
+	}
+	return access, nil
+}
+
+// effectiveAccess returns the access of the user with a vertex key.
+func (w *walker) effectiveAccess(user string) []Access {
+	var access []Access
+	w.walk(user, user, []string{user}, 0, func(a Access) bool {
+		access = append(access, a)
+		return true
+	})
+	return access
+}
+
+// AllEffectiveAccess returns the effective access of every user in the graph.
+func AllEffectiveAccess(g Graph, m Model) ([]Access, error) {
+	w, err := newWalker(g, m)
+	if err != nil {
+		return nil, err
+	}
+
+	adjacency, err := g.AdjacencyMap()
+	if err != nil {
+		return nil, err
+	}
+
+	var users []string
+	for v := range adjacency {
+		if isType(v, m.User) {
+			users = append(users, v)
+		}
+	}
+	sort.Strings(users)
+
+	var access []Access
+	for _, u := range users {
+		access = append(access, w.effectiveAccess(u)...)
+	}
+	return access, nil
+}
+
+// EffectiveAccessTable is the name of the report table which effective access is saved to.
+const EffectiveAccessTable = "effective_access"
+
+// SaveEffectiveAccess replaces the effective_access table in a report.
+// The via column holds the IDs of the groups on the path, separated by " > ".
+func SaveEffectiveAccess(db *sqlx.DB, m Model, access []Access) error {
+	tx, err := db.Beginx()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	_, err = tx.Exec(`DROP TABLE IF EXISTS ` + EffectiveAccessTable)
+	if err != nil {
+		return err
+	}
+
+	_, err = tx.Exec(`CREATE TABLE ` + EffectiveAccessTable + ` ("user" TEXT, "account" TEXT, "permission_set" TEXT, "path_type" TEXT, "assignment" TEXT, "via" TEXT, "instance" TEXT)`)
+	if err != nil {
+		return errors.Wrapf(err, "creating table %s", EffectiveAccessTable)
+	}
+
+	for _, a := range access {
+		var via []string
+		for _, g := range a.Groups(m) {
+			via = append(via, idOf(g))
+		}
+		_, err = tx.Exec(`INSERT INTO `+EffectiveAccessTable+` ("user", "account", "permission_set", "path_type", "assignment", "via", "instance") VALUES ($1, $2, $3, $4, $5, $6, $7)`,
+			a.UserID, a.AccountID, a.PermissionSetID, a.PathType, a.AssignmentID, strings.Join(via, " > "), a.Instance)
+		if err != nil {
+			return errors.Wrapf(err, "inserting effective access %+v", a)
+		}
+	}
+	return tx.Commit()
+}
@@ -0,0 +1,225 @@
+package accessgraph_test
+
+import (
+	"path/filepath"
+	"reflect"
+	"sort"
+	"testing"
+
+	"github.com/common-fate/access-inspector/pkg/accessgraph"
+	"github.com/common-fate/provider-registry-sdk-go/pkg/msg"
+	"github.com/dominikbraun/graph"
+	"github.com/jmoiron/sqlx"
+	_ "github.com/mattn/go-sqlite3"
+)
+
+// edge is a relation field of the resource from, referencing the resource to.
+// Resources are given by their vertex keys.
+type edge struct {
+	from, field, to string
+}
+
+// graphOf builds a graph holding the resources connected by edges.
+func graphOf(t *testing.T, edges ...edge) accessgraph.Graph {
+	t.Helper()
+	g := accessgraph.New()
+	add := func(key string) {
+		resourceType, _, id := accessgraph.SplitKey(key)
+		err := g.AddVertex(msg.Resource{Type: resourceType, ID: id, Name: id})
+		if err != nil && err != graph.ErrVertexAlreadyExists {
+			t.Fatal(err)
+		}
+	}
+	for _, e := range edges {
+		add(e.from)
+		add(e.to)
+		err := g.AddEdge(e.from, e.to, graph.EdgeAttribute("label", e.field))
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+	return g
+}
+
+// assignment returns the edges of an account assignment to a principal.
+func assignment(id, principalField, principal, account, permissionSet string) []edge {
+	v := "AccountAssignment/" + id
+	return []edge{
+		{v, principalField, principal},
+		{v, "account", "Account/" + account},
+		{v, "permission_set", "PermissionSet/" + permissionSet},
+	}
+}
+
+// membership returns the edges of a membership adding a user or group to a group.
+func membership(id, memberField, member, group string) []edge {
+	v := "GroupMembership/" + id
+	return []edge{
+		{v, memberField, member},
+		{v, "group", group},
+	}
+}
+
+func edges(sets ...[]edge) []edge {
+	var all []edge
+	for _, s := range sets {
+		all = append(all, s...)
+	}
+	return all
+}
+
+// nestedGroups is alice, who is a member of devs, which is a member of platform.
+// Each of them has an assignment, and platform is also a member of devs, making a cycle.
+func nestedGroups(t *testing.T) accessgraph.Graph {
+	return graphOf(t, edges(
+		assignment("aa-direct", "user", "User/alice", "sandbox", "admin"),
+		membership("gm-alice", "user", "User/alice", "Group/devs"),
+		assignment("aa-devs", "group", "Group/devs", "dev", "power-user"),
+		membership("gm-devs", "member", "Group/devs", "Group/platform"),
+		assignment("aa-platform", "group", "Group/platform", "prod", "read-only"),
+		membership("gm-platform", "member", "Group/platform", "Group/devs"),
+	)...)
+}
+
+type accessRow struct {
+	Account, PermissionSet string
+	PathType               accessgraph.PathType
+	Assignment             string
+	Path                   []string
+}
+
+func rows(access []accessgraph.Access) []accessRow {
+	var got []accessRow
+	for _, a := range access {
+		got = append(got, accessRow{a.AccountID, a.PermissionSetID, a.PathType, a.AssignmentID, a.Path})
+	}
+	sort.Slice(got, func(i, j int) bool { return got[i].Assignment < got[j].Assignment })
+	return got
+}
+
+func TestEffectiveAccessThroughNestedGroups(t *testing.T) {
+	g := nestedGroups(t)
+
+	access, err := accessgraph.EffectiveAccess(g, accessgraph.AWSSSO, "alice")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// the cycle back from platform to devs isn't followed, so each assignment is reached once
+	want := []accessRow{
+		{"dev", "power-user", accessgraph.PathGroup, "aa-devs", []string{"User/alice", "GroupMembership/gm-alice", "Group/devs", "AccountAssignment/aa-devs"}},
+		{"sandbox", "admin", accessgraph.PathDirect, "aa-direct", []string{"User/alice", "AccountAssignment/aa-direct"}},
+		{"prod", "read-only", accessgraph.PathNestedGroup, "aa-platform", []string{"User/alice", "GroupMembership/gm-alice", "Group/devs", "GroupMembership/gm-devs", "Group/platform", "AccountAssignment/aa-platform"}},
+	}
+	if got := rows(access); !reflect.DeepEqual(got, want) {
+		t.Errorf("got access\n%+v\nwant\n%+v", got, want)
+	}
+}
+
+func TestEffectiveAccessGroupCycle(t *testing.T) {
+	// two groups which are members of each other, with the user in one of them
+	g := graphOf(t, edges(
+		membership("gm-alice", "user", "User/alice", "Group/a"),
+		membership("gm-a", "member", "Group/a", "Group/b"),
+		membership("gm-b", "member", "Group/b", "Group/a"),
+		assignment("aa-b", "group", "Group/b", "prod", "admin"),
+	)...)
+
+	access, err := accessgraph.EffectiveAccess(g, accessgraph.AWSSSO, "alice")
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []accessRow{
+		{"prod", "admin", accessgraph.PathNestedGroup, "aa-b", []string{"User/alice", "GroupMembership/gm-alice", "Group/a", "GroupMembership/gm-a", "Group/b", "AccountAssignment/aa-b"}},
+	}
+	if got := rows(access); !reflect.DeepEqual(got, want) {
+		t.Errorf("got access\n%+v\nwant\n%+v", got, want)
+	}
+}
+
+func TestEffectiveAccessJIT(t *testing.T) {
+	g := nestedGroups(t)
+
+	err := accessgraph.AddGrants(g, accessgraph.AWSSSO, []accessgraph.Grant{
+		{ID: "req-1", UserID: "alice", AccountID: "prod", PermissionSetID: "admin"},
+		// grants to users who aren't in the graph are added as well
+		{ID: "req-2", UserID: "bob", AccountID: "prod", PermissionSetID: "admin"},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	access, err := accessgraph.EffectiveAccess(g, accessgraph.AWSSSO, "alice")
+	if err != nil {
+		t.Fatal(err)
+	}
+	var jit []accessRow
+	for _, a := range rows(access) {
+		if a.PathType == accessgraph.PathJIT {
+			jit = append(jit, a)
+		}
+	}
+	want := []accessRow{
+		{"prod", "admin", accessgraph.PathJIT, "req-1", []string{"User/alice", "CommonFateGrant/req-1"}},
+	}
+	if !reflect.DeepEqual(jit, want) {
+		t.Errorf("got just-in-time access\n%+v\nwant\n%+v", jit, want)
+	}
+
+	all, err := accessgraph.AllEffectiveAccess(g, accessgraph.AWSSSO)
+	if err != nil {
+		t.Fatal(err)
+	}
+	users := map[string]int{}
+	for _, a := range all {
+		users[a.UserID]++
+	}
+	if !reflect.DeepEqual(users, map[string]int{"alice": 4, "bob": 1}) {
+		t.Errorf("got access per user %v, want 4 for alice and 1 for bob", users)
+	}
+}
+
+func TestSaveEffectiveAccess(t *testing.T) {
+	g := nestedGroups(t)
+	access, err := accessgraph.AllEffectiveAccess(g, accessgraph.AWSSSO)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	db, err := sqlx.Open("sqlite3", "file:"+filepath.Join(t.TempDir(), "report.db"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+
+	// saving twice replaces the table rather than adding to it
+	for i := 0; i < 2; i++ {
+		err = accessgraph.SaveEffectiveAccess(db, accessgraph.AWSSSO, access)
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	type row struct {
+		User          string `db:"user"`
+		Account       string `db:"account"`
+		PermissionSet string `db:"permission_set"`
+		PathType      string `db:"path_type"`
+		Assignment    string `db:"assignment"`
+		Via           string `db:"via"`
+		Instance      string `db:"instance"`
+	}
+	var got []row
+	err = db.Select(&got, `SELECT "user", "account", "permission_set", "path_type", "assignment", "via", "instance" FROM `+accessgraph.EffectiveAccessTable+` ORDER BY "assignment"`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []row{
+		{"alice", "dev", "power-user", "group", "aa-devs", "devs", ""},
+		{"alice", "sandbox", "admin", "direct", "aa-direct", "", ""},
+		{"alice", "prod", "read-only", "nested-group", "aa-platform", "devs > platform", ""},
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("got rows\n%+v\nwant\n%+v", got, want)
+	}
+}
@@ -0,0 +1,349 @@
+package accessgraph
+
+import (
+	"encoding/json"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/common-fate/provider-registry-sdk-go/pkg/msg"
+)
+
+// Format is a graph export format.
+type Format string
+
+const (
+	FormatDOT     Format = "dot"
+	FormatGraphML Format = "graphml"
+	FormatGEXF    Format = "gexf"
+	FormatMermaid Format = "mermaid"
+	FormatJSON    Format = "json"
+)
+
+// Formats are the supported export formats.
+var Formats = []Format{FormatDOT, FormatGraphML, FormatGEXF, FormatMermaid, FormatJSON}
+
+// ParseFormat parses an export format name.
+func ParseFormat(s string) (Format, error) {
+	for _, f := range Formats {
+		if string(f) == strings.ToLower(s) {
+			return f, nil
+		}
+	}
+	return "", fmt.Errorf("unsupported graph format %q: must be one of %v", s, Formats)
+}
+
+// FormatFromPath infers the export format from a file extension, defaulting to DOT.
+func FormatFromPath(path string) Format {
+	switch strings.ToLower(filepath.Ext(path)) {
+	case ".graphml":
+		return FormatGraphML
+	case ".gexf":
+		return FormatGEXF
+	case ".mmd", ".mermaid":
+		return FormatMermaid
+	case ".json":
+		return FormatJSON
+	}
+	return FormatDOT
+}
+
+// Node is a vertex in an exported graph.
+type Node struct {
+	// ID is the vertex key, in the format <type>/<resource ID>.
+	ID         string         `json:"id"`
+	Type       string         `json:"type"`
+	ResourceID string         `json:"resourceId"`
+	Name       string         `json:"name,omitempty"`
+	Label      string         `json:"label"`
+	Data       map[string]any `json:"data,omitempty"`
+}
+
+// Edge is an edge in an exported graph.
+type Edge struct {
+	Source string `json:"source"`
+	Target string `json:"target"`
+	// Label is the name of the relation field.
+	Label string `json:"label,omitempty"`
+}
+
+// Export is the nodes and edges of a graph, sorted so that exports are stable.
+type Export struct {
+	Nodes []Node `json:"nodes"`
+	Edges []Edge `json:"edges"`
+}
+
+// Flatten lists the nodes and edges of a graph.
+func Flatten(g Graph) (Export, error) {
+	var e Export
+
+	adjacencyMap, err := g.AdjacencyMap()
+	if err != nil {
+		return e, err
+	}
+
+	for hash, adjacencies := range adjacencyMap {
+		r, props, err := g.VertexWithProperties(hash)
+		if err != nil {
+			return e, err
+		}
+		label := props.Attributes["label"]
+		if label == "" {
+			label = Label(r)
+		}
+		e.Nodes = append(e.Nodes, nodeFor(hash, r, label))
+
+		for target, edge := range adjacencies {
+			e.Edges = append(e.Edges, Edge{Source: hash, Target: target, Label: edge.Properties.Attributes["label"]})
+		}
+	}
+
+	sort.Slice(e.Nodes, func(i, j int) bool {
+		return e.Nodes[i].ID < e.Nodes[j].ID
+	})
+	sort.Slice(e.Edges, func(i, j int) bool {
+		if e.Edges[i].Source != e.Edges[j].Source {
+			return e.Edges[i].Source < e.Edges[j].Source
+		}
+		return e.Edges[i].Target < e.Edges[j].Target
+	})
+	return e, nil
+}
+
+func nodeFor(hash string, r msg.Resource, label string) Node {
+	return Node{
+		ID:         hash,
+		Type:       r.Type,
+		ResourceID: r.ID,
+		Name:       r.Name,
+		Label:      label,
+		Data:       r.Data,
+	}
+}
+
+// Write exports a graph in the given format.
+func Write(w io.Writer, g Graph, f Format) error {
+	e, err := Flatten(g)
+	if err != nil {
+		return err
+	}
+
+	switch f {
+	case FormatDOT:
+		return writeDOT(w, e)
+	case FormatGraphML:
+		return writeGraphML(w, e)
+	case FormatGEXF:
+		return writeGEXF(w, e)
+	case FormatMermaid:
+		return writeMermaid(w, e)
+	case FormatJSON:
+		enc := json.NewEncoder(w)
+		enc.SetIndent("", "  ")
+		return enc.Encode(e)
+	}
+	return fmt.Errorf("unsupported graph format %q", f)
+}
+
+func writeDOT(w io.Writer, e Export) error {
+	var b strings.Builder
+	b.WriteString("strict digraph {\n")
+	for _, n := range e.Nodes {
+		fmt.Fprintf(&b, "\t%s [ label=%s, type=%s ];\n", strconv.Quote(n.ID), strconv.Quote(n.Label), strconv.Quote(n.Type))
+	}
+	for _, edge := range e.Edges {
+		fmt.Fprintf(&b, "\t%s -> %s [ label=%s ];\n", strconv.Quote(edge.Source), strconv.Quote(edge.Target), strconv.Quote(edge.Label))
+	}
+	b.WriteString("}\n")
+	_, err := io.WriteString(w, b.String())
+	return err
+}
+
+type graphMLData struct {
+	Key   string `xml:"key,attr"`
+	Value string `xml:",chardata"`
+}
+
+type graphMLKey struct {
+	ID       string `xml:"id,attr"`
+	For      string `xml:"for,attr"`
+	AttrName string `xml:"attr.name,attr"`
+	AttrType string `xml:"attr.type,attr"`
+}
+
+type graphMLNode struct {
+	ID   string        `xml:"id,attr"`
+	Data []graphMLData `xml:"data"`
+}
+
+type graphMLEdge struct {
+	ID     string        `xml:"id,attr"`
+	Source string        `xml:"source,attr"`
+	Target string        `xml:"target,attr"`
+	Data   []graphMLData `xml:"data"`
+}
+
+type graphML struct {
+	XMLName xml.Name     `xml:"graphml"`
+	XMLNS   string       `xml:"xmlns,attr"`
+	Keys    []graphMLKey `xml:"key"`
+	Graph   struct {
+		ID          string        `xml:"id,attr"`
+		EdgeDefault string        `xml:"edgedefault,attr"`
+		Nodes       []graphMLNode `xml:"node"`
+		Edges       []graphMLEdge `xml:"edge"`
+	} `xml:"graph"`
+}
+
+func writeGraphML(w io.Writer, e Export) error {
+	doc := graphML{
+		XMLNS: "http://graphml.graphdrawing.org/xmlns",
+		Keys: []graphMLKey{
+			{ID: "label", For: "node", AttrName: "label", AttrType: "string"},
+			{ID: "type", For: "node", AttrName: "type", AttrType: "string"},
+			{ID: "resourceId", For: "node", AttrName: "resourceId", AttrType: "string"},
+			{ID: "name", For: "node", AttrName: "name", AttrType: "string"},
+			{ID: "relation", For: "edge", AttrName: "relation", AttrType: "string"},
+		},
+	}
+	doc.Graph.ID = "G"
+	doc.Graph.EdgeDefault = "directed"
+
+	for _, n := range e.Nodes {
+		doc.Graph.Nodes = append(doc.Graph.Nodes, graphMLNode{
+			ID: n.ID,
+			Data: []graphMLData{
+				{Key: "label", Value: n.Label},
+				{Key: "type", Value: n.Type},
+				{Key: "resourceId", Value: n.ResourceID},
+				{Key: "name", Value: n.Name},
+			},
+		})
+	}
+	for i, edge := range e.Edges {
+		doc.Graph.Edges = append(doc.Graph.Edges, graphMLEdge{
+			ID:     fmt.Sprintf("e%d", i),
+			Source: edge.Source,
+			Target: edge.Target,
+			Data:   []graphMLData{{Key: "relation", Value: edge.Label}},
+		})
+	}
+	return writeXML(w, doc)
+}
+
+type gexfAttValue struct {
+	For   string `xml:"for,attr"`
+	Value string `xml:"value,attr"`
+}
+
+type gexfNode struct {
+	ID        string         `xml:"id,attr"`
+	Label     string         `xml:"label,attr"`
+	AttValues []gexfAttValue `xml:"attvalues>attvalue"`
+}
+
+type gexfEdge struct {
+	ID     string `xml:"id,attr"`
+	Source string `xml:"source,attr"`
+	Target string `xml:"target,attr"`
+	Label  string `xml:"label,attr,omitempty"`
+}
+
+type gexfAttribute struct {
+	ID    string `xml:"id,attr"`
+	Title string `xml:"title,attr"`
+	Type  string `xml:"type,attr"`
+}
+
+type gexf struct {
+	XMLName xml.Name `xml:"gexf"`
+	XMLNS   string   `xml:"xmlns,attr"`
+	Version string   `xml:"version,attr"`
+	Graph   struct {
+		DefaultEdgeType string `xml:"defaultedgetype,attr"`
+		Attributes      struct {
+			Class      string          `xml:"class,attr"`
+			Attributes []gexfAttribute `xml:"attribute"`
+		} `xml:"attributes"`
+		Nodes []gexfNode `xml:"nodes>node"`
+		Edges []gexfEdge `xml:"edges>edge"`
+	} `xml:"graph"`
+}
+
+func writeGEXF(w io.Writer, e Export) error {
+	doc := gexf{XMLNS: "http://gexf.net/1.3", Version: "1.3"}
+	doc.Graph.DefaultEdgeType = "directed"
+	doc.Graph.Attributes.Class = "node"
+	doc.Graph.Attributes.Attributes = []gexfAttribute{
+		{ID: "type", Title: "type", Type: "string"},
+		{ID: "resourceId", Title: "resourceId", Type: "string"},
+		{ID: "name", Title: "name", Type: "string"},
+	}
+
+	for _, n := range e.Nodes {
+		doc.Graph.Nodes = append(doc.Graph.Nodes, gexfNode{
+			ID:    n.ID,
+			Label: n.Label,
+			AttValues: []gexfAttValue{
+				{For: "type", Value: n.Type},
+				{For: "resourceId", Value: n.ResourceID},
+				{For: "name", Value: n.Name},
+			},
+		})
+	}
+	for i, edge := range e.Edges {
+		doc.Graph.Edges = append(doc.Graph.Edges, gexfEdge{
+			ID:     fmt.Sprintf("e%d", i),
+			Source: edge.Source,
+			Target: edge.Target,
+			Label:  edge.Label,
+		})
+	}
+	return writeXML(w, doc)
+}
+
+func writeXML(w io.Writer, v any) error {
+	_, err := io.WriteString(w, xml.Header)
+	if err != nil {
+		return err
+	}
+	enc := xml.NewEncoder(w)
+	enc.Indent("", "  ")
+	err = enc.Encode(v)
+	if err != nil {
+		return err
+	}
+	_, err = io.WriteString(w, "\n")
+	return err
+}
+
+// mermaidEscape escapes text for use inside a quoted Mermaid label.
+func mermaidEscape(s string) string {
+	return strings.ReplaceAll(s, `"`, "#quot;")
+}
+
+func writeMermaid(w io.Writer, e Export) error {
+	var b strings.Builder
+	b.WriteString("flowchart LR\n")
+
+	// Mermaid node IDs can't contain the characters used in resource IDs, so number the nodes
+	ids := map[string]string{}
+	for i, n := range e.Nodes {
+		id := fmt.Sprintf("n%d", i)
+		ids[n.ID] = id
+		fmt.Fprintf(&b, "  %s[\"%s\"]\n", id, mermaidEscape(n.Label))
+	}
+	for _, edge := range e.Edges {
+		if edge.Label != "" {
+			fmt.Fprintf(&b, "  %s -->|\"%s\"| %s\n", ids[edge.Source], mermaidEscape(edge.Label), ids[edge.Target])
+		} else {
+			fmt.Fprintf(&b, "  %s --> %s\n", ids[edge.Source], ids[edge.Target])
+		}
+	}
+	_, err := io.WriteString(w, b.String())
+	return err
+}
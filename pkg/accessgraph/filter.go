@@ -0,0 +1,268 @@
+package accessgraph
+
+import (
+	"sort"
+	"strings"
+
+	"github.com/dominikbraun/graph"
+	"github.com/pkg/errors"
+)
+
+// DefaultDepth is the number of edges followed from the start vertices of a filter
+// without a depth, such as from an account to its assignments and the principals they're
+// assigned to. Following every edge would usually select most of the graph, as users and
+// groups connect accounts to each other.
+const DefaultDepth = 2
+
+// Filter selects part of a graph to export.
+type Filter struct {
+	// Start is the vertex keys to start from. If empty, every vertex is selected.
+	Start []string
+	// Depth is the number of edges to follow from the start vertices, in either direction.
+	// Zero uses DefaultDepth, and a negative depth means there is no limit.
+	Depth int
+	// IncludeTypes keeps only resources of these types. If empty, every type is kept.
+	IncludeTypes []string
+	// ExcludeTypes removes resources of these types.
+	ExcludeTypes []string
+	// CollapseGroups replaces memberships and intermediate groups with a single
+	// edge from each principal to the groups it belongs to.
+	CollapseGroups bool
+}
+
+// Subgraph returns the part of a graph matching the filter.
+// Group chains are collapsed first, so that the depth is counted in collapsed edges.
+// Type filters are applied last, so that excluded resources are still followed when
+// finding the vertices near the start vertices.
+func Subgraph(g Graph, m Model, f Filter) (Graph, error) {
+	var err error
+	if f.CollapseGroups {
+		g, err = CollapseGroups(g, m)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	adjacency, err := g.AdjacencyMap()
+	if err != nil {
+		return nil, err
+	}
+
+	selected := map[string]bool{}
+	if len(f.Start) == 0 {
+		for v := range adjacency {
+			selected[v] = true
+		}
+	} else {
+		predecessors, err := g.PredecessorMap()
+		if err != nil {
+			return nil, err
+		}
+		for _, v := range f.Start {
+			if _, err := g.Vertex(v); err != nil {
+				return nil, errors.Wrapf(err, "finding start vertex %s", v)
+			}
+			selected[v] = true
+		}
+
+		maxDepth := f.Depth
+		if maxDepth == 0 {
+			maxDepth = DefaultDepth
+		}
+		frontier := append([]string{}, f.Start...)
+		for depth := 0; len(frontier) > 0 && (maxDepth < 0 || depth < maxDepth); depth++ {
+			var next []string
+			for _, v := range frontier {
+				for _, neighbours := range []map[string]graph.Edge[string]{adjacency[v], predecessors[v]} {
+					for n := range neighbours {
+						if !selected[n] {
+							selected[n] = true
+							next = append(next, n)
+						}
+					}
+				}
+			}
+			frontier = next
+		}
+	}
+
+	return copyGraph(g, func(v string) bool {
+		if !selected[v] {
+			return false
+		}
+		t, _, _ := SplitKey(v)
+		if len(f.IncludeTypes) > 0 && !containsFold(f.IncludeTypes, t) {
+			return false
+		}
+		return !containsFold(f.ExcludeTypes, t)
+	}, nil)
+}
+
+// CollapseGroups removes memberships and intermediate groups from a graph, adding an
+// edge from each principal to every group it belongs to. An intermediate group is a
+// group which is a member of another group and has nothing assigned to it. Edges which
+// skip intermediate groups are labelled with the groups they pass through.
+func CollapseGroups(g Graph, m Model) (Graph, error) {
+	w, err := newWalker(g, m)
+	if err != nil {
+		return nil, err
+	}
+
+	// assignments to groups may reference the group with the same field as memberships
+	memberships := map[string]bool{}
+	for v := range w.succs {
+		if _, _, ok := w.assignment(v); ok {
+			continue
+		}
+		for _, field := range w.succs[v] {
+			if field == m.MembershipGroupField {
+				memberships[v] = true
+			}
+		}
+	}
+
+	// memberOf returns the groups which a principal is directly a member of
+	memberOf := func(principal string) []string {
+		var groups []string
+		for p := range w.preds[principal] {
+			if memberships[p] {
+				groups = append(groups, w.joins(p, principal)...)
+			}
+		}
+		sort.Strings(groups)
+		return groups
+	}
+
+	intermediate := map[string]bool{}
+	for v := range w.succs {
+		if !isType(v, m.Group) || len(memberOf(v)) == 0 {
+			continue
+		}
+		assigned := false
+		for p := range w.preds[v] {
+			if _, _, ok := w.assignment(p); ok {
+				assigned = true
+				break
+			}
+		}
+		intermediate[v] = !assigned
+	}
+
+	type collapsedEdge struct {
+		from, to string
+		via      []string
+	}
+	var edges []collapsedEdge
+
+	// follow each chain of groups from a principal until reaching a group which is kept
+	var follow func(principal string, group string, via []string)
+	follow = func(principal string, group string, via []string) {
+		if !intermediate[group] {
+			edges = append(edges, collapsedEdge{from: principal, to: group, via: via})
+			return
+		}
+		for _, next := range memberOf(group) {
+			// don't follow cycles in nested group memberships
+			if next == principal || contains(via, next) {
+				continue
+			}
+			follow(principal, next, append(append([]string{}, via...), group))
+		}
+	}
+
+	for v := range w.succs {
+		if memberships[v] || intermediate[v] || !(isType(v, m.User) || isType(v, m.Group)) {
+			continue
+		}
+		for _, group := range memberOf(v) {
+			follow(v, group, nil)
+		}
+	}
+
+	return copyGraph(g, func(v string) bool {
+		return !memberships[v] && !intermediate[v]
+	}, func(c Graph) error {
+		for _, e := range edges {
+			label := "member"
+			if len(e.via) > 0 {
+				var names []string
+				for _, v := range e.via {
+					names = append(names, idOf(v))
+				}
+				label = "member via " + strings.Join(names, " > ")
+			}
+			err := c.AddEdge(e.from, e.to, graph.EdgeAttribute("label", label))
+			// a principal may reach a group through more than one chain, so keep the first
+			if err != nil && err != graph.ErrEdgeAlreadyExists {
+				return err
+			}
+		}
+		return nil
+	})
+}
+
+// copyGraph copies the vertices which are kept, and the edges between them, into a new graph.
+// If addEdges is set it's called with the new graph after the vertices have been copied.
+func copyGraph(g Graph, keep func(v string) bool, addEdges func(c Graph) error) (Graph, error) {
+	adjacency, err := g.AdjacencyMap()
+	if err != nil {
+		return nil, err
+	}
+
+	var vertices []string
+	for v := range adjacency {
+		if keep(v) {
+			vertices = append(vertices, v)
+		}
+	}
+	sort.Strings(vertices)
+
+	c := New()
+	for _, v := range vertices {
+		r, props, err := g.VertexWithProperties(v)
+		if err != nil {
+			return nil, err
+		}
+		var opts []func(*graph.VertexProperties)
+		for k, v := range props.Attributes {
+			opts = append(opts, graph.VertexAttribute(k, v))
+		}
+		err = c.AddVertex(r, opts...)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	for _, v := range vertices {
+		for target, e := range adjacency[v] {
+			if !keep(target) {
+				continue
+			}
+			var opts []func(*graph.EdgeProperties)
+			for k, v := range e.Properties.Attributes {
+				opts = append(opts, graph.EdgeAttribute(k, v))
+			}
+			err = c.AddEdge(v, target, opts...)
+			if err != nil {
+				return nil, err
+			}
+		}
+	}
+
+	if addEdges != nil {
+		err = addEdges(c)
+		if err != nil {
+			return nil, err
+		}
+	}
+	return c, nil
+}
+
+func containsFold(s []string, v string) bool {
+	for _, x := range s {
+		if strings.EqualFold(x, v) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,168 @@
+package accessgraph_test
+
+import (
+	"reflect"
+	"sort"
+	"testing"
+
+	"github.com/common-fate/access-inspector/pkg/accessgraph"
+)
+
+// vertices returns the sorted vertex keys of a graph.
+func vertices(t *testing.T, g accessgraph.Graph) []string {
+	t.Helper()
+	adjacency, err := g.AdjacencyMap()
+	if err != nil {
+		t.Fatal(err)
+	}
+	var keys []string
+	for v := range adjacency {
+		keys = append(keys, v)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+// labels returns the labels of the edges in a graph, keyed by "from -> to".
+func labels(t *testing.T, g accessgraph.Graph) map[string]string {
+	t.Helper()
+	adjacency, err := g.AdjacencyMap()
+	if err != nil {
+		t.Fatal(err)
+	}
+	m := map[string]string{}
+	for from, targets := range adjacency {
+		for to, e := range targets {
+			m[from+" -> "+to] = e.Properties.Attributes["label"]
+		}
+	}
+	return m
+}
+
+// twoAccounts is alice, who has access to prod directly and to dev through the devs group.
+func twoAccounts(t *testing.T) accessgraph.Graph {
+	return graphOf(t, edges(
+		assignment("aa-prod", "user", "User/alice", "prod", "admin"),
+		membership("gm-alice", "user", "User/alice", "Group/devs"),
+		assignment("aa-dev", "group", "Group/devs", "dev", "admin"),
+	)...)
+}
+
+func TestSubgraph(t *testing.T) {
+	tests := []struct {
+		name   string
+		filter accessgraph.Filter
+		want   []string
+	}{
+		{
+			name:   "no filter",
+			filter: accessgraph.Filter{},
+			want:   []string{"Account/dev", "Account/prod", "AccountAssignment/aa-dev", "AccountAssignment/aa-prod", "Group/devs", "GroupMembership/gm-alice", "PermissionSet/admin", "User/alice"},
+		},
+		{
+			// the assignment and the principal it's assigned to, but not the rest of the user's access
+			name:   "default depth",
+			filter: accessgraph.Filter{Start: []string{"Account/prod"}},
+			want:   []string{"Account/prod", "AccountAssignment/aa-prod", "PermissionSet/admin", "User/alice"},
+		},
+		{
+			name:   "depth",
+			filter: accessgraph.Filter{Start: []string{"Account/prod"}, Depth: 1},
+			want:   []string{"Account/prod", "AccountAssignment/aa-prod"},
+		},
+		{
+			name:   "no depth limit",
+			filter: accessgraph.Filter{Start: []string{"Account/prod"}, Depth: -1},
+			want:   []string{"Account/dev", "Account/prod", "AccountAssignment/aa-dev", "AccountAssignment/aa-prod", "Group/devs", "GroupMembership/gm-alice", "PermissionSet/admin", "User/alice"},
+		},
+		{
+			name:   "several start vertices",
+			filter: accessgraph.Filter{Start: []string{"Account/prod", "Group/devs"}, Depth: 1},
+			want:   []string{"Account/prod", "AccountAssignment/aa-dev", "AccountAssignment/aa-prod", "Group/devs", "GroupMembership/gm-alice"},
+		},
+		{
+			// excluded resources are still followed to find the resources near the start
+			name:   "exclude types",
+			filter: accessgraph.Filter{Start: []string{"Account/prod"}, Depth: 4, ExcludeTypes: []string{"accountassignment", "GroupMembership"}},
+			want:   []string{"Account/dev", "Account/prod", "Group/devs", "PermissionSet/admin", "User/alice"},
+		},
+		{
+			name:   "include types",
+			filter: accessgraph.Filter{IncludeTypes: []string{"Account", "User"}},
+			want:   []string{"Account/dev", "Account/prod", "User/alice"},
+		},
+		{
+			// the depth is counted in collapsed edges, so the group is one edge from the user
+			name:   "collapse groups",
+			filter: accessgraph.Filter{Start: []string{"User/alice"}, Depth: 1, CollapseGroups: true},
+			want:   []string{"AccountAssignment/aa-prod", "Group/devs", "User/alice"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			sub, err := accessgraph.Subgraph(twoAccounts(t), accessgraph.AWSSSO, tt.filter)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got := vertices(t, sub); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("got vertices %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestSubgraphKeepsEdges(t *testing.T) {
+	sub, err := accessgraph.Subgraph(twoAccounts(t), accessgraph.AWSSSO, accessgraph.Filter{Start: []string{"Account/prod"}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := map[string]string{
+		"AccountAssignment/aa-prod -> Account/prod":        "account",
+		"AccountAssignment/aa-prod -> PermissionSet/admin": "permission_set",
+		"AccountAssignment/aa-prod -> User/alice":          "user",
+	}
+	if got := labels(t, sub); !reflect.DeepEqual(got, want) {
+		t.Errorf("got edges %v, want %v", got, want)
+	}
+}
+
+func TestSubgraphUnknownStart(t *testing.T) {
+	_, err := accessgraph.Subgraph(twoAccounts(t), accessgraph.AWSSSO, accessgraph.Filter{Start: []string{"Account/staging"}})
+	if err == nil {
+		t.Error("expected an error for a start vertex which isn't in the graph")
+	}
+}
+
+func TestCollapseGroups(t *testing.T) {
+	// alice is in eng, which has nothing assigned and is a member of platform and of itself
+	// through ops. bob is directly in platform.
+	g := graphOf(t, edges(
+		membership("gm-alice", "user", "User/alice", "Group/eng"),
+		membership("gm-eng", "member", "Group/eng", "Group/ops"),
+		membership("gm-ops", "member", "Group/ops", "Group/eng"),
+		membership("gm-eng-platform", "member", "Group/eng", "Group/platform"),
+		membership("gm-bob", "user", "User/bob", "Group/platform"),
+		assignment("aa-platform", "group", "Group/platform", "prod", "admin"),
+	)...)
+
+	collapsed, err := accessgraph.CollapseGroups(g, accessgraph.AWSSSO)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	wantVertices := []string{"Account/prod", "AccountAssignment/aa-platform", "Group/platform", "PermissionSet/admin", "User/alice", "User/bob"}
+	if got := vertices(t, collapsed); !reflect.DeepEqual(got, wantVertices) {
+		t.Errorf("got vertices %v, want %v", got, wantVertices)
+	}
+
+	wantEdges := map[string]string{
+		"User/alice -> Group/platform":                         "member via eng",
+		"User/bob -> Group/platform":                           "member",
+		"AccountAssignment/aa-platform -> Group/platform":      "group",
+		"AccountAssignment/aa-platform -> Account/prod":        "account",
+		"AccountAssignment/aa-platform -> PermissionSet/admin": "permission_set",
+	}
+	if got := labels(t, collapsed); !reflect.DeepEqual(got, wantEdges) {
+		t.Errorf("got edges %v, want %v", got, wantEdges)
+	}
+}
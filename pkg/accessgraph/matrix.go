@@ -0,0 +1,92 @@
+package accessgraph
+
+import (
+	"encoding/csv"
+	"io"
+	"sort"
+	"strings"
+)
+
+// WriteMatrix writes effective access as a CSV matrix, with a row for each user and a column
+// for each account and permission set. Each cell lists the path types granting the access.
+func WriteMatrix(w io.Writer, g Graph, m Model, access []Access) error {
+	name := func(key string) string {
+		r, err := g.Vertex(key)
+		if err != nil || r.Name == "" {
+			return idOf(key)
+		}
+		return r.Name
+	}
+
+	type column struct {
+		key   string
+		label string
+	}
+
+	columns := map[string]column{}
+	cells := map[string]map[string]map[PathType]bool{}
+	var users []string
+
+	for _, a := range access {
+		col := a.AccountKey(m) + "+" + a.PermissionSetKey(m)
+		if _, ok := columns[col]; !ok {
+			label := name(a.AccountKey(m)) + " / " + name(a.PermissionSetKey(m))
+			if a.Instance != "" {
+				label += " (" + a.Instance + ")"
+			}
+			columns[col] = column{key: col, label: label}
+		}
+		user := a.UserKey(m)
+		if cells[user] == nil {
+			cells[user] = map[string]map[PathType]bool{}
+			users = append(users, user)
+		}
+		if cells[user][col] == nil {
+			cells[user][col] = map[PathType]bool{}
+		}
+		cells[user][col][a.PathType] = true
+	}
+
+	var cols []column
+	for _, c := range columns {
+		cols = append(cols, c)
+	}
+	sort.Slice(cols, func(i, j int) bool {
+		return cols[i].label < cols[j].label
+	})
+	sort.Strings(users)
+
+	cw := csv.NewWriter(w)
+
+	header := []string{"user"}
+	for _, c := range cols {
+		header = append(header, c.label)
+	}
+	err := cw.Write(header)
+	if err != nil {
+		return err
+	}
+
+	for _, u := range users {
+		label := name(u)
+		if instance := instanceOf(u); instance != "" {
+			label += " (" + instance + ")"
+		}
+		row := []string{label}
+		for _, c := range cols {
+			var types []string
+			for t := range cells[u][c.key] {
+				types = append(types, string(t))
+			}
+			sort.Strings(types)
+			row = append(row, strings.Join(types, ";"))
+		}
+		err = cw.Write(row)
+		if err != nil {
+			return err
+		}
+	}
+
+	cw.Flush()
+	return cw.Error()
+}
@@ -0,0 +1,233 @@
+package accessgraph
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/common-fate/access-inspector/pkg/report"
+	"github.com/common-fate/provider-registry-sdk-go/pkg/msg"
+	"github.com/dominikbraun/graph"
+)
+
+// Grant is a just-in-time entitlement, such as an active Common Fate Access Request.
+type Grant struct {
+	ID string
+	// Instance is the instance holding the user, account and permission set. It's empty for the default instance.
+	Instance        string
+	UserID          string
+	AccountID       string
+	PermissionSetID string
+}
+
+// AddGrants adds just-in-time grants to the graph, so that they are included in effective access.
+// Each grant becomes a vertex of the model's Grant type referencing the user, account and permission set.
+func AddGrants(g Graph, m Model, grants []Grant) error {
+	for _, grant := range grants {
+		r := msg.Resource{
+			Type: m.Grant,
+			ID:   grant.ID,
+			Data: map[string]any{"user": grant.UserID, "account": grant.AccountID, "permission_set": grant.PermissionSetID},
+		}
+		if grant.Instance != "" {
+			r.Data[report.InstanceColumn] = grant.Instance
+		}
+		err := g.AddVertex(r, graph.VertexAttribute("label", Label(r)))
+		if err == graph.ErrVertexAlreadyExists {
+			continue
+		}
+		if err != nil {
+			return err
+		}
+
+		refs := []struct {
+			field string
+			to    msg.Resource
+		}{
+			{"user", msg.Resource{Type: m.User, ID: grant.UserID, Data: inInstance(grant.Instance)}},
+			{"account", msg.Resource{Type: m.Account, ID: grant.AccountID, Data: inInstance(grant.Instance)}},
+			{"permission_set", msg.Resource{Type: m.PermissionSet, ID: grant.PermissionSetID, Data: inInstance(grant.Instance)}},
+		}
+		for _, ref := range refs {
+			err = g.AddVertex(ref.to, graph.VertexAttribute("label", Label(ref.to)))
+			if err != nil && err != graph.ErrVertexAlreadyExists {
+				return err
+			}
+			err = g.AddEdge(Hash(r), Hash(ref.to), graph.EdgeAttribute("label", ref.field))
+			if err != nil && err != graph.ErrEdgeAlreadyExists {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// Lookup returns the vertex keys of the resources of a type with an ID, in every instance.
+func Lookup(g Graph, resourceType string, id string) ([]string, error) {
+	adjacency, err := g.AdjacencyMap()
+	if err != nil {
+		return nil, err
+	}
+	var keys []string
+	for v := range adjacency {
+		if isType(v, resourceType) && idOf(v) == id {
+			keys = append(keys, v)
+		}
+	}
+	sort.Strings(keys)
+	return keys, nil
+}
+
+// Find looks up a resource of a particular type by its ID, its name, or its email address.
+// Matching on names and email addresses is case-insensitive. Resources in every instance
+// are searched, and the query may also be the vertex key of a resource.
+func Find(g Graph, resourceType string, query string) (msg.Resource, error) {
+	return find(g, resourceType, query, func(string) bool { return true })
+}
+
+// FindIn looks up a resource like Find, only searching the resources in an instance.
+// The instance is empty for the default instance.
+func FindIn(g Graph, resourceType string, instance string, query string) (msg.Resource, error) {
+	return find(g, resourceType, query, func(v string) bool { return instanceOf(v) == instance })
+}
+
+func find(g Graph, resourceType string, query string, inScope func(v string) bool) (msg.Resource, error) {
+	if r, err := g.Vertex(query); err == nil && r.Type == resourceType {
+		return r, nil
+	}
+
+	adjacency, err := g.AdjacencyMap()
+	if err != nil {
+		return msg.Resource{}, err
+	}
+
+	var byID, byName []msg.Resource
+	for v := range adjacency {
+		if !isType(v, resourceType) || !inScope(v) {
+			continue
+		}
+		r, err := g.Vertex(v)
+		if err != nil {
+			return msg.Resource{}, err
+		}
+		email, _ := r.Data["email"].(string)
+		if r.ID == query {
+			byID = append(byID, r)
+		} else if strings.EqualFold(r.Name, query) || strings.EqualFold(email, query) {
+			byName = append(byName, r)
+		}
+	}
+
+	// IDs take precedence over names, as a name may be the ID of another resource
+	matches := byID
+	if len(matches) == 0 {
+		matches = byName
+	}
+
+	switch len(matches) {
+	case 0:
+		return msg.Resource{}, fmt.Errorf("no %s matching %q was found", resourceType, query)
+	case 1:
+		return matches[0], nil
+	}
+	var keys []string
+	for _, r := range matches {
+		keys = append(keys, Hash(r))
+	}
+	sort.Strings(keys)
+	if len(byID) > 0 {
+		return msg.Resource{}, fmt.Errorf("%q matches a %s in more than one instance (%s): use the key instead", query, resourceType, strings.Join(keys, ", "))
+	}
+	var ids []string
+	for _, k := range keys {
+		ids = append(ids, idOf(k))
+	}
+	return msg.Resource{}, fmt.Errorf("%q matches more than one %s (%s): use the ID instead", query, resourceType, strings.Join(ids, ", "))
+}
+
+// InvalidResourceError is returned by FindVertex for resources which aren't in the format it accepts.
+type InvalidResourceError struct {
+	Resource string
+}
+
+func (e InvalidResourceError) Error() string {
+	return fmt.Sprintf("%q must be in the format <type>/<ID, name or email>", e.Resource)
+}
+
+// FindVertex looks up a resource given as <type>/<ID, name or email>, returning its vertex key.
+// Resources may be given as <type>@<instance>/<ID, name or email> to only search one instance.
+func FindVertex(g Graph, s string) (string, error) {
+	head, query, ok := strings.Cut(s, "/")
+	if !ok {
+		return "", InvalidResourceError{Resource: s}
+	}
+	find := Find
+	resourceType, instance, scoped := strings.Cut(head, "@")
+	if scoped {
+		find = func(g Graph, resourceType string, query string) (msg.Resource, error) {
+			return FindIn(g, resourceType, instance, query)
+		}
+	}
+	r, err := find(g, resourceType, query)
+	if err != nil {
+		return "", err
+	}
+	return Hash(r), nil
+}
+
+// Why returns every path which grants a user access to an account in the user's instance,
+// where user is the vertex key of the user. If permissionSetID is not empty, only paths
+// granting that permission set are returned.
+func Why(g Graph, m Model, user, accountID, permissionSetID string) ([]Access, error) {
+	w, err := newWalker(g, m)
+	if err != nil {
+		return nil, err
+	}
+	access := w.effectiveAccess(user)
+
+	var paths []Access
+	for _, a := range access {
+		if a.AccountID != accountID {
+			continue
+		}
+		if permissionSetID != "" && a.PermissionSetID != permissionSetID {
+			continue
+		}
+		paths = append(paths, a)
+	}
+	return paths, nil
+}
+
+// Hop is a resource on a path through the graph.
+type Hop struct {
+	Type string `json:"type"`
+	ID   string `json:"id"`
+	Name string `json:"name,omitempty"`
+	// Instance is the instance holding the resource. It's empty for the default instance.
+	Instance string `json:"instance,omitempty"`
+}
+
+func (h Hop) String() string {
+	s := h.Type + " " + h.ID
+	if h.Name != "" {
+		s = fmt.Sprintf("%s (%s)", s, h.Name)
+	}
+	if h.Instance != "" {
+		s += " in " + h.Instance
+	}
+	return s
+}
+
+// Hops returns the resources along a path.
+func Hops(g Graph, path []string) []Hop {
+	var hops []Hop
+	for _, v := range path {
+		t, instance, id := SplitKey(v)
+		hop := Hop{Type: t, ID: id, Instance: instance}
+		if r, err := g.Vertex(v); err == nil {
+			hop.Name = r.Name
+		}
+		hops = append(hops, hop)
+	}
+	return hops
+}
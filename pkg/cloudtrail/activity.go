@@ -0,0 +1,152 @@
+package cloudtrail
+
+import (
+	"encoding/json"
+	"regexp"
+	"strings"
+	"time"
+)
+
+// Activity is an event attributed to an Identity Center user acting through a permission set.
+type Activity struct {
+	Time time.Time
+	// User is the Identity Center user ID, user name or email address, depending on the event.
+	User              string
+	AccountID         string
+	PermissionSetName string
+	// SignIn is true for events where the user assumed the permission set's role,
+	// and false for API calls made with the role.
+	SignIn bool
+	// Action is the IAM action of an API call, such as "s3:GetObject".
+	Action string
+}
+
+// ssoRoleName matches the IAM roles which Identity Center provisions for permission sets,
+// such as AWSReservedSSO_AdministratorAccess_0123456789abcdef.
+var ssoRoleName = regexp.MustCompile(`^AWSReservedSSO_(.+)_[0-9a-fA-F]+$`)
+
+// PermissionSetFromRole returns the permission set name from the name or ARN of
+// a role provisioned by Identity Center.
+func PermissionSetFromRole(role string) (string, bool) {
+	role = role[strings.LastIndex(role, "/")+1:]
+	m := ssoRoleName.FindStringSubmatch(role)
+	if m == nil {
+		return "", false
+	}
+	return m[1], true
+}
+
+// accountFromARN returns the account ID from an ARN such as arn:aws:iam::123456789012:role/example.
+func accountFromARN(arn string) string {
+	parts := strings.SplitN(arn, ":", 6)
+	if len(parts) < 6 {
+		return ""
+	}
+	return parts[4]
+}
+
+// serviceNames maps event sources to IAM service prefixes where they differ.
+var serviceNames = map[string]string{
+	"monitoring": "cloudwatch",
+	"email":      "ses",
+	"tagging":    "tag",
+}
+
+// Action returns the IAM action for an event, such as "s3:GetObject" for
+// an event from s3.amazonaws.com named GetObject.
+func (e Event) Action() string {
+	service := strings.TrimSuffix(e.EventSource, ".amazonaws.com")
+	if s, ok := serviceNames[service]; ok {
+		service = s
+	}
+	return service + ":" + e.EventName
+}
+
+// Activity attributes an event to an Identity Center user and permission set.
+// It returns false for events which weren't performed through Identity Center,
+// and for failed events such as access denied errors.
+func (e Event) Activity() (Activity, bool) {
+	a := Activity{Time: e.EventTime}
+
+	if e.ErrorCode != "" {
+		return a, false
+	}
+
+	switch {
+	case e.EventSource == "sts.amazonaws.com" && e.EventName == "AssumeRoleWithSAML":
+		var params struct {
+			RoleARN string `json:"roleArn"`
+		}
+		_ = json.Unmarshal(e.RequestParameters, &params)
+		ps, ok := PermissionSetFromRole(params.RoleARN)
+		if !ok {
+			return a, false
+		}
+		a.PermissionSetName = ps
+		a.AccountID = accountFromARN(params.RoleARN)
+		a.User = e.UserIdentity.UserName
+		if a.User == "" {
+			// the principal ID is in the format <saml provider>:<user>
+			a.User = e.UserIdentity.PrincipalID[strings.LastIndex(e.UserIdentity.PrincipalID, ":")+1:]
+		}
+		a.SignIn = true
+
+	case e.EventSource == "sso.amazonaws.com" && (e.EventName == "Federate" || e.EventName == "GetRoleCredentials"):
+		// Federate is recorded when a user signs in to the console through the access portal,
+		// and GetRoleCredentials when they fetch credentials for the CLI.
+		var details struct {
+			RoleName  string `json:"role_name"`
+			AccountID string `json:"account_id"`
+			// GetRoleCredentials request parameters
+			RoleNameParam  string `json:"roleName"`
+			AccountIDParam string `json:"accountId"`
+		}
+		_ = json.Unmarshal(e.ServiceEventDetails, &details)
+		_ = json.Unmarshal(e.RequestParameters, &details)
+		a.PermissionSetName = details.RoleName
+		if a.PermissionSetName == "" {
+			a.PermissionSetName = details.RoleNameParam
+		}
+		a.AccountID = details.AccountID
+		if a.AccountID == "" {
+			a.AccountID = details.AccountIDParam
+		}
+		if e.UserIdentity.OnBehalfOf != nil && e.UserIdentity.OnBehalfOf.UserID != "" {
+			a.User = e.UserIdentity.OnBehalfOf.UserID
+		} else {
+			a.User = e.UserIdentity.UserName
+		}
+		a.SignIn = true
+
+	case e.UserIdentity.Type == "AssumedRole":
+		// an API call made with credentials for a permission set's role
+		var issuer string
+		if e.UserIdentity.SessionContext != nil {
+			issuer = e.UserIdentity.SessionContext.SessionIssuer.UserName
+		}
+		// the ARN is in the format arn:aws:sts::123456789012:assumed-role/<role name>/<session name>
+		parts := strings.Split(e.UserIdentity.ARN, "/")
+		if issuer == "" && len(parts) == 3 {
+			issuer = parts[1]
+		}
+		ps, ok := PermissionSetFromRole(issuer)
+		if !ok || len(parts) != 3 {
+			return a, false
+		}
+		a.PermissionSetName = ps
+		a.AccountID = e.UserIdentity.AccountID
+		if a.AccountID == "" {
+			a.AccountID = e.RecipientAccountID
+		}
+		a.User = parts[2]
+		a.Action = e.Action()
+
+	default:
+		return a, false
+	}
+
+	if a.User == "" || a.AccountID == "" || a.PermissionSetName == "" {
+		return a, false
+	}
+	return a, true
+}
@@ -0,0 +1,145 @@
+// Package cloudtrail reads exported CloudTrail logs and attributes events to
+// AWS IAM Identity Center users, accounts and permission sets.
+package cloudtrail
+
+import (
+	"bufio"
+	"bytes"
+	"compress/gzip"
+	"encoding/json"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+// Event is a CloudTrail event. Only the fields used for attributing access are decoded.
+type Event struct {
+	// EventID uniquely identifies the event, so that logs which are loaded twice can be detected.
+	EventID             string          `json:"eventID"`
+	EventTime           time.Time       `json:"eventTime"`
+	EventSource         string          `json:"eventSource"`
+	EventName           string          `json:"eventName"`
+	AWSRegion           string          `json:"awsRegion"`
+	ErrorCode           string          `json:"errorCode,omitempty"`
+	RecipientAccountID  string          `json:"recipientAccountId"`
+	UserIdentity        UserIdentity    `json:"userIdentity"`
+	RequestParameters   json.RawMessage `json:"requestParameters,omitempty"`
+	ResponseElements    json.RawMessage `json:"responseElements,omitempty"`
+	ServiceEventDetails json.RawMessage `json:"serviceEventDetails,omitempty"`
+}
+
+// UserIdentity is the identity which performed a CloudTrail event.
+type UserIdentity struct {
+	Type           string          `json:"type"`
+	PrincipalID    string          `json:"principalId"`
+	ARN            string          `json:"arn"`
+	AccountID      string          `json:"accountId"`
+	UserName       string          `json:"userName"`
+	SessionContext *SessionContext `json:"sessionContext,omitempty"`
+	OnBehalfOf     *OnBehalfOf     `json:"onBehalfOf,omitempty"`
+}
+
+type SessionContext struct {
+	SessionIssuer struct {
+		Type     string `json:"type"`
+		ARN      string `json:"arn"`
+		UserName string `json:"userName"`
+	} `json:"sessionIssuer"`
+}
+
+// OnBehalfOf identifies the Identity Center user for events performed by the SSO service.
+type OnBehalfOf struct {
+	UserID           string `json:"userId"`
+	IdentityStoreARN string `json:"identityStoreArn"`
+}
+
+// Load reads every CloudTrail log file in a directory and its subdirectories.
+// Files may be gzip compressed, as they are when delivered to S3, and may contain either
+// a {"Records": [...]} object, a JSON array of events, or newline-delimited events.
+func Load(dir string) ([]Event, error) {
+	var events []Event
+
+	err := filepath.WalkDir(dir, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if d.IsDir() {
+			return nil
+		}
+		name := strings.ToLower(d.Name())
+		if !strings.HasSuffix(name, ".json") && !strings.HasSuffix(name, ".json.gz") && !strings.HasSuffix(name, ".ndjson") {
+			return nil
+		}
+
+		fileEvents, err := loadFile(path)
+		if err != nil {
+			return errors.Wrapf(err, "reading CloudTrail log %s", path)
+		}
+		events = append(events, fileEvents...)
+		return nil
+	})
+	return events, err
+}
+
+func loadFile(path string) ([]Event, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	var r io.Reader = f
+	if strings.HasSuffix(strings.ToLower(path), ".gz") {
+		gz, err := gzip.NewReader(f)
+		if err != nil {
+			return nil, err
+		}
+		defer gz.Close()
+		r = gz
+	}
+
+	b, err := io.ReadAll(r)
+	if err != nil {
+		return nil, err
+	}
+	b = bytes.TrimSpace(b)
+	if len(b) == 0 {
+		return nil, nil
+	}
+
+	if b[0] == '[' {
+		var events []Event
+		err = json.Unmarshal(b, &events)
+		return events, err
+	}
+
+	var records struct {
+		Records *[]Event `json:"Records"`
+	}
+	if err := json.Unmarshal(b, &records); err == nil && records.Records != nil {
+		return *records.Records, nil
+	}
+
+	// newline-delimited events, as exported by CloudTrail Lake queries
+	var events []Event
+	scanner := bufio.NewScanner(bytes.NewReader(b))
+	scanner.Buffer(make([]byte, 1024*1024), 16*1024*1024)
+	for scanner.Scan() {
+		line := bytes.TrimSpace(scanner.Bytes())
+		if len(line) == 0 {
+			continue
+		}
+		var e Event
+		err = json.Unmarshal(line, &e)
+		if err != nil {
+			return nil, err
+		}
+		events = append(events, e)
+	}
+	return events, scanner.Err()
+}
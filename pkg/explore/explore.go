@@ -0,0 +1,432 @@
+// Package explore is an interactive terminal browser for the entitlement graph of a report.
+package explore
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/common-fate/access-inspector/pkg/accessgraph"
+	"github.com/common-fate/access-inspector/pkg/remediation"
+	"github.com/common-fate/provider-registry-sdk-go/pkg/msg"
+)
+
+// Item is a row in a view. Selecting an item drills into the resource or access it holds.
+type Item struct {
+	Label  string
+	Detail string
+	// Vertex is the graph vertex the item represents, if any.
+	Vertex string
+	// Access is set for items representing a path granting access.
+	Access *accessgraph.Access
+}
+
+// View is a list of items.
+type View struct {
+	Title  string
+	Items  []Item
+	Cursor int
+	// Query is the fuzzy search filtering the items.
+	Query string
+	// visible is the indexes of the items matching the query.
+	visible []int
+	// marked is true for the view which lists the marked removals.
+	marked bool
+}
+
+func (v *View) filter() {
+	v.visible = v.visible[:0]
+	type scored struct {
+		index, score int
+	}
+	var matches []scored
+	for i, item := range v.Items {
+		score, ok := FuzzyMatch(v.Query, item.Label+" "+item.Detail)
+		if ok {
+			matches = append(matches, scored{i, score})
+		}
+	}
+	// keep the original order unless searching
+	if v.Query != "" {
+		sort.SliceStable(matches, func(i, j int) bool {
+			return matches[i].score > matches[j].score
+		})
+	}
+	for _, m := range matches {
+		v.visible = append(v.visible, m.index)
+	}
+	if v.Cursor >= len(v.visible) {
+		v.Cursor = len(v.visible) - 1
+	}
+	if v.Cursor < 0 {
+		v.Cursor = 0
+	}
+}
+
+// Visible returns the items matching the query.
+func (v *View) Visible() []Item {
+	items := make([]Item, len(v.visible))
+	for i, idx := range v.visible {
+		items[i] = v.Items[idx]
+	}
+	return items
+}
+
+// Selected returns the item under the cursor.
+func (v *View) Selected() (Item, bool) {
+	if len(v.visible) == 0 {
+		return Item{}, false
+	}
+	return v.Items[v.visible[v.Cursor]], true
+}
+
+// Explorer holds the state of an exploring session.
+type Explorer struct {
+	g Graph
+	m accessgraph.Model
+	// access is the effective access of every user.
+	access []accessgraph.Access
+	// stack is the views which have been drilled into, with the current view last.
+	stack []*View
+	// marked is the account assignments marked for removal, keyed by Removal.Key().
+	marked map[string]remediation.Removal
+	// order is the keys of marked in the order they were marked.
+	order []string
+	// Status is a message about the last action.
+	Status string
+}
+
+// Graph is the entitlement graph being explored.
+type Graph = accessgraph.Graph
+
+// Category is a top-level list of resources of one type.
+type Category struct {
+	Title string
+	Type  string
+}
+
+// Categories returns the top-level lists of resources for a model.
+func Categories(m accessgraph.Model) []Category {
+	return []Category{
+		{"Users", m.User},
+		{"Groups", m.Group},
+		{"Accounts", m.Account},
+		{"Permission sets", m.PermissionSet},
+	}
+}
+
+// New creates an explorer, starting at the list of users.
+func New(g Graph, m accessgraph.Model) (*Explorer, error) {
+	access, err := accessgraph.AllEffectiveAccess(g, m)
+	if err != nil {
+		return nil, err
+	}
+	e := &Explorer{g: g, m: m, access: access, marked: map[string]remediation.Removal{}}
+	err = e.ShowCategory(0)
+	if err != nil {
+		return nil, err
+	}
+	return e, nil
+}
+
+// Current returns the view being shown.
+func (e *Explorer) Current() *View {
+	return e.stack[len(e.stack)-1]
+}
+
+func (e *Explorer) push(v *View) {
+	v.filter()
+	e.stack = append(e.stack, v)
+}
+
+// Back returns to the previous view.
+func (e *Explorer) Back() {
+	if len(e.stack) > 1 {
+		e.stack = e.stack[:len(e.stack)-1]
+	}
+}
+
+// Breadcrumbs returns the titles of the views which have been drilled into.
+func (e *Explorer) Breadcrumbs() string {
+	var titles []string
+	for _, v := range e.stack {
+		titles = append(titles, v.Title)
+	}
+	return strings.Join(titles, " > ")
+}
+
+// ShowCategory replaces the views with a list of every resource in a category.
+func (e *Explorer) ShowCategory(i int) error {
+	categories := Categories(e.m)
+	if i < 0 || i >= len(categories) {
+		return nil
+	}
+	c := categories[i]
+
+	adjacency, err := e.g.AdjacencyMap()
+	if err != nil {
+		return err
+	}
+	var vertices []string
+	for v := range adjacency {
+		if t, _, _ := accessgraph.SplitKey(v); t == c.Type {
+			vertices = append(vertices, v)
+		}
+	}
+	sort.Strings(vertices)
+
+	v := &View{Title: c.Title}
+	for _, vertex := range vertices {
+		r, err := e.g.Vertex(vertex)
+		if err != nil {
+			return err
+		}
+		v.Items = append(v.Items, e.resourceItem(vertex, r))
+	}
+	sort.SliceStable(v.Items, func(i, j int) bool {
+		return strings.ToLower(v.Items[i].Label) < strings.ToLower(v.Items[j].Label)
+	})
+
+	e.stack = nil
+	e.push(v)
+	return nil
+}
+
+func (e *Explorer) resourceItem(vertex string, r msg.Resource) Item {
+	label := r.Name
+	if label == "" {
+		label = r.ID
+	}
+	detail := r.ID
+	if email, ok := r.Data["email"].(string); ok && email != "" && email != label {
+		detail = email + "  " + r.ID
+	}
+	if instance := accessgraph.Instance(r); instance != "" {
+		detail += "  " + instance
+	}
+	return Item{Label: label, Detail: detail, Vertex: vertex}
+}
+
+// name returns the name of the resource with a vertex key, or its ID if it has no name.
+func (e *Explorer) name(key string) string {
+	if r, err := e.g.Vertex(key); err == nil && r.Name != "" {
+		return r.Name
+	}
+	_, _, id := accessgraph.SplitKey(key)
+	return id
+}
+
+func (e *Explorer) accessItem(a accessgraph.Access, principal bool) Item {
+	var via []string
+	for _, g := range a.Groups(e.m) {
+		via = append(via, e.name(g))
+	}
+	detail := string(a.PathType)
+	if len(via) > 0 {
+		detail += " via " + strings.Join(via, " > ")
+	}
+	if a.Instance != "" {
+		detail += " in instance " + a.Instance
+	}
+	label := fmt.Sprintf("%s in %s", e.name(a.PermissionSetKey(e.m)), e.name(a.AccountKey(e.m)))
+	if principal {
+		label = e.name(a.UserKey(e.m)) + ": " + label
+	}
+	return Item{Label: label, Detail: detail, Access: &a}
+}
+
+// Select drills into the item under the cursor. Users show their effective access,
+// other resources show every path of access through them, and access shows its path.
+func (e *Explorer) Select() error {
+	item, ok := e.Current().Selected()
+	if !ok {
+		return nil
+	}
+
+	if item.Access != nil {
+		v := &View{Title: item.Label}
+		for _, hop := range item.Access.Path {
+			r, err := e.g.Vertex(hop)
+			if err != nil {
+				return err
+			}
+			it := e.resourceItem(hop, r)
+			it.Label = r.Type + " " + it.Label
+			v.Items = append(v.Items, it)
+		}
+		e.push(v)
+		return nil
+	}
+
+	if item.Vertex == "" {
+		return nil
+	}
+
+	t, _, _ := accessgraph.SplitKey(item.Vertex)
+	if t == e.m.User {
+		v := &View{Title: "Effective access of " + item.Label}
+		for _, a := range e.access {
+			if a.UserKey(e.m) == item.Vertex {
+				v.Items = append(v.Items, e.accessItem(a, false))
+			}
+		}
+		e.push(v)
+		return nil
+	}
+
+	br, err := accessgraph.ComputeBlastRadius(e.g, e.m, item.Vertex)
+	if err != nil {
+		return err
+	}
+	v := &View{Title: "Access through " + item.Label}
+	if t == e.m.Account {
+		v.Title = "Who can reach " + item.Label
+	}
+	for _, a := range br.Access {
+		v.Items = append(v.Items, e.accessItem(a, true))
+	}
+	e.push(v)
+	return nil
+}
+
+// Move moves the cursor by n items.
+func (e *Explorer) Move(n int) {
+	v := e.Current()
+	v.Cursor += n
+	if v.Cursor >= len(v.visible) {
+		v.Cursor = len(v.visible) - 1
+	}
+	if v.Cursor < 0 {
+		v.Cursor = 0
+	}
+}
+
+// Search sets the fuzzy search query of the current view.
+func (e *Explorer) Search(query string) {
+	v := e.Current()
+	v.Query = query
+	v.Cursor = 0
+	v.filter()
+}
+
+// removal returns the account assignment to remove to take away an access path.
+func (e *Explorer) removal(a accessgraph.Access) (remediation.Removal, error) {
+	if a.PathType == accessgraph.PathJIT {
+		return remediation.Removal{}, fmt.Errorf("access through Common Fate grant %s is just-in-time and is removed when the grant expires", a.AssignmentID)
+	}
+	if len(a.Path) < 2 {
+		return remediation.Removal{}, fmt.Errorf("the path to assignment %s is incomplete", a.AssignmentID)
+	}
+
+	// the principal holding the assignment is the vertex before it on the path
+	principal := a.Path[len(a.Path)-2]
+	t, _, id := accessgraph.SplitKey(principal)
+	r := remediation.Removal{
+		PrincipalID:       id,
+		AccountID:         a.AccountID,
+		AccountName:       e.name(a.AccountKey(e.m)),
+		PermissionSetARN:  a.PermissionSetID,
+		PermissionSetName: e.name(a.PermissionSetKey(e.m)),
+	}
+	switch t {
+	case e.m.User:
+		r.PrincipalType = remediation.PrincipalUser
+		r.PrincipalName = e.name(principal)
+		if u, err := e.g.Vertex(principal); err == nil {
+			if email, ok := u.Data["email"].(string); ok && email != "" {
+				r.PrincipalName = email
+			}
+		}
+	case e.m.Group:
+		r.PrincipalType = remediation.PrincipalGroup
+		r.PrincipalName = e.name(principal)
+	default:
+		return remediation.Removal{}, fmt.Errorf("assignment %s is not made to a user or a group", a.AssignmentID)
+	}
+	return r, nil
+}
+
+// ToggleMark marks or unmarks the account assignment granting the access under the cursor for removal.
+func (e *Explorer) ToggleMark() {
+	item, ok := e.Current().Selected()
+	if !ok || item.Access == nil {
+		e.Status = "only access can be marked for removal"
+		return
+	}
+	r, err := e.removal(*item.Access)
+	if err != nil {
+		e.Status = err.Error()
+		return
+	}
+
+	key := r.Key()
+	if _, ok := e.marked[key]; ok {
+		delete(e.marked, key)
+		for i, k := range e.order {
+			if k == key {
+				e.order = append(e.order[:i], e.order[i+1:]...)
+				break
+			}
+		}
+		e.Status = fmt.Sprintf("unmarked %s", describe(r))
+		if e.Current().marked {
+			e.ShowMarked()
+		}
+		return
+	}
+
+	e.marked[key] = r
+	e.order = append(e.order, key)
+	e.Status = fmt.Sprintf("marked %s for removal", describe(r))
+	if r.PrincipalType == remediation.PrincipalGroup {
+		affected := map[string]bool{}
+		for _, a := range e.access {
+			if a.AssignmentID == item.Access.AssignmentID {
+				affected[a.UserID] = true
+			}
+		}
+		e.Status += fmt.Sprintf(", which affects %d users", len(affected))
+	}
+}
+
+// IsMarked returns true if the account assignment granting an access item is marked for removal.
+func (e *Explorer) IsMarked(item Item) bool {
+	if item.Access == nil {
+		return false
+	}
+	r, err := e.removal(*item.Access)
+	if err != nil {
+		return false
+	}
+	_, ok := e.marked[r.Key()]
+	return ok
+}
+
+func describe(r remediation.Removal) string {
+	return fmt.Sprintf("%s %s %s in %s", strings.ToLower(string(r.PrincipalType)), r.PrincipalName, r.PermissionSetName, r.AccountName)
+}
+
+// Marked returns the account assignments marked for removal, in the order they were marked.
+func (e *Explorer) Marked() []remediation.Removal {
+	var removals []remediation.Removal
+	for _, k := range e.order {
+		removals = append(removals, e.marked[k])
+	}
+	return removals
+}
+
+// ShowMarked shows the access granted by the assignments marked for removal.
+func (e *Explorer) ShowMarked() {
+	v := &View{Title: fmt.Sprintf("Marked for removal (%d)", len(e.order)), marked: true}
+	for _, a := range e.access {
+		if e.IsMarked(Item{Access: &a}) {
+			v.Items = append(v.Items, e.accessItem(a, true))
+		}
+	}
+	if e.Current().marked {
+		e.stack[len(e.stack)-1] = v
+		v.filter()
+		return
+	}
+	e.push(v)
+}
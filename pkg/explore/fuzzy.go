@@ -0,0 +1,42 @@
+package explore
+
+import (
+	"strings"
+	"unicode"
+)
+
+// FuzzyMatch returns true if every character of the query appears in s in order,
+// ignoring case. The score is higher for matches which are consecutive or start words,
+// so that closer matches can be listed first. An empty query matches everything.
+func FuzzyMatch(query string, s string) (score int, ok bool) {
+	q := []rune(strings.ToLower(query))
+	if len(q) == 0 {
+		return 0, true
+	}
+	text := []rune(strings.ToLower(s))
+
+	qi := 0
+	prev := -2
+	for i, r := range text {
+		if qi == len(q) {
+			break
+		}
+		if r != q[qi] {
+			continue
+		}
+		score++
+		if prev == i-1 {
+			score += 3
+		}
+		if i == 0 || !unicode.IsLetter(text[i-1]) && !unicode.IsDigit(text[i-1]) {
+			score += 2
+		}
+		prev = i
+		qi++
+	}
+	if qi < len(q) {
+		return 0, false
+	}
+	// prefer shorter strings when the matches are otherwise equal
+	return score*1000 - len(text), true
+}
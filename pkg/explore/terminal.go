@@ -0,0 +1,341 @@
+package explore
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/common-fate/access-inspector/pkg/remediation"
+	"golang.org/x/term"
+)
+
+// key is a keystroke read from the terminal.
+type key string
+
+const (
+	keyUp        key = "up"
+	keyDown      key = "down"
+	keyPageUp    key = "pgup"
+	keyPageDown  key = "pgdn"
+	keyLeft      key = "left"
+	keyRight     key = "right"
+	keyEnter     key = "enter"
+	keyEscape    key = "esc"
+	keyBackspace key = "backspace"
+	keyCtrlC     key = "ctrl-c"
+)
+
+const help = "↑↓ move  ⏎ open  ← back  / search  1-4 lists  m mark  M marked  x export  q quit"
+
+// readKey reads a keystroke, decoding the escape sequences for arrow and paging keys.
+func readKey(r *bufio.Reader) (key, error) {
+	b, err := r.ReadByte()
+	if err != nil {
+		return "", err
+	}
+	switch b {
+	case 3:
+		return keyCtrlC, nil
+	case '\r', '\n':
+		return keyEnter, nil
+	case 127, 8:
+		return keyBackspace, nil
+	case 27:
+		// a lone escape is the escape key, otherwise it starts a sequence
+		if r.Buffered() == 0 {
+			return keyEscape, nil
+		}
+		next, _ := r.ReadByte()
+		if next != '[' && next != 'O' {
+			return keyEscape, nil
+		}
+		var seq []byte
+		for r.Buffered() > 0 {
+			c, _ := r.ReadByte()
+			seq = append(seq, c)
+			if c >= 0x40 && c <= 0x7e {
+				break
+			}
+		}
+		switch string(seq) {
+		case "A":
+			return keyUp, nil
+		case "B":
+			return keyDown, nil
+		case "C":
+			return keyRight, nil
+		case "D":
+			return keyLeft, nil
+		case "5~":
+			return keyPageUp, nil
+		case "6~":
+			return keyPageDown, nil
+		}
+		return keyEscape, nil
+	}
+
+	// read the rest of a multi-byte character
+	buf := []byte{b}
+	for !utf8.FullRune(buf) && r.Buffered() > 0 {
+		c, _ := r.ReadByte()
+		buf = append(buf, c)
+	}
+	return key(buf), nil
+}
+
+// mode is what keystrokes are being used for.
+type mode int
+
+const (
+	modeBrowse mode = iota
+	modeSearch
+	modeExport
+)
+
+// Options configure the remediation script exported from the explorer.
+type Options struct {
+	InstanceARN string
+	Region      string
+	// Instances are the AWS SSO instances holding the account assignments of each permission set, keyed by ARN.
+	Instances map[string]remediation.SSOInstance
+	// ScriptPath is the default file to export the remediation script to.
+	ScriptPath string
+}
+
+// Run shows the explorer in the terminal until the user quits.
+func Run(e *Explorer, in *os.File, out *os.File, opts Options) error {
+	fd := int(in.Fd())
+	if !term.IsTerminal(fd) {
+		return fmt.Errorf("explore must be run in an interactive terminal")
+	}
+	state, err := term.MakeRaw(fd)
+	if err != nil {
+		return err
+	}
+	defer term.Restore(fd, state)
+
+	// use the alternate screen and hide the cursor, restoring both on exit
+	fmt.Fprint(out, "\x1b[?1049h\x1b[?25l")
+	defer fmt.Fprint(out, "\x1b[?25h\x1b[?1049l")
+
+	r := bufio.NewReader(in)
+	m := modeBrowse
+	input := ""
+
+	for {
+		width, height, err := term.GetSize(int(out.Fd()))
+		// some terminals don't report their size
+		if err != nil || width < 20 || height < 6 {
+			width, height = 80, 24
+		}
+		prompt := ""
+		switch m {
+		case modeSearch:
+			prompt = "/" + input
+		case modeExport:
+			prompt = "export remediation script to: " + input
+		}
+		_, err = out.Write(render(e, width, height, prompt))
+		if err != nil {
+			return err
+		}
+
+		k, err := readKey(r)
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		if k == keyCtrlC {
+			return nil
+		}
+
+		switch m {
+		case modeSearch:
+			switch k {
+			case keyEnter:
+				m = modeBrowse
+			case keyEscape:
+				e.Search("")
+				m = modeBrowse
+			case keyBackspace:
+				input = trimLastRune(input)
+				e.Search(input)
+			case keyUp, keyDown:
+				e.Move(map[key]int{keyUp: -1, keyDown: 1}[k])
+			default:
+				if utf8.RuneCountInString(string(k)) == 1 {
+					input += string(k)
+					e.Search(input)
+				}
+			}
+			continue
+
+		case modeExport:
+			switch k {
+			case keyEnter:
+				m = modeBrowse
+				e.Status = export(e, input, opts)
+			case keyEscape:
+				m = modeBrowse
+				e.Status = "export cancelled"
+			case keyBackspace:
+				input = trimLastRune(input)
+			default:
+				if utf8.RuneCountInString(string(k)) == 1 {
+					input += string(k)
+				}
+			}
+			continue
+		}
+
+		e.Status = ""
+		switch k {
+		case "q":
+			return nil
+		case keyUp, "k":
+			e.Move(-1)
+		case keyDown, "j":
+			e.Move(1)
+		case keyPageUp:
+			e.Move(-(height - 5))
+		case keyPageDown:
+			e.Move(height - 5)
+		case keyEnter, keyRight, "l":
+			err = e.Select()
+		case keyLeft, keyBackspace, keyEscape, "h":
+			e.Back()
+		case "/":
+			m = modeSearch
+			input = e.Current().Query
+		case "1", "2", "3", "4":
+			err = e.ShowCategory(int(k[0] - '1'))
+		case "m", " ":
+			e.ToggleMark()
+		case "M":
+			e.ShowMarked()
+		case "x":
+			if len(e.order) == 0 {
+				e.Status = "nothing is marked for removal: press m on an access row to mark it"
+				break
+			}
+			m = modeExport
+			input = opts.ScriptPath
+		}
+		if err != nil {
+			e.Status = err.Error()
+		}
+	}
+}
+
+func trimLastRune(s string) string {
+	if s == "" {
+		return s
+	}
+	_, size := utf8.DecodeLastRuneInString(s)
+	return s[:len(s)-size]
+}
+
+// export writes the marked removals to a remediation script, returning a status message.
+func export(e *Explorer, path string, opts Options) string {
+	if path == "" {
+		return "export cancelled: no file given"
+	}
+	var b bytes.Buffer
+	err := remediation.WriteScript(&b, remediation.ScriptOptions{InstanceARN: opts.InstanceARN, Region: opts.Region, Instances: opts.Instances}, e.Marked())
+	if err != nil {
+		return err.Error()
+	}
+	err = os.WriteFile(path, b.Bytes(), 0755)
+	if err != nil {
+		return err.Error()
+	}
+	return fmt.Sprintf("wrote %d removals to %s", len(e.order), path)
+}
+
+// truncate shortens s to fit in width columns.
+func truncate(s string, width int) string {
+	if width <= 0 {
+		return ""
+	}
+	if utf8.RuneCountInString(s) <= width {
+		return s
+	}
+	runes := []rune(s)
+	if width == 1 {
+		return string(runes[:1])
+	}
+	return string(runes[:width-1]) + "…"
+}
+
+// render draws the current view to fit the terminal.
+func render(e *Explorer, width int, height int, prompt string) []byte {
+	var b bytes.Buffer
+	line := func(s string) {
+		b.WriteString(truncate(s, width))
+		b.WriteString("\x1b[K\r\n")
+	}
+
+	b.WriteString("\x1b[H")
+
+	v := e.Current()
+	visible := v.Visible()
+
+	line("\x1b[1m" + truncate(e.Breadcrumbs(), width-8) + "\x1b[0m")
+	status := fmt.Sprintf("%d of %d", len(visible), len(v.Items))
+	if v.Query != "" {
+		status += fmt.Sprintf(" matching %q", v.Query)
+	}
+	if len(e.order) > 0 {
+		status += fmt.Sprintf("  |  %d marked for removal", len(e.order))
+	}
+	line(status)
+
+	// leave room for the header, the status line and the help line
+	rows := height - 4
+	if rows < 1 {
+		rows = 1
+	}
+	start := 0
+	if v.Cursor >= rows {
+		start = v.Cursor - rows + 1
+	}
+
+	for i := start; i < start+rows; i++ {
+		if i >= len(visible) {
+			line("")
+			continue
+		}
+		item := visible[i]
+		mark := "  "
+		if e.IsMarked(item) {
+			mark = "✗ "
+		}
+		text := mark + item.Label
+		if item.Detail != "" {
+			text += "  \x1b[2m" + item.Detail
+		}
+		text = truncate(text, width+len("\x1b[2m"))
+		if i == v.Cursor {
+			b.WriteString("\x1b[7m" + text + "\x1b[0m\x1b[K\r\n")
+		} else {
+			b.WriteString(text + "\x1b[0m\x1b[K\r\n")
+		}
+	}
+
+	switch {
+	case prompt != "":
+		line(prompt)
+	case e.Status != "":
+		line(e.Status)
+	default:
+		line("")
+	}
+	b.WriteString("\x1b[2m" + truncate(strings.TrimSpace(help), width) + "\x1b[0m\x1b[K")
+	return b.Bytes()
+}
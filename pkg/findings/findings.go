@@ -0,0 +1,152 @@
+// Package findings works out which standing AWS SSO account assignments should be removed,
+// using the same rules as the analyze command.
+package findings
+
+import (
+	"sort"
+	"time"
+
+	"github.com/common-fate/access-inspector/pkg/remediation"
+	"github.com/common-fate/access-inspector/pkg/report"
+	"github.com/common-fate/access-inspector/pkg/risk"
+	"github.com/common-fate/access-inspector/pkg/usage"
+	"github.com/jmoiron/sqlx"
+)
+
+// Status is what will happen to an account assignment.
+type Status string
+
+const (
+	// StatusRemove is standing user access which should be removed.
+	StatusRemove Status = "remove"
+	// StatusGroup is access through a group. Common Fate only manages individual
+	// user access, so these are informational.
+	StatusGroup Status = "skip-group"
+	// StatusCommonFate is access which is also granted through an active Common Fate
+	// Access Request, and is managed by Common Fate.
+	StatusCommonFate Status = "skip-common-fate"
+	// StatusRecentlyUsed is access which has been used within the unused days window.
+	StatusRecentlyUsed Status = "skip-recently-used"
+)
+
+// Finding is standing access held by a user.
+type Finding struct {
+	Status            Status     `json:"status"`
+	AssignmentID      string     `json:"assignmentId"`
+	AccountID         string     `json:"accountId"`
+	AccountName       string     `json:"accountName"`
+	PermissionSetARN  string     `json:"permissionSetArn"`
+	PermissionSetName string     `json:"permissionSetName"`
+	UserID            string     `json:"userId"`
+	UserEmail         string     `json:"userEmail"`
+	GroupID           string     `json:"groupId,omitempty"`
+	GroupName         string     `json:"groupName,omitempty"`
+	Risk              risk.Score `json:"risk"`
+	// RequestID is the Common Fate Access Request granting the same access.
+	RequestID string `json:"requestId,omitempty"`
+	// LastUsed is when the access was last used, if usage data is available.
+	LastUsed *time.Time `json:"lastUsed,omitempty"`
+	// Instance is the instance holding the assignment. It's empty for the default instance.
+	Instance string `json:"instance,omitempty"`
+}
+
+// Key identifies access to a permission set in an account by a user's email address.
+// It's used to match standing access against active Common Fate Access Requests.
+func Key(userEmail, accountID, permissionSetARN string) string {
+	return remediation.Key(remediation.AWSSSO{}, userEmail, map[string]string{"accountId": accountID, "permissionSetArn": permissionSetARN})
+}
+
+// instance returns the instance of an assignment, or an empty string for the default instance.
+func instance(name string) string {
+	if name == report.DefaultInstance {
+		return ""
+	}
+	return name
+}
+
+// Options configure which access is kept.
+type Options struct {
+	// CommonFateAccess maps Key() to the ID of an active Access Request.
+	CommonFateAccess map[string]string
+	// Usage is keyed by usage.Key(). If UnusedDays is set, access used more
+	// recently than UnusedDays ago is kept.
+	Usage      map[string]usage.Usage
+	UnusedDays int
+	Now        time.Time
+}
+
+// Compute lists group assignments and then user assignments, each ordered from highest to lowest risk.
+func Compute(db *sqlx.DB, scorer *risk.Scorer, opts Options) ([]Finding, error) {
+	groupAssignments, err := report.GroupAssignments(db)
+	if err != nil {
+		return nil, err
+	}
+	userAssignments, err := report.UserAssignments(db)
+	if err != nil {
+		return nil, err
+	}
+
+	// rank the findings so that the most dangerous standing access is removed first
+	sort.SliceStable(groupAssignments, func(i, j int) bool {
+		return scorer.Assignment(groupAssignments[i].Account, groupAssignments[i].PermissionSetARN).Value >
+			scorer.Assignment(groupAssignments[j].Account, groupAssignments[j].PermissionSetARN).Value
+	})
+	sort.SliceStable(userAssignments, func(i, j int) bool {
+		return scorer.Assignment(userAssignments[i].Account, userAssignments[i].PermissionSetARN).Value >
+			scorer.Assignment(userAssignments[j].Account, userAssignments[j].PermissionSetARN).Value
+	})
+
+	now := opts.Now
+	if now.IsZero() {
+		now = time.Now()
+	}
+	cutoff := now.AddDate(0, 0, -opts.UnusedDays)
+
+	var findings []Finding
+
+	for _, ga := range groupAssignments {
+		findings = append(findings, Finding{
+			Status:            StatusGroup,
+			AssignmentID:      ga.AccountAssignmentID,
+			AccountID:         ga.Account,
+			AccountName:       ga.AccountName,
+			PermissionSetARN:  ga.PermissionSetARN,
+			PermissionSetName: ga.PermissionSetName,
+			UserID:            ga.UserID,
+			UserEmail:         ga.UserEmail,
+			GroupID:           ga.GroupID,
+			GroupName:         ga.GroupName,
+			Instance:          instance(ga.Instance),
+			Risk:              scorer.Assignment(ga.Account, ga.PermissionSetARN),
+		})
+	}
+
+	for _, ua := range userAssignments {
+		f := Finding{
+			Status:            StatusRemove,
+			AssignmentID:      ua.AccountAssignmentID,
+			AccountID:         ua.Account,
+			AccountName:       ua.AccountName,
+			PermissionSetARN:  ua.PermissionSetARN,
+			PermissionSetName: ua.PermissionSetName,
+			UserID:            ua.UserID,
+			UserEmail:         ua.UserEmail,
+			Instance:          instance(ua.Instance),
+			Risk:              scorer.Assignment(ua.Account, ua.PermissionSetARN),
+		}
+		if u, ok := opts.Usage[usage.Key(ua.UserID, ua.Account, ua.PermissionSetARN)]; ok {
+			lastUsed := u.LastUsed
+			f.LastUsed = &lastUsed
+		}
+
+		if requestID, ok := opts.CommonFateAccess[Key(ua.UserEmail, ua.Account, ua.PermissionSetARN)]; ok {
+			f.Status = StatusCommonFate
+			f.RequestID = requestID
+		} else if opts.UnusedDays > 0 && f.LastUsed != nil && f.LastUsed.After(cutoff) {
+			f.Status = StatusRecentlyUsed
+		}
+		findings = append(findings, f)
+	}
+
+	return findings, nil
+}
@@ -0,0 +1,123 @@
+// Package htmlreport renders a report as a single self-contained HTML file,
+// which can be shared with people who don't have access to the report database.
+package htmlreport
+
+import (
+	_ "embed"
+	"html/template"
+	"io"
+	"time"
+
+	"github.com/common-fate/access-inspector/pkg/report"
+)
+
+//go:embed report.html.tmpl
+var reportTemplate string
+
+// Access is a single entitlement which a user holds.
+type Access struct {
+	AccountID         string
+	AccountName       string
+	PermissionSetARN  string
+	PermissionSetName string
+	// Source is how the access is granted: "direct", "group" or "Common Fate".
+	Source string
+	// Via is the group name or Access Request ID the access is granted through.
+	Via string
+	// Risk is the risk score of the entitlement.
+	Risk       int
+	RiskReason string
+}
+
+// JIT returns true if the access is granted just-in-time through Common Fate.
+func (a Access) JIT() bool {
+	return a.Source == SourceCommonFate
+}
+
+const (
+	SourceDirect     = "direct"
+	SourceGroup      = "group"
+	SourceCommonFate = "Common Fate"
+)
+
+// UserAccess is the effective access of a single user.
+type UserAccess struct {
+	ID     string
+	Email  string
+	Access []Access
+}
+
+// AccountSummary counts the standing and just-in-time entitlements to an account.
+type AccountSummary struct {
+	ID       string
+	Name     string
+	Standing int
+	JIT      int
+}
+
+// Data is everything rendered into the report.
+type Data struct {
+	Title       string
+	GeneratedAt time.Time
+	// Tables are rendered as sortable and filterable tables.
+	Tables   []report.Table
+	Users    []UserAccess
+	Accounts []AccountSummary
+}
+
+// Totals returns the total number of standing and just-in-time entitlements.
+func (d Data) Totals() AccountSummary {
+	var total AccountSummary
+	for _, a := range d.Accounts {
+		total.Standing += a.Standing
+		total.JIT += a.JIT
+	}
+	return total
+}
+
+// maxCount is the largest standing or JIT count across all accounts, used to scale the chart.
+func (d Data) maxCount() int {
+	max := 0
+	for _, a := range d.Accounts {
+		if a.Standing > max {
+			max = a.Standing
+		}
+		if a.JIT > max {
+			max = a.JIT
+		}
+	}
+	return max
+}
+
+// Render writes the report as HTML.
+func Render(w io.Writer, data Data) error {
+	max := data.maxCount()
+
+	funcs := template.FuncMap{
+		// barWidth scales a count to a chart bar width in pixels.
+		"barWidth": func(count int) int {
+			if max == 0 {
+				return 0
+			}
+			return count * 400 / max
+		},
+		"percent": func(count, total int) int {
+			if total == 0 {
+				return 0
+			}
+			return count * 100 / total
+		},
+		"add": func(a, b int) int {
+			return a + b
+		},
+		"mul": func(a, b int) int {
+			return a * b
+		},
+	}
+
+	tmpl, err := template.New("report").Funcs(funcs).Parse(reportTemplate)
+	if err != nil {
+		return err
+	}
+	return tmpl.Execute(w, data)
+}
@@ -0,0 +1,71 @@
+package policy
+
+import (
+	"regexp"
+	"strings"
+)
+
+// readActions are the action name prefixes which AWS treats as read-only
+// in the ReadOnlyAccess managed policy.
+var readActions = Strings{"*:Get*", "*:List*", "*:Describe*", "*:BatchGet*", "*:View*", "*:Search*", "*:Lookup*", "*:Query*", "*:Scan*", "*:Select*", "*:Head*"}
+
+func allow(actions ...string) Document {
+	return Document{
+		Version:   "2012-10-17",
+		Statement: []Statement{{Effect: "Allow", Action: actions, Resource: Strings{"*"}}},
+	}
+}
+
+// managedPolicies approximates commonly used AWS managed policies. The real policies
+// can't be read from the report, so these are used to evaluate permission sets offline.
+var managedPolicies = map[string]Document{
+	"AdministratorAccess": allow("*"),
+	"PowerUserAccess": {
+		Version: "2012-10-17",
+		Statement: []Statement{
+			{Effect: "Allow", NotAction: Strings{"iam:*", "organizations:*", "account:*"}, Resource: Strings{"*"}},
+			{Effect: "Allow", Action: Strings{"iam:CreateServiceLinkedRole", "iam:DeleteServiceLinkedRole", "iam:ListRoles", "organizations:DescribeOrganization", "account:ListRegions"}, Resource: Strings{"*"}},
+		},
+	},
+	"ReadOnlyAccess":               allow(readActions...),
+	"ViewOnlyAccess":               allow("*:List*", "*:Describe*", "*:View*"),
+	"SecurityAudit":                allow("*:Get*", "*:List*", "*:Describe*"),
+	"IAMFullAccess":                allow("iam:*", "organizations:DescribeAccount", "organizations:DescribeOrganization", "organizations:DescribeOrganizationalUnit", "organizations:DescribePolicy", "organizations:ListChildren", "organizations:ListParents", "organizations:ListPoliciesForTarget", "organizations:ListRoots", "organizations:ListPolicies", "organizations:ListTargetsForPolicy"),
+	"IAMReadOnlyAccess":            allow("iam:GenerateCredentialReport", "iam:GenerateServiceLastAccessedDetails", "iam:Get*", "iam:List*", "iam:SimulateCustomPolicy", "iam:SimulatePrincipalPolicy"),
+	"AWSOrganizationsFullAccess":   allow("organizations:*", "account:*"),
+	"Billing":                      allow("aws-portal:*", "billing:*", "budgets:*", "ce:*", "cur:*", "pricing:*", "purchase-orders:*", "tax:*", "account:*", "payments:*", "invoicing:*"),
+	"AWSBillingReadOnlyAccess":     allow("aws-portal:View*", "billing:Get*", "billing:List*", "budgets:View*", "budgets:Describe*", "ce:Get*", "ce:Describe*", "ce:List*", "cur:Describe*", "pricing:*", "payments:Get*", "payments:List*", "tax:Get*", "tax:List*"),
+	"AmazonS3FullAccess":           allow("s3:*", "s3-object-lambda:*"),
+	"AmazonS3ReadOnlyAccess":       allow("s3:Get*", "s3:List*", "s3-object-lambda:Get*", "s3-object-lambda:List*"),
+	"AmazonEC2FullAccess":          allow("ec2:*", "elasticloadbalancing:*", "cloudwatch:*", "autoscaling:*", "iam:CreateServiceLinkedRole"),
+	"AmazonEC2ReadOnlyAccess":      allow("ec2:Describe*", "elasticloadbalancing:Describe*", "cloudwatch:ListMetrics", "cloudwatch:GetMetricStatistics", "cloudwatch:Describe*", "autoscaling:Describe*"),
+	"AWSCloudTrail_FullAccess":     allow("cloudtrail:*"),
+	"AWSCloudTrail_ReadOnlyAccess": allow("cloudtrail:Get*", "cloudtrail:Describe*", "cloudtrail:List*", "cloudtrail:LookupEvents"),
+	"AWSSupportAccess":             allow("support:*"),
+	"SupportUser":                  allow("support:*"),
+	"DatabaseAdministrator":        allow("rds:*", "dynamodb:*", "redshift:*", "elasticache:*", "cloudwatch:*", "logs:*"),
+	"DataScientist":                allow("sagemaker:*", "s3:*", "athena:*", "glue:*"),
+	"NetworkAdministrator":         allow("ec2:*Vpc*", "ec2:*Subnet*", "ec2:*Gateway*", "ec2:*Route*", "ec2:*Address*", "ec2:*NetworkAcl*", "ec2:*Vpn*", "ec2:Describe*", "route53:*", "directconnect:*", "elasticloadbalancing:*"),
+	"SystemAdministrator":          allow("ec2:*", "autoscaling:*", "cloudwatch:*", "logs:*", "ssm:*", "iam:PassRole", "iam:GetRole", "iam:ListRoles"),
+}
+
+// ManagedPolicyName returns the name of an AWS managed policy from its ARN,
+// such as "ReadOnlyAccess" for arn:aws:iam::aws:policy/job-function/ReadOnlyAccess.
+func ManagedPolicyName(arn string) string {
+	return arn[strings.LastIndex(arn, "/")+1:]
+}
+
+// awsManagedPolicyARN matches the ARNs of AWS managed policies, such as arn:aws:iam::aws:policy/ReadOnlyAccess,
+// in any partition. Customer managed policies have the account ID in place of aws.
+var awsManagedPolicyARN = regexp.MustCompile(`^arn:aws[a-z-]*:iam::aws:policy/`)
+
+// ManagedPolicy returns an approximation of an AWS managed policy, looked up by ARN. Policies are only
+// matched by ARN, as a customer managed policy may have the same name as an AWS managed one.
+// The second return value is false if the policy is not known.
+func ManagedPolicy(arn string) (Document, bool) {
+	if !awsManagedPolicyARN.MatchString(arn) {
+		return Document{}, false
+	}
+	doc, ok := managedPolicies[ManagedPolicyName(arn)]
+	return doc, ok
+}
@@ -0,0 +1,205 @@
+// Package policy parses IAM policy documents and evaluates which actions they allow.
+//
+// Allows only considers the Action and NotAction elements of Allow statements, and
+// of Deny statements which apply to every resource without conditions. Scoped Deny
+// statements may leave the action allowed on other resources, so they are ignored
+// and the result is an upper bound on the access which the policy grants. AllowsEverywhere is a lower bound,
+// which only counts Allow statements on every resource without conditions.
+package policy
+
+import (
+	"encoding/json"
+	"strings"
+)
+
+// Document is an IAM policy document.
+type Document struct {
+	Version   string      `json:"Version,omitempty"`
+	Statement []Statement `json:"-"`
+}
+
+type rawStatement struct {
+	Version   string          `json:"Version,omitempty"`
+	Statement json.RawMessage `json:"Statement"`
+}
+
+// Statement is a single statement in a policy document.
+type Statement struct {
+	Effect    string  `json:"Effect"`
+	Action    Strings `json:"Action,omitempty"`
+	NotAction Strings `json:"NotAction,omitempty"`
+	Resource  Strings `json:"Resource,omitempty"`
+	// NotResource and Condition aren't evaluated, but scope the statement.
+	NotResource Strings         `json:"NotResource,omitempty"`
+	Condition   json.RawMessage `json:"Condition,omitempty"`
+}
+
+// unscoped returns true if the statement applies to every resource, without conditions.
+func (s Statement) unscoped() bool {
+	if len(s.NotResource) > 0 || len(s.Condition) > 0 {
+		return false
+	}
+	for _, r := range s.Resource {
+		if r == "*" {
+			return true
+		}
+	}
+	return false
+}
+
+// Strings is a policy element which may be either a single string or a list of strings.
+type Strings []string
+
+func (s *Strings) UnmarshalJSON(b []byte) error {
+	var single string
+	if err := json.Unmarshal(b, &single); err == nil {
+		*s = Strings{single}
+		return nil
+	}
+	var list []string
+	if err := json.Unmarshal(b, &list); err != nil {
+		return err
+	}
+	*s = list
+	return nil
+}
+
+// UnmarshalJSON handles the Statement element being either a single statement or a list.
+func (d *Document) UnmarshalJSON(b []byte) error {
+	var raw rawStatement
+	if err := json.Unmarshal(b, &raw); err != nil {
+		return err
+	}
+	d.Version = raw.Version
+	if len(raw.Statement) == 0 {
+		return nil
+	}
+	var single Statement
+	if err := json.Unmarshal(raw.Statement, &single); err == nil && single.Effect != "" {
+		d.Statement = []Statement{single}
+		return nil
+	}
+	return json.Unmarshal(raw.Statement, &d.Statement)
+}
+
+func (d Document) MarshalJSON() ([]byte, error) {
+	return json.Marshal(struct {
+		Version   string      `json:"Version,omitempty"`
+		Statement []Statement `json:"Statement"`
+	}{d.Version, d.Statement})
+}
+
+// Parse parses a policy document. Documents which have been JSON-encoded
+// into a string (as happens when a provider returns the policy as a string field)
+// are decoded first.
+func Parse(s string) (Document, error) {
+	var doc Document
+	var encoded string
+	if err := json.Unmarshal([]byte(s), &encoded); err == nil {
+		s = encoded
+	}
+	err := json.Unmarshal([]byte(s), &doc)
+	return doc, err
+}
+
+// Allows returns true if the document allows the action and doesn't deny it everywhere.
+func (d Document) Allows(action string) bool {
+	return Set{d}.Allows(action)
+}
+
+// Matches returns true if the statement applies to the action.
+func (s Statement) Matches(action string) bool {
+	if len(s.NotAction) > 0 {
+		for _, pattern := range s.NotAction {
+			if MatchAction(pattern, action) {
+				return false
+			}
+		}
+		return true
+	}
+	for _, pattern := range s.Action {
+		if MatchAction(pattern, action) {
+			return true
+		}
+	}
+	return false
+}
+
+// MatchAction matches an IAM action such as "s3:GetObject" against a pattern
+// which may contain '*' and '?' wildcards. Matching is case-insensitive, as it is in IAM.
+func MatchAction(pattern, action string) bool {
+	return wildcardMatch(strings.ToLower(pattern), strings.ToLower(action))
+}
+
+func wildcardMatch(pattern, s string) bool {
+	// iterative glob matching with backtracking on the last '*'
+	p, i := 0, 0
+	star, match := -1, 0
+	for i < len(s) {
+		switch {
+		case p < len(pattern) && (pattern[p] == '?' || pattern[p] == s[i]):
+			p++
+			i++
+		case p < len(pattern) && pattern[p] == '*':
+			star = p
+			match = i
+			p++
+		case star != -1:
+			p = star + 1
+			match++
+			i = match
+		default:
+			return false
+		}
+	}
+	for p < len(pattern) && pattern[p] == '*' {
+		p++
+	}
+	return p == len(pattern)
+}
+
+// Set is a collection of policy documents which apply together, such as
+// the managed and inline policies attached to a permission set.
+type Set []Document
+
+// Allows returns true if any document allows the action and none deny it on every resource
+// without conditions. Deny statements scoped to resources or conditions aren't counted.
+func (s Set) Allows(action string) bool {
+	allowed := false
+	for _, d := range s {
+		for _, st := range d.Statement {
+			if !st.Matches(action) {
+				continue
+			}
+			if strings.EqualFold(st.Effect, "Deny") {
+				if st.unscoped() {
+					return false
+				}
+				continue
+			}
+			allowed = true
+		}
+	}
+	return allowed
+}
+
+// AllowsEverywhere returns true if the action is allowed on every resource without conditions.
+// Allow statements scoped to resources or conditions aren't counted, and any Deny statement
+// matching the action prevents it, even if it is scoped, so the result is a lower bound.
+func (s Set) AllowsEverywhere(action string) bool {
+	allowed := false
+	for _, d := range s {
+		for _, st := range d.Statement {
+			if !st.Matches(action) {
+				continue
+			}
+			if strings.EqualFold(st.Effect, "Deny") {
+				return false
+			}
+			if st.unscoped() {
+				allowed = true
+			}
+		}
+	}
+	return allowed
+}
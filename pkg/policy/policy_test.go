@@ -0,0 +1,179 @@
+package policy
+
+import "testing"
+
+func TestMatchAction(t *testing.T) {
+	tests := []struct {
+		pattern string
+		action  string
+		want    bool
+	}{
+		{"*", "s3:GetObject", true},
+		{"s3:*", "s3:GetObject", true},
+		{"s3:*", "s3-object-lambda:GetObject", false},
+		{"s3:Get*", "s3:GetObject", true},
+		{"s3:Get*", "s3:PutObject", false},
+		{"*:List*", "iam:ListRoles", true},
+		{"ec2:*Vpc*", "ec2:CreateVpcEndpoint", true},
+		{"iam:?etRole", "iam:GetRole", true},
+		{"iam:?etRole", "iam:GetRolePolicy", false},
+		// matching is case-insensitive, as it is in IAM
+		{"S3:getobject", "s3:GetObject", true},
+		{"s3:GetObject", "s3:GetObjectAcl", false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.pattern+" "+tt.action, func(t *testing.T) {
+			if got := MatchAction(tt.pattern, tt.action); got != tt.want {
+				t.Errorf("MatchAction(%q, %q) = %v, want %v", tt.pattern, tt.action, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestParse(t *testing.T) {
+	tests := []struct {
+		name       string
+		doc        string
+		statements int
+	}{
+		{"list", `{"Version": "2012-10-17", "Statement": [{"Effect": "Allow", "Action": "s3:*", "Resource": "*"}, {"Effect": "Deny", "Action": ["s3:DeleteBucket"], "Resource": "*"}]}`, 2},
+		{"single statement", `{"Version": "2012-10-17", "Statement": {"Effect": "Allow", "Action": "s3:*", "Resource": "*"}}`, 1},
+		{"encoded as a string", `"{\"Statement\": {\"Effect\": \"Allow\", \"Action\": \"s3:*\", \"Resource\": \"*\"}}"`, 1},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			doc, err := Parse(tt.doc)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if len(doc.Statement) != tt.statements {
+				t.Errorf("got %d statements, want %d", len(doc.Statement), tt.statements)
+			}
+			if !doc.Allows("s3:GetObject") {
+				t.Error("expected the document to allow s3:GetObject")
+			}
+		})
+	}
+}
+
+func TestAllows(t *testing.T) {
+	parse := func(s string) Document {
+		doc, err := Parse(s)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return doc
+	}
+
+	tests := []struct {
+		name   string
+		set    Set
+		action string
+		// allows is the upper bound from Allows, and everywhere the lower bound from AllowsEverywhere
+		allows     bool
+		everywhere bool
+	}{
+		{
+			name:       "administrator",
+			set:        Set{managedPolicies["AdministratorAccess"]},
+			action:     "iam:CreateUser",
+			allows:     true,
+			everywhere: true,
+		},
+		{
+			name:   "action not allowed",
+			set:    Set{managedPolicies["ReadOnlyAccess"]},
+			action: "s3:PutObject",
+		},
+		{
+			name:   "NotAction excludes the action",
+			set:    Set{managedPolicies["PowerUserAccess"]},
+			action: "iam:CreateUser",
+		},
+		{
+			name:       "NotAction allows other actions",
+			set:        Set{managedPolicies["PowerUserAccess"]},
+			action:     "s3:PutObject",
+			allows:     true,
+			everywhere: true,
+		},
+		{
+			name:       "allowed by a second statement after NotAction",
+			set:        Set{managedPolicies["PowerUserAccess"]},
+			action:     "iam:ListRoles",
+			allows:     true,
+			everywhere: true,
+		},
+		{
+			name:   "Deny on every resource",
+			set:    Set{managedPolicies["AdministratorAccess"], parse(`{"Statement": {"Effect": "Deny", "Action": "iam:*", "Resource": "*"}}`)},
+			action: "iam:CreateUser",
+		},
+		{
+			name:   "Deny with NotAction",
+			set:    Set{parse(`{"Statement": [{"Effect": "Allow", "Action": "*", "Resource": "*"}, {"Effect": "Deny", "NotAction": "s3:*", "Resource": "*"}]}`)},
+			action: "iam:CreateUser",
+		},
+		{
+			// the action is still allowed on other resources
+			name:   "Deny scoped to a resource",
+			set:    Set{managedPolicies["AdministratorAccess"], parse(`{"Statement": {"Effect": "Deny", "Action": "iam:*", "Resource": "arn:aws:iam::*:role/breakglass"}}`)},
+			action: "iam:CreateUser",
+			allows: true,
+		},
+		{
+			name:   "Deny with a condition",
+			set:    Set{parse(`{"Statement": [{"Effect": "Allow", "Action": "*", "Resource": "*"}, {"Effect": "Deny", "Action": "*", "Resource": "*", "Condition": {"StringNotEquals": {"aws:RequestedRegion": "eu-west-1"}}}]}`)},
+			action: "ec2:RunInstances",
+			allows: true,
+		},
+		{
+			name:   "Allow scoped to a resource",
+			set:    Set{parse(`{"Statement": {"Effect": "Allow", "Action": "s3:*", "Resource": "arn:aws:s3:::logs/*"}}`)},
+			action: "s3:PutObject",
+			allows: true,
+		},
+		{
+			name:   "Allow with NotResource",
+			set:    Set{parse(`{"Statement": {"Effect": "Allow", "Action": "s3:*", "NotResource": "arn:aws:s3:::secrets/*"}}`)},
+			action: "s3:GetObject",
+			allows: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := tt.set.Allows(tt.action); got != tt.allows {
+				t.Errorf("Allows(%q) = %v, want %v", tt.action, got, tt.allows)
+			}
+			if got := tt.set.AllowsEverywhere(tt.action); got != tt.everywhere {
+				t.Errorf("AllowsEverywhere(%q) = %v, want %v", tt.action, got, tt.everywhere)
+			}
+			if len(tt.set) == 1 {
+				if got := tt.set[0].Allows(tt.action); got != tt.allows {
+					t.Errorf("Document.Allows(%q) = %v, want %v", tt.action, got, tt.allows)
+				}
+			}
+		})
+	}
+}
+
+func TestManagedPolicy(t *testing.T) {
+	tests := []struct {
+		arn  string
+		want bool
+	}{
+		{"arn:aws:iam::aws:policy/AdministratorAccess", true},
+		{"arn:aws:iam::aws:policy/job-function/ViewOnlyAccess", true},
+		{"arn:aws-us-gov:iam::aws:policy/ReadOnlyAccess", true},
+		// a customer managed policy with the same name as an AWS managed one
+		{"arn:aws:iam::123456789012:policy/AdministratorAccess", false},
+		{"arn:aws:iam::aws:policy/SomeNewPolicy", false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.arn, func(t *testing.T) {
+			if _, ok := ManagedPolicy(tt.arn); ok != tt.want {
+				t.Errorf("ManagedPolicy(%q) found %v, want %v", tt.arn, ok, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,73 @@
+package query
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"strings"
+	"text/tabwriter"
+
+	"github.com/common-fate/access-inspector/pkg/report"
+)
+
+// Format is an output format for query results.
+type Format string
+
+const (
+	FormatTable Format = "table"
+	FormatJSON  Format = "json"
+	FormatCSV   Format = "csv"
+)
+
+// Formats are the supported output formats.
+var Formats = []Format{FormatTable, FormatJSON, FormatCSV}
+
+// ParseFormat parses an output format name.
+func ParseFormat(s string) (Format, error) {
+	for _, f := range Formats {
+		if string(f) == strings.ToLower(s) {
+			return f, nil
+		}
+	}
+	return "", fmt.Errorf("unsupported output format %q: must be one of %v", s, Formats)
+}
+
+// Write writes query results in the given format. JSON output is an array
+// with an object for each row, keyed by column name.
+func Write(w io.Writer, t report.Table, f Format) error {
+	switch f {
+	case FormatTable:
+		tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+		fmt.Fprintln(tw, strings.Join(t.Columns, "\t"))
+		for _, row := range t.Rows {
+			fmt.Fprintln(tw, strings.Join(row, "\t"))
+		}
+		return tw.Flush()
+	case FormatJSON:
+		rows := []map[string]string{}
+		for _, row := range t.Rows {
+			obj := map[string]string{}
+			for i, col := range t.Columns {
+				obj[col] = row[i]
+			}
+			rows = append(rows, obj)
+		}
+		enc := json.NewEncoder(w)
+		enc.SetIndent("", "  ")
+		return enc.Encode(rows)
+	case FormatCSV:
+		cw := csv.NewWriter(w)
+		err := cw.Write(t.Columns)
+		if err != nil {
+			return err
+		}
+		err = cw.WriteAll(t.Rows)
+		if err != nil {
+			return err
+		}
+		cw.Flush()
+		return cw.Error()
+	}
+	return fmt.Errorf("unsupported output format %q", f)
+}
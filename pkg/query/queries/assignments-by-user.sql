@@ -0,0 +1,34 @@
+-- description: every account assignment a user holds, directly or through a group they are a direct member of
+-- param: user (required) the user ID or email address
+SELECT
+    accountassignment.id,
+    accountassignment.account,
+    account.name as account_name,
+    accountassignment.permission_set as permission_set_arn,
+    permissionset.name as permission_set_name,
+    'direct' as source,
+    '' as group_name,
+    accountassignment.instance
+FROM accountassignment
+INNER JOIN account ON accountassignment.account = account.id AND account.instance = accountassignment.instance
+INNER JOIN permissionset ON accountassignment.permission_set = permissionset.id AND permissionset.instance = accountassignment.instance
+INNER JOIN user ON accountassignment."user" = user.id AND user.instance = accountassignment.instance
+WHERE user.id = :user OR user.email = :user
+UNION ALL
+SELECT
+    accountassignment.id,
+    accountassignment.account,
+    account.name as account_name,
+    accountassignment.permission_set as permission_set_arn,
+    permissionset.name as permission_set_name,
+    'group' as source,
+    "group".name as group_name,
+    accountassignment.instance
+FROM accountassignment
+INNER JOIN account ON accountassignment.account = account.id AND account.instance = accountassignment.instance
+INNER JOIN permissionset ON accountassignment.permission_set = permissionset.id AND permissionset.instance = accountassignment.instance
+INNER JOIN groupmembership ON accountassignment."group" = groupmembership."group" AND groupmembership.instance = accountassignment.instance
+INNER JOIN user ON groupmembership."user" = user.id AND user.instance = groupmembership.instance
+INNER JOIN "group" ON groupmembership."group" = "group".id AND "group".instance = groupmembership.instance
+WHERE user.id = :user OR user.email = :user
+ORDER BY account_name, permission_set_name
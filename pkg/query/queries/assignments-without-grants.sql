@@ -0,0 +1,19 @@
+-- description: standing access which isn't covered by an active Common Fate grant (requires import-requests to have been run)
+SELECT
+    v_effective_access.user_email,
+    v_effective_access.account_id,
+    v_effective_access.account_name,
+    v_effective_access.permission_set_name,
+    v_effective_access.source,
+    v_effective_access.group_name,
+    v_effective_access.assignment_id,
+    v_effective_access.instance
+FROM v_effective_access
+WHERE NOT EXISTS (
+    SELECT 1 FROM v_commonfate_aws_sso_requests
+    WHERE v_commonfate_aws_sso_requests.grant_status = 'ACTIVE'
+    AND lower(v_commonfate_aws_sso_requests.requestor_email) = lower(v_effective_access.user_email)
+    AND v_commonfate_aws_sso_requests.account_id = v_effective_access.account_id
+    AND v_commonfate_aws_sso_requests.permission_set_arn = v_effective_access.permission_set_arn
+)
+ORDER BY v_effective_access.account_name, v_effective_access.permission_set_name, v_effective_access.user_email
@@ -0,0 +1,15 @@
+-- description: AWS SSO account assignments made directly to users
+SELECT
+    accountassignment.id,
+    accountassignment.account,
+    account.name as account_name,
+    accountassignment.permission_set as permission_set_arn,
+    permissionset.name as permission_set_name,
+    user.id as user_id,
+    user.email,
+    accountassignment.instance
+FROM accountassignment
+INNER JOIN account ON accountassignment.account = account.id AND account.instance = accountassignment.instance
+INNER JOIN permissionset ON accountassignment.permission_set = permissionset.id AND permissionset.instance = accountassignment.instance
+INNER JOIN user ON accountassignment."user" = user.id AND user.instance = accountassignment.instance
+ORDER BY account.name, permissionset.name, user.email
@@ -0,0 +1,19 @@
+-- description: AWS SSO account assignments made to groups, with one row for each member of the group
+SELECT
+    accountassignment.id,
+    accountassignment.account,
+    account.name as account_name,
+    accountassignment.permission_set as permission_set_arn,
+    permissionset.name as permission_set_name,
+    "group".id as group_id,
+    "group".name as group_name,
+    user.id as user_id,
+    user.email,
+    accountassignment.instance
+FROM accountassignment
+INNER JOIN account ON accountassignment.account = account.id AND account.instance = accountassignment.instance
+INNER JOIN permissionset ON accountassignment.permission_set = permissionset.id AND permissionset.instance = accountassignment.instance
+INNER JOIN groupmembership ON accountassignment."group" = groupmembership."group" AND groupmembership.instance = accountassignment.instance
+INNER JOIN user ON groupmembership."user" = user.id AND user.instance = groupmembership.instance
+INNER JOIN "group" ON groupmembership."group" = "group".id AND "group".instance = groupmembership.instance
+ORDER BY account.name, permissionset.name, "group".name, user.email
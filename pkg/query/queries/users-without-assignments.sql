@@ -0,0 +1,18 @@
+-- description: users with no account assignments, either directly or through a group they are a direct member of
+SELECT
+    user.id,
+    user.name,
+    user.email,
+    user.instance
+FROM user
+WHERE NOT EXISTS (
+    SELECT 1 FROM accountassignment
+    WHERE accountassignment."user" = user.id AND accountassignment.instance = user.instance
+)
+AND NOT EXISTS (
+    SELECT 1
+    FROM groupmembership
+    INNER JOIN accountassignment ON accountassignment."group" = groupmembership."group" AND accountassignment.instance = groupmembership.instance
+    WHERE groupmembership."user" = user.id AND groupmembership.instance = user.instance
+)
+ORDER BY user.email
@@ -0,0 +1,198 @@
+// Package query runs named, parameterised SQL queries against a report.
+// Queries are .sql files: leading comments declare a description and parameters,
+// and parameters are referenced in the SQL as :name.
+//
+//	-- description: every user with access to an account
+//	-- param: account (required) the account ID or name
+//	SELECT ... WHERE account.id = :account
+package query
+
+import (
+	"database/sql"
+	"embed"
+	"fmt"
+	"io/fs"
+	"os"
+	"path"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/common-fate/access-inspector/pkg/report"
+	"github.com/jmoiron/sqlx"
+	"github.com/pkg/errors"
+)
+
+//go:embed queries/*.sql
+var builtin embed.FS
+
+// Param is a parameter accepted by a query.
+type Param struct {
+	Name        string `json:"name"`
+	Description string `json:"description,omitempty"`
+	Required    bool   `json:"required"`
+}
+
+// Query is a named SQL query.
+type Query struct {
+	Name        string  `json:"name"`
+	Description string  `json:"description,omitempty"`
+	Params      []Param `json:"params,omitempty"`
+	SQL         string  `json:"sql"`
+	// Source is the file the query was loaded from, or "builtin".
+	Source string `json:"source"`
+}
+
+// Parse parses the comments at the start of a query file.
+func Parse(name string, src string) (Query, error) {
+	q := Query{Name: name, SQL: src}
+	for _, line := range strings.Split(src, "\n") {
+		line = strings.TrimSpace(line)
+		if line == "" {
+			continue
+		}
+		if !strings.HasPrefix(line, "--") {
+			break
+		}
+		key, value, ok := strings.Cut(strings.TrimSpace(strings.TrimPrefix(line, "--")), ":")
+		if !ok {
+			continue
+		}
+		value = strings.TrimSpace(value)
+		switch strings.TrimSpace(key) {
+		case "description":
+			q.Description = value
+		case "param":
+			paramName, description, _ := strings.Cut(value, " ")
+			if paramName == "" {
+				return q, fmt.Errorf("query %s has a param with no name", name)
+			}
+			p := Param{Name: paramName}
+			description = strings.TrimSpace(description)
+			if strings.HasPrefix(description, "(required)") {
+				p.Required = true
+				description = strings.TrimSpace(strings.TrimPrefix(description, "(required)"))
+			}
+			p.Description = description
+			q.Params = append(q.Params, p)
+		}
+	}
+	return q, nil
+}
+
+// Library is a set of queries, keyed by name.
+type Library map[string]Query
+
+// Builtin loads the queries shipped with the tool.
+func Builtin() (Library, error) {
+	lib := Library{}
+	err := lib.load(builtin, "queries", "builtin")
+	return lib, err
+}
+
+// Load loads the built-in queries and then the queries in dir, if it is set.
+// Queries in dir replace built-in queries with the same name.
+func Load(dir string) (Library, error) {
+	lib, err := Builtin()
+	if err != nil {
+		return nil, err
+	}
+	if dir == "" {
+		return lib, nil
+	}
+	err = lib.load(os.DirFS(dir), ".", dir)
+	if err != nil {
+		return nil, errors.Wrapf(err, "loading queries from %s", dir)
+	}
+	return lib, nil
+}
+
+func (l Library) load(fsys fs.FS, dir string, source string) error {
+	entries, err := fs.ReadDir(fsys, dir)
+	if err != nil {
+		return err
+	}
+	for _, e := range entries {
+		if e.IsDir() || filepath.Ext(e.Name()) != ".sql" {
+			continue
+		}
+		b, err := fs.ReadFile(fsys, path.Join(dir, e.Name()))
+		if err != nil {
+			return err
+		}
+		name := strings.TrimSuffix(e.Name(), ".sql")
+		q, err := Parse(name, string(b))
+		if err != nil {
+			return err
+		}
+		q.Source = source
+		if source != "builtin" {
+			q.Source = filepath.Join(source, e.Name())
+		}
+		l[name] = q
+	}
+	return nil
+}
+
+// Sorted lists the queries by name.
+func (l Library) Sorted() []Query {
+	var queries []Query
+	for _, q := range l {
+		queries = append(queries, q)
+	}
+	sort.Slice(queries, func(i, j int) bool {
+		return queries[i].Name < queries[j].Name
+	})
+	return queries
+}
+
+// Get returns the query with the given name.
+func (l Library) Get(name string) (Query, error) {
+	q, ok := l[name]
+	if !ok {
+		var names []string
+		for _, q := range l.Sorted() {
+			names = append(names, q.Name)
+		}
+		return q, fmt.Errorf("no query named %q: must be one of %s", name, strings.Join(names, ", "))
+	}
+	return q, nil
+}
+
+// Run runs a query with the given parameters. Every declared parameter is passed to the
+// query, as NULL if it isn't set, and an error is returned if a required parameter is missing
+// or a parameter is given which the query doesn't declare.
+func Run(db *sqlx.DB, q Query, params map[string]string) (report.Table, error) {
+	declared := map[string]bool{}
+	var args []any
+	for _, p := range q.Params {
+		declared[p.Name] = true
+		v, ok := params[p.Name]
+		if !ok {
+			if p.Required {
+				return report.Table{}, fmt.Errorf("query %s requires the --%s parameter", q.Name, p.Name)
+			}
+			args = append(args, sql.Named(p.Name, nil))
+			continue
+		}
+		args = append(args, sql.Named(p.Name, v))
+	}
+	for k := range params {
+		if !declared[k] {
+			return report.Table{}, fmt.Errorf("query %s has no parameter named %s", q.Name, k)
+		}
+	}
+
+	t, err := report.QueryTable(db, q.Name, q.SQL, args...)
+	if err != nil {
+		// the built-in queries join resources within an instance, which reports scanned
+		// before several instances were supported don't record
+		if q.Source == "builtin" {
+			if scoped, _ := report.ColumnExists(db, "accountassignment", report.InstanceColumn); !scoped {
+				return t, errors.Wrapf(err, "running query %s (the report doesn't record the instance of its resources: scan the provider again to use the built-in queries)", q.Name)
+			}
+		}
+		return t, errors.Wrapf(err, "running query %s", q.Name)
+	}
+	return t, nil
+}
@@ -0,0 +1,131 @@
+// Package recommend finds users whose observed API activity could be performed
+// with a less privileged permission set than the one they hold.
+package recommend
+
+import (
+	"sort"
+
+	"github.com/common-fate/access-inspector/pkg/cloudtrail"
+	"github.com/common-fate/access-inspector/pkg/risk"
+	"github.com/common-fate/access-inspector/pkg/usage"
+)
+
+// Recommendation is a suggestion to downgrade a user's permission set in an account.
+type Recommendation struct {
+	UserID    string `json:"userId"`
+	AccountID string `json:"accountId"`
+	// Current is the permission set the user holds.
+	Current risk.PermissionSet `json:"current"`
+	// Recommended is the least privileged permission set which covers every observed action.
+	Recommended risk.PermissionSet `json:"recommended"`
+	// Candidates are every permission set less privileged than Current which covers
+	// the observed actions, from least to most privileged.
+	Candidates []risk.PermissionSet `json:"candidates"`
+	// ObservedActions are the distinct IAM actions the user performed with the current permission set.
+	ObservedActions []string `json:"observedActions"`
+}
+
+// Compute attributes API calls in the CloudTrail events to users, accounts and permission sets,
+// and recommends a less privileged permission set wherever one covers every action observed.
+func Compute(scorer *risk.Scorer, resolver *usage.Resolver, events []cloudtrail.Event) []Recommendation {
+	type observed struct {
+		userID    string
+		accountID string
+		psARN     string
+		actions   map[string]bool
+	}
+
+	byKey := map[string]*observed{}
+	var keys []string
+
+	for _, e := range events {
+		a, ok := e.Activity()
+		if !ok || a.SignIn {
+			continue
+		}
+		userID, psARN, ok := resolver.Resolve(a)
+		if !ok {
+			continue
+		}
+		key := usage.Key(userID, a.AccountID, psARN)
+		o, ok := byKey[key]
+		if !ok {
+			o = &observed{userID: userID, accountID: a.AccountID, psARN: psARN, actions: map[string]bool{}}
+			byKey[key] = o
+			keys = append(keys, key)
+		}
+		o.actions[a.Action] = true
+	}
+
+	// candidates are only permission sets whose policies are fully known,
+	// so that we never recommend a permission set which might not cover the actions
+	var candidates []risk.PermissionSet
+	for _, ps := range scorer.Ranked() {
+		if ps.Evaluated && len(ps.Policies) > 0 {
+			candidates = append(candidates, ps)
+		}
+	}
+	// least privileged first
+	sort.SliceStable(candidates, func(i, j int) bool {
+		return candidates[i].Score < candidates[j].Score
+	})
+
+	var recommendations []Recommendation
+
+	for _, k := range keys {
+		o := byKey[k]
+		current, ok := scorer.PermissionSets[o.psARN]
+		if !ok {
+			continue
+		}
+
+		var actions []string
+		for a := range o.actions {
+			actions = append(actions, a)
+		}
+		sort.Strings(actions)
+
+		rec := Recommendation{
+			UserID:          o.userID,
+			AccountID:       o.accountID,
+			Current:         current,
+			ObservedActions: actions,
+		}
+
+		for _, c := range candidates {
+			if c.ARN == current.ARN || c.Score >= current.Score {
+				continue
+			}
+			if covers(c, actions) {
+				rec.Candidates = append(rec.Candidates, c)
+			}
+		}
+
+		if len(rec.Candidates) == 0 {
+			continue
+		}
+		rec.Recommended = rec.Candidates[0]
+		recommendations = append(recommendations, rec)
+	}
+
+	// recommend the largest reductions in privilege first
+	sort.SliceStable(recommendations, func(i, j int) bool {
+		return recommendations[i].reduction() > recommendations[j].reduction()
+	})
+	return recommendations
+}
+
+func (r Recommendation) reduction() int {
+	return r.Current.Score - r.Recommended.Score
+}
+
+// covers returns true if the permission set allows every action. Statements scoped to resources or
+// conditions aren't counted, as the observed actions may have been on other resources or failed the conditions.
+func covers(ps risk.PermissionSet, actions []string) bool {
+	for _, a := range actions {
+		if !ps.Policies.AllowsEverywhere(a) {
+			return false
+		}
+	}
+	return true
+}
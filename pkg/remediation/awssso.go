@@ -0,0 +1,74 @@
+package remediation
+
+import (
+	"context"
+	"fmt"
+)
+
+// AccountAssignment is an AWS SSO account assignment.
+type AccountAssignment struct {
+	AccountID        string
+	PermissionSetARN string
+	PrincipalType    PrincipalType
+	PrincipalID      string
+	// InstanceARN and Region are the AWS SSO instance holding the assignment, if it was given by its entitlement.
+	InstanceARN string
+	Region      string
+}
+
+// AWSSSOBackend makes changes to AWS SSO.
+type AWSSSOBackend interface {
+	DeleteAccountAssignment(ctx context.Context, a AccountAssignment) error
+	CreateAccountAssignment(ctx context.Context, a AccountAssignment) error
+}
+
+// AWSSSO removes and restores AWS SSO account assignments made to users and groups.
+// Its entitlements have accountId and permissionSetArn fields, and optional instanceArn
+// and region fields giving the instance holding the assignment when several are in use.
+type AWSSSO struct {
+	Backend AWSSSOBackend
+}
+
+func (AWSSSO) Name() string { return "aws-sso" }
+
+func (AWSSSO) Fields() []string { return []string{"accountId", "permissionSetArn"} }
+
+func (p AWSSSO) Validate(e Entitlement) error {
+	return validate(p, e, PrincipalUser, PrincipalGroup)
+}
+
+func (AWSSSO) Describe(e Entitlement) string {
+	return fmt.Sprintf("%s access to %s (%v) with role %s", e.principal(), e.Label("accountId"), e.Fields["accountId"], e.Label("permissionSetArn"))
+}
+
+func assignment(e Entitlement) AccountAssignment {
+	return AccountAssignment{
+		AccountID:        e.Fields["accountId"],
+		PermissionSetARN: e.Fields["permissionSetArn"],
+		PrincipalType:    e.PrincipalType,
+		PrincipalID:      e.PrincipalID,
+		InstanceARN:      e.Fields["instanceArn"],
+		Region:           e.Fields["region"],
+	}
+}
+
+func (p AWSSSO) Remove(ctx context.Context, e Entitlement) error {
+	return p.Backend.DeleteAccountAssignment(ctx, assignment(e))
+}
+
+func (p AWSSSO) Restore(ctx context.Context, e Entitlement) error {
+	return p.Backend.CreateAccountAssignment(ctx, assignment(e))
+}
+
+// Entitlement converts a removal to an AWS SSO entitlement.
+func (r Removal) Entitlement() Entitlement {
+	return Entitlement{
+		Provider:      AWSSSO{}.Name(),
+		PrincipalType: r.PrincipalType,
+		PrincipalID:   r.PrincipalID,
+		PrincipalName: r.PrincipalName,
+		Fields:        map[string]string{"accountId": r.AccountID, "permissionSetArn": r.PermissionSetARN},
+		Labels:        map[string]string{"accountId": r.AccountName, "permissionSetArn": r.PermissionSetName},
+		Comment:       r.Comment,
+	}
+}
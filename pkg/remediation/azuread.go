@@ -0,0 +1,65 @@
+package remediation
+
+import (
+	"context"
+	"fmt"
+)
+
+// RoleAssignment is an Azure AD directory role assignment.
+type RoleAssignment struct {
+	// ID is the ID of the assignment, which is needed to delete it. Restored assignments are given a new ID.
+	ID               string
+	RoleDefinitionID string
+	// DirectoryScopeID is the scope of the assignment, such as / for the whole directory.
+	DirectoryScopeID string
+	PrincipalID      string
+}
+
+// AzureADBackend makes changes to Azure AD.
+type AzureADBackend interface {
+	DeleteRoleAssignment(ctx context.Context, a RoleAssignment) error
+	CreateRoleAssignment(ctx context.Context, a RoleAssignment) error
+}
+
+// AzureAD removes and restores Azure AD directory role assignments.
+// Its entitlements have roleDefinitionId and directoryScopeId fields, and a
+// roleAssignmentId field which is needed to remove them.
+type AzureAD struct {
+	Backend AzureADBackend
+}
+
+func (AzureAD) Name() string { return "azure-ad" }
+
+func (AzureAD) Fields() []string { return []string{"roleDefinitionId", "directoryScopeId"} }
+
+func (p AzureAD) Validate(e Entitlement) error {
+	err := validate(p, e, PrincipalUser, PrincipalGroup, PrincipalServiceAccount)
+	if err != nil {
+		return err
+	}
+	if e.Fields["roleAssignmentId"] == "" {
+		return fmt.Errorf("azure-ad entitlements must have a roleAssignmentId field")
+	}
+	return nil
+}
+
+func (AzureAD) Describe(e Entitlement) string {
+	return fmt.Sprintf("%s role %s (%s) in scope %s", e.principal(), e.Label("roleDefinitionId"), e.Fields["roleDefinitionId"], e.Label("directoryScopeId"))
+}
+
+func roleAssignment(e Entitlement) RoleAssignment {
+	return RoleAssignment{
+		ID:               e.Fields["roleAssignmentId"],
+		RoleDefinitionID: e.Fields["roleDefinitionId"],
+		DirectoryScopeID: e.Fields["directoryScopeId"],
+		PrincipalID:      e.PrincipalID,
+	}
+}
+
+func (p AzureAD) Remove(ctx context.Context, e Entitlement) error {
+	return p.Backend.DeleteRoleAssignment(ctx, roleAssignment(e))
+}
+
+func (p AzureAD) Restore(ctx context.Context, e Entitlement) error {
+	return p.Backend.CreateRoleAssignment(ctx, roleAssignment(e))
+}
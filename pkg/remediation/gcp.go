@@ -0,0 +1,85 @@
+package remediation
+
+import (
+	"context"
+	"fmt"
+	"strings"
+)
+
+// IAMBinding is a member's role on a GCP resource.
+type IAMBinding struct {
+	// Resource is a project, folder or organization, such as projects/my-project.
+	Resource string
+	Role     string
+	// Member is the member in IAM policy format, such as user:alice@example.com.
+	Member string
+}
+
+// GCPBackend makes changes to GCP IAM policies.
+type GCPBackend interface {
+	RemoveIAMBinding(ctx context.Context, b IAMBinding) error
+	AddIAMBinding(ctx context.Context, b IAMBinding) error
+}
+
+// GCP removes and restores GCP IAM bindings on projects, folders and organizations.
+// Its entitlements are held by principals identified by their email address, and
+// have resource and role fields.
+type GCP struct {
+	Backend GCPBackend
+}
+
+func (GCP) Name() string { return "gcp" }
+
+func (GCP) Fields() []string { return []string{"resource", "role"} }
+
+// gcpResourceTypes are the types of resource whose IAM bindings can be changed.
+var gcpResourceTypes = []string{"projects", "folders", "organizations"}
+
+// splitResource splits a resource such as projects/my-project into its type and ID.
+func splitResource(resource string) (string, string, error) {
+	t, id, ok := strings.Cut(resource, "/")
+	if ok && id != "" {
+		for _, rt := range gcpResourceTypes {
+			if t == rt {
+				return t, id, nil
+			}
+		}
+	}
+	return "", "", fmt.Errorf("unsupported GCP resource %q: must be in the format projects/<ID>, folders/<ID> or organizations/<ID>", resource)
+}
+
+func (p GCP) Validate(e Entitlement) error {
+	err := validate(p, e, PrincipalUser, PrincipalGroup, PrincipalServiceAccount)
+	if err != nil {
+		return err
+	}
+	_, _, err = splitResource(e.Fields["resource"])
+	return err
+}
+
+func (GCP) Describe(e Entitlement) string {
+	return fmt.Sprintf("%s role %s on %s", e.principal(), e.Label("role"), e.Label("resource"))
+}
+
+func binding(e Entitlement) IAMBinding {
+	prefix := "user"
+	switch e.PrincipalType {
+	case PrincipalGroup:
+		prefix = "group"
+	case PrincipalServiceAccount:
+		prefix = "serviceAccount"
+	}
+	return IAMBinding{
+		Resource: e.Fields["resource"],
+		Role:     e.Fields["role"],
+		Member:   prefix + ":" + e.PrincipalID,
+	}
+}
+
+func (p GCP) Remove(ctx context.Context, e Entitlement) error {
+	return p.Backend.RemoveIAMBinding(ctx, binding(e))
+}
+
+func (p GCP) Restore(ctx context.Context, e Entitlement) error {
+	return p.Backend.AddIAMBinding(ctx, binding(e))
+}
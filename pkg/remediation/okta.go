@@ -0,0 +1,38 @@
+package remediation
+
+import (
+	"context"
+	"fmt"
+)
+
+// OktaBackend makes changes to Okta.
+type OktaBackend interface {
+	RemoveGroupMember(ctx context.Context, groupID string, userID string) error
+	AddGroupMember(ctx context.Context, groupID string, userID string) error
+}
+
+// Okta removes and restores the membership of users in Okta groups.
+// Its entitlements are held by users and have a groupId field.
+type Okta struct {
+	Backend OktaBackend
+}
+
+func (Okta) Name() string { return "okta" }
+
+func (Okta) Fields() []string { return []string{"groupId"} }
+
+func (p Okta) Validate(e Entitlement) error {
+	return validate(p, e, PrincipalUser)
+}
+
+func (Okta) Describe(e Entitlement) string {
+	return fmt.Sprintf("%s membership of group %s (%s)", e.principal(), e.Label("groupId"), e.Fields["groupId"])
+}
+
+func (p Okta) Remove(ctx context.Context, e Entitlement) error {
+	return p.Backend.RemoveGroupMember(ctx, e.Fields["groupId"], e.PrincipalID)
+}
+
+func (p Okta) Restore(ctx context.Context, e Entitlement) error {
+	return p.Backend.AddGroupMember(ctx, e.Fields["groupId"], e.PrincipalID)
+}
@@ -0,0 +1,144 @@
+package remediation
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// Entitlement is standing access held by a principal, identified in the terms of the provider which grants it.
+type Entitlement struct {
+	// Provider is the name of the provider, such as aws-sso.
+	Provider      string        `json:"provider"`
+	PrincipalType PrincipalType `json:"principalType"`
+	// PrincipalID identifies the principal in the provider. GCP principals are identified by their email address.
+	PrincipalID string `json:"principalId"`
+	// PrincipalName is shown in scripts, and defaults to the principal ID.
+	PrincipalName string `json:"principalName,omitempty"`
+	// Fields identify what the entitlement grants access to, such as the account ID and permission set ARN.
+	Fields map[string]string `json:"fields"`
+	// Labels are the human-readable names of the fields, keyed by field.
+	Labels map[string]string `json:"labels,omitempty"`
+	// Comment is written above the entitlement in scripts, if set.
+	Comment string `json:"comment,omitempty"`
+}
+
+// Label returns the human-readable name of a field, falling back to its value.
+func (e Entitlement) Label(field string) string {
+	if l := e.Labels[field]; l != "" {
+		return l
+	}
+	return e.Fields[field]
+}
+
+func (e Entitlement) principalName() string {
+	if e.PrincipalName != "" {
+		return e.PrincipalName
+	}
+	return e.PrincipalID
+}
+
+// principal describes the principal holding an entitlement, such as "user alice@example.com".
+func (e Entitlement) principal() string {
+	return fmt.Sprintf("%s %s", strings.ToLower(strings.ReplaceAll(string(e.PrincipalType), "_", " ")), e.principalName())
+}
+
+// Provider removes and restores the entitlements of one provider.
+type Provider interface {
+	// Name is the name of the provider. It's the same as the kind of the entitlements
+	// which Common Fate grants for the provider, so that grants can be matched with standing access.
+	Name() string
+	// Fields are the fields which identify an entitlement, in the order they are keyed.
+	Fields() []string
+	// Validate checks that an entitlement can be removed and restored.
+	Validate(e Entitlement) error
+	// Describe describes an entitlement for people reading a script or log.
+	Describe(e Entitlement) string
+	Remove(ctx context.Context, e Entitlement) error
+	// Restore grants a removed entitlement again.
+	Restore(ctx context.Context, e Entitlement) error
+}
+
+// Key identifies an entitlement held by a user. Standing access and Common Fate grants
+// for the same user and fields have the same key, so that access managed by Common Fate isn't removed.
+func Key(p Provider, userEmail string, fields map[string]string) string {
+	parts := []string{userEmail}
+	for _, f := range p.Fields() {
+		parts = append(parts, fields[f])
+	}
+	return strings.Join(parts, "+")
+}
+
+// validate checks that an entitlement is held by one of a provider's principal types and has the provider's fields.
+func validate(p Provider, e Entitlement, principalTypes ...PrincipalType) error {
+	if e.PrincipalID == "" {
+		return fmt.Errorf("%s entitlements must have a principal ID", p.Name())
+	}
+	ok := false
+	var names []string
+	for _, t := range principalTypes {
+		ok = ok || e.PrincipalType == t
+		names = append(names, string(t))
+	}
+	if !ok {
+		return fmt.Errorf("%s entitlements can't be held by %q principals: must be %s", p.Name(), e.PrincipalType, strings.Join(names, " or "))
+	}
+	for _, f := range p.Fields() {
+		if e.Fields[f] == "" {
+			return fmt.Errorf("%s entitlements must have a %s field", p.Name(), f)
+		}
+	}
+	return nil
+}
+
+// Providers are remediation providers, keyed by name.
+type Providers map[string]Provider
+
+// NewProviders creates a set of providers.
+func NewProviders(providers ...Provider) Providers {
+	ps := Providers{}
+	for _, p := range providers {
+		ps[p.Name()] = p
+	}
+	return ps
+}
+
+// Supported returns every provider without a backend. They can identify and describe entitlements, but not change them.
+func Supported() Providers {
+	return NewProviders(AWSSSO{}, Okta{}, AzureAD{}, GCP{})
+}
+
+// Names are the names of the providers, sorted.
+func (ps Providers) Names() []string {
+	var names []string
+	for n := range ps {
+		names = append(names, n)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// Get returns the provider for an entitlement.
+func (ps Providers) Get(name string) (Provider, error) {
+	p, ok := ps[name]
+	if !ok {
+		return nil, fmt.Errorf("unsupported provider %q: must be one of %s", name, strings.Join(ps.Names(), ", "))
+	}
+	return p, nil
+}
+
+// Validate checks that every entitlement can be removed and restored by its provider.
+func (ps Providers) Validate(entitlements []Entitlement) error {
+	for i, e := range entitlements {
+		p, err := ps.Get(e.Provider)
+		if err != nil {
+			return fmt.Errorf("entitlement %d: %w", i+1, err)
+		}
+		err = p.Validate(e)
+		if err != nil {
+			return fmt.Errorf("entitlement %d: %w", i+1, err)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,234 @@
+package remediation
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"testing"
+)
+
+// recorder is a backend for every provider which records the changes it's asked to make.
+type recorder struct {
+	calls []string
+}
+
+func (r *recorder) record(format string, args ...any) error {
+	r.calls = append(r.calls, fmt.Sprintf(format, args...))
+	return nil
+}
+
+func (r *recorder) DeleteAccountAssignment(ctx context.Context, a AccountAssignment) error {
+	return r.record("DeleteAccountAssignment %+v", a)
+}
+
+func (r *recorder) CreateAccountAssignment(ctx context.Context, a AccountAssignment) error {
+	return r.record("CreateAccountAssignment %+v", a)
+}
+
+func (r *recorder) RemoveGroupMember(ctx context.Context, groupID string, userID string) error {
+	return r.record("RemoveGroupMember %s %s", groupID, userID)
+}
+
+func (r *recorder) AddGroupMember(ctx context.Context, groupID string, userID string) error {
+	return r.record("AddGroupMember %s %s", groupID, userID)
+}
+
+func (r *recorder) DeleteRoleAssignment(ctx context.Context, a RoleAssignment) error {
+	return r.record("DeleteRoleAssignment %+v", a)
+}
+
+func (r *recorder) CreateRoleAssignment(ctx context.Context, a RoleAssignment) error {
+	return r.record("CreateRoleAssignment %+v", a)
+}
+
+func (r *recorder) RemoveIAMBinding(ctx context.Context, b IAMBinding) error {
+	return r.record("RemoveIAMBinding %+v", b)
+}
+
+func (r *recorder) AddIAMBinding(ctx context.Context, b IAMBinding) error {
+	return r.record("AddIAMBinding %+v", b)
+}
+
+func providers(r *recorder) Providers {
+	return NewProviders(AWSSSO{Backend: r}, Okta{Backend: r}, AzureAD{Backend: r}, GCP{Backend: r})
+}
+
+func TestRemoveAndRestore(t *testing.T) {
+	tests := []struct {
+		name        string
+		e           Entitlement
+		wantRemove  string
+		wantRestore string
+	}{
+		{
+			name: "aws-sso user",
+			e: Entitlement{
+				Provider: "aws-sso", PrincipalType: PrincipalUser, PrincipalID: "u-alice",
+				Fields: map[string]string{"accountId": "111111111111", "permissionSetArn": "arn:aws:sso:::permissionSet/ssoins-1/ps-admin"},
+			},
+			wantRemove:  "DeleteAccountAssignment {AccountID:111111111111 PermissionSetARN:arn:aws:sso:::permissionSet/ssoins-1/ps-admin PrincipalType:USER PrincipalID:u-alice InstanceARN: Region:}",
+			wantRestore: "CreateAccountAssignment {AccountID:111111111111 PermissionSetARN:arn:aws:sso:::permissionSet/ssoins-1/ps-admin PrincipalType:USER PrincipalID:u-alice InstanceARN: Region:}",
+		},
+		{
+			name: "aws-sso group in another instance",
+			e: Entitlement{
+				Provider: "aws-sso", PrincipalType: PrincipalGroup, PrincipalID: "g-admins",
+				Fields: map[string]string{
+					"accountId": "222222222222", "permissionSetArn": "arn:aws:sso:::permissionSet/ssoins-2/ps-ro",
+					"instanceArn": "arn:aws:sso:::instance/ssoins-2", "region": "eu-west-1",
+				},
+			},
+			wantRemove:  "DeleteAccountAssignment {AccountID:222222222222 PermissionSetARN:arn:aws:sso:::permissionSet/ssoins-2/ps-ro PrincipalType:GROUP PrincipalID:g-admins InstanceARN:arn:aws:sso:::instance/ssoins-2 Region:eu-west-1}",
+			wantRestore: "CreateAccountAssignment {AccountID:222222222222 PermissionSetARN:arn:aws:sso:::permissionSet/ssoins-2/ps-ro PrincipalType:GROUP PrincipalID:g-admins InstanceARN:arn:aws:sso:::instance/ssoins-2 Region:eu-west-1}",
+		},
+		{
+			name: "okta",
+			e: Entitlement{
+				Provider: "okta", PrincipalType: PrincipalUser, PrincipalID: "00u1",
+				Fields: map[string]string{"groupId": "00g1"},
+			},
+			wantRemove:  "RemoveGroupMember 00g1 00u1",
+			wantRestore: "AddGroupMember 00g1 00u1",
+		},
+		{
+			name: "azure-ad",
+			e: Entitlement{
+				Provider: "azure-ad", PrincipalType: PrincipalServiceAccount, PrincipalID: "sp-1",
+				Fields: map[string]string{"roleAssignmentId": "ra-1", "roleDefinitionId": "rd-1", "directoryScopeId": "/"},
+			},
+			wantRemove:  "DeleteRoleAssignment {ID:ra-1 RoleDefinitionID:rd-1 DirectoryScopeID:/ PrincipalID:sp-1}",
+			wantRestore: "CreateRoleAssignment {ID:ra-1 RoleDefinitionID:rd-1 DirectoryScopeID:/ PrincipalID:sp-1}",
+		},
+		{
+			name: "gcp user",
+			e: Entitlement{
+				Provider: "gcp", PrincipalType: PrincipalUser, PrincipalID: "alice@example.com",
+				Fields: map[string]string{"resource": "projects/my-project", "role": "roles/owner"},
+			},
+			wantRemove:  "RemoveIAMBinding {Resource:projects/my-project Role:roles/owner Member:user:alice@example.com}",
+			wantRestore: "AddIAMBinding {Resource:projects/my-project Role:roles/owner Member:user:alice@example.com}",
+		},
+		{
+			name: "gcp group",
+			e: Entitlement{
+				Provider: "gcp", PrincipalType: PrincipalGroup, PrincipalID: "admins@example.com",
+				Fields: map[string]string{"resource": "folders/123", "role": "roles/editor"},
+			},
+			wantRemove:  "RemoveIAMBinding {Resource:folders/123 Role:roles/editor Member:group:admins@example.com}",
+			wantRestore: "AddIAMBinding {Resource:folders/123 Role:roles/editor Member:group:admins@example.com}",
+		},
+		{
+			name: "gcp service account",
+			e: Entitlement{
+				Provider: "gcp", PrincipalType: PrincipalServiceAccount, PrincipalID: "deploy@my-project.iam.gserviceaccount.com",
+				Fields: map[string]string{"resource": "organizations/456", "role": "roles/viewer"},
+			},
+			wantRemove:  "RemoveIAMBinding {Resource:organizations/456 Role:roles/viewer Member:serviceAccount:deploy@my-project.iam.gserviceaccount.com}",
+			wantRestore: "AddIAMBinding {Resource:organizations/456 Role:roles/viewer Member:serviceAccount:deploy@my-project.iam.gserviceaccount.com}",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := &recorder{}
+			ps := providers(r)
+			err := ps.Validate([]Entitlement{tt.e})
+			if err != nil {
+				t.Fatal(err)
+			}
+			p, err := ps.Get(tt.e.Provider)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			err = p.Remove(context.Background(), tt.e)
+			if err != nil {
+				t.Fatal(err)
+			}
+			err = p.Restore(context.Background(), tt.e)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			want := []string{tt.wantRemove, tt.wantRestore}
+			if strings.Join(r.calls, "\n") != strings.Join(want, "\n") {
+				t.Errorf("got calls:\n%s\nwant:\n%s", strings.Join(r.calls, "\n"), strings.Join(want, "\n"))
+			}
+		})
+	}
+}
+
+func TestValidate(t *testing.T) {
+	tests := []struct {
+		name    string
+		e       Entitlement
+		wantErr string
+	}{
+		{
+			name:    "unknown provider",
+			e:       Entitlement{Provider: "github", PrincipalType: PrincipalUser, PrincipalID: "alice"},
+			wantErr: `entitlement 1: unsupported provider "github": must be one of aws-sso, azure-ad, gcp, okta`,
+		},
+		{
+			name:    "aws-sso without a principal ID",
+			e:       Entitlement{Provider: "aws-sso", PrincipalType: PrincipalUser, Fields: map[string]string{"accountId": "111111111111", "permissionSetArn": "ps"}},
+			wantErr: "entitlement 1: aws-sso entitlements must have a principal ID",
+		},
+		{
+			name:    "aws-sso without a permission set",
+			e:       Entitlement{Provider: "aws-sso", PrincipalType: PrincipalUser, PrincipalID: "u-alice", Fields: map[string]string{"accountId": "111111111111"}},
+			wantErr: "entitlement 1: aws-sso entitlements must have a permissionSetArn field",
+		},
+		{
+			name:    "aws-sso held by a service account",
+			e:       Entitlement{Provider: "aws-sso", PrincipalType: PrincipalServiceAccount, PrincipalID: "sa", Fields: map[string]string{"accountId": "111111111111", "permissionSetArn": "ps"}},
+			wantErr: `entitlement 1: aws-sso entitlements can't be held by "SERVICE_ACCOUNT" principals: must be USER or GROUP`,
+		},
+		{
+			name:    "okta without a group",
+			e:       Entitlement{Provider: "okta", PrincipalType: PrincipalUser, PrincipalID: "00u1"},
+			wantErr: "entitlement 1: okta entitlements must have a groupId field",
+		},
+		{
+			name:    "okta held by a group",
+			e:       Entitlement{Provider: "okta", PrincipalType: PrincipalGroup, PrincipalID: "00g2", Fields: map[string]string{"groupId": "00g1"}},
+			wantErr: `entitlement 1: okta entitlements can't be held by "GROUP" principals: must be USER`,
+		},
+		{
+			name:    "azure-ad without a scope",
+			e:       Entitlement{Provider: "azure-ad", PrincipalType: PrincipalUser, PrincipalID: "u1", Fields: map[string]string{"roleAssignmentId": "ra-1", "roleDefinitionId": "rd-1"}},
+			wantErr: "entitlement 1: azure-ad entitlements must have a directoryScopeId field",
+		},
+		{
+			name:    "azure-ad without an assignment ID",
+			e:       Entitlement{Provider: "azure-ad", PrincipalType: PrincipalUser, PrincipalID: "u1", Fields: map[string]string{"roleDefinitionId": "rd-1", "directoryScopeId": "/"}},
+			wantErr: "entitlement 1: azure-ad entitlements must have a roleAssignmentId field",
+		},
+		{
+			name:    "gcp without a role",
+			e:       Entitlement{Provider: "gcp", PrincipalType: PrincipalUser, PrincipalID: "alice@example.com", Fields: map[string]string{"resource": "projects/my-project"}},
+			wantErr: "entitlement 1: gcp entitlements must have a role field",
+		},
+		{
+			name:    "gcp with an unsupported resource",
+			e:       Entitlement{Provider: "gcp", PrincipalType: PrincipalUser, PrincipalID: "alice@example.com", Fields: map[string]string{"resource": "buckets/my-bucket", "role": "roles/owner"}},
+			wantErr: `entitlement 1: unsupported GCP resource "buckets/my-bucket": must be in the format projects/<ID>, folders/<ID> or organizations/<ID>`,
+		},
+		{
+			name:    "gcp resource without an ID",
+			e:       Entitlement{Provider: "gcp", PrincipalType: PrincipalUser, PrincipalID: "alice@example.com", Fields: map[string]string{"resource": "projects/", "role": "roles/owner"}},
+			wantErr: `entitlement 1: unsupported GCP resource "projects/": must be in the format projects/<ID>, folders/<ID> or organizations/<ID>`,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := &recorder{}
+			err := providers(r).Validate([]Entitlement{tt.e})
+			if err == nil || err.Error() != tt.wantErr {
+				t.Errorf("got error %v, want %s", err, tt.wantErr)
+			}
+			if len(r.calls) != 0 {
+				t.Errorf("validating made changes: %v", r.calls)
+			}
+		})
+	}
+}
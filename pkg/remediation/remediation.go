@@ -0,0 +1,60 @@
+// Package remediation removes and restores standing access, with a provider for each
+// system access is granted in. The providers write scripts for people to review and run.
+package remediation
+
+import (
+	"context"
+	"fmt"
+	"io"
+)
+
+// PrincipalType is the type of principal an entitlement is held by.
+type PrincipalType string
+
+const (
+	PrincipalUser  PrincipalType = "USER"
+	PrincipalGroup PrincipalType = "GROUP"
+	// PrincipalServiceAccount is a non-human identity, such as a GCP service account or an Azure AD service principal.
+	PrincipalServiceAccount PrincipalType = "SERVICE_ACCOUNT"
+)
+
+// Removal is an AWS SSO account assignment to be removed.
+type Removal struct {
+	PrincipalType     PrincipalType
+	PrincipalID       string
+	PrincipalName     string
+	AccountID         string
+	AccountName       string
+	PermissionSetARN  string
+	PermissionSetName string
+	// Comment is written above the removal in the script, if set.
+	Comment string
+}
+
+// Key identifies the account assignment being removed.
+func (r Removal) Key() string {
+	return fmt.Sprintf("%s+%s+%s+%s", r.PrincipalType, r.PrincipalID, r.AccountID, r.PermissionSetARN)
+}
+
+// WriteHeader writes the start of a bash script which removes AWS SSO account assignments.
+func WriteHeader(w io.Writer, instanceARN string, region string) error {
+	_, err := fmt.Fprintf(w, "#!/bin/bash\nSSO_INSTANCE_ARN=%s\nSSO_REGION=%s\n\n", instanceARN, region)
+	return err
+}
+
+// WriteRemoval writes the commands to remove an account assignment, where n is
+// the position of the removal in the script and total is the number of removals.
+// opts give the AWS SSO instance of the assignment, and must match the header.
+func WriteRemoval(w io.Writer, opts ScriptOptions, r Removal, n int, total int) error {
+	return writeStep(context.Background(), w, ScriptProviders(w, opts), ActionRemove, r.Entitlement(), n, total)
+}
+
+// WriteScript writes a bash script which removes every account assignment in removals.
+func WriteScript(w io.Writer, opts ScriptOptions, removals []Removal) error {
+	var entitlements []Entitlement
+	for _, r := range removals {
+		entitlements = append(entitlements, r.Entitlement())
+	}
+	opts.Action = ActionRemove
+	return WriteEntitlementScript(context.Background(), w, opts, entitlements)
+}
@@ -0,0 +1,281 @@
+package remediation
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/url"
+	"regexp"
+	"strings"
+)
+
+// Action is what a script does to entitlements.
+type Action string
+
+const (
+	ActionRemove  Action = "remove"
+	ActionRestore Action = "restore"
+)
+
+// ScriptOptions configure the scripts which remove and restore entitlements.
+type ScriptOptions struct {
+	// Action defaults to removing the entitlements.
+	Action Action
+	// InstanceARN and Region are the AWS SSO instance holding account assignments.
+	InstanceARN string
+	Region      string
+	// Instances are the AWS SSO instances holding the account assignments of each permission set,
+	// keyed by permission set ARN, for reports scanned from several instances.
+	// Assignments of permission sets which aren't in it are in the instance given by InstanceARN and Region.
+	Instances map[string]SSOInstance
+}
+
+// SSOInstance is an AWS SSO instance.
+type SSOInstance struct {
+	InstanceARN string `json:"instanceArn"`
+	Region      string `json:"region"`
+}
+
+func (opts ScriptOptions) action() Action {
+	if opts.Action == "" {
+		return ActionRemove
+	}
+	return opts.Action
+}
+
+// instance returns the AWS SSO instance holding an account assignment: the one given by its entitlement,
+// then the one holding its permission set, then the default. It returns false if the instance isn't known.
+func (opts ScriptOptions) instance(a AccountAssignment) (SSOInstance, bool) {
+	if a.InstanceARN != "" {
+		return SSOInstance{InstanceARN: a.InstanceARN, Region: a.Region}, a.Region != ""
+	}
+	if i, ok := opts.Instances[a.PermissionSetARN]; ok && i.InstanceARN != "" {
+		return i, i.Region != ""
+	}
+	return SSOInstance{InstanceARN: opts.InstanceARN, Region: opts.Region}, opts.InstanceARN != "" && opts.Region != ""
+}
+
+// Script is a backend for every provider which writes the shell commands making each change, rather than making it.
+// Okta commands read the organization URL and an API token from the OKTA_ORG_URL and OKTA_API_TOKEN environment
+// variables, and the Azure AD and GCP commands use the credentials of the az and gcloud CLIs.
+type Script struct {
+	W io.Writer
+	// Options give the AWS SSO instance of each account assignment. Commands for assignments in
+	// the default instance use the SSO_INSTANCE_ARN and SSO_REGION variables set at the start of the script.
+	Options ScriptOptions
+}
+
+// ScriptProviders returns every provider, writing their changes as shell commands to w.
+func ScriptProviders(w io.Writer, opts ScriptOptions) Providers {
+	s := Script{W: w, Options: opts}
+	return NewProviders(AWSSSO{Backend: s}, Okta{Backend: s}, AzureAD{Backend: s}, GCP{Backend: s})
+}
+
+func (s Script) command(format string, a ...interface{}) error {
+	_, err := fmt.Fprintf(s.W, format+"\n", a...)
+	return err
+}
+
+func (s Script) DeleteAccountAssignment(ctx context.Context, a AccountAssignment) error {
+	return s.command("aws sso-admin delete-account-assignment %s", s.awsSSOArgs(a))
+}
+
+func (s Script) CreateAccountAssignment(ctx context.Context, a AccountAssignment) error {
+	return s.command("aws sso-admin create-account-assignment %s", s.awsSSOArgs(a))
+}
+
+// awsSSOArgs are the arguments identifying an account assignment in aws sso-admin commands.
+func (s Script) awsSSOArgs(a AccountAssignment) string {
+	instance := "--instance-arn $SSO_INSTANCE_ARN --region $SSO_REGION"
+	if i, _ := s.Options.instance(a); i.InstanceARN != s.Options.InstanceARN || i.Region != s.Options.Region {
+		instance = "--instance-arn " + quote(i.InstanceARN) + " --region " + quote(i.Region)
+	}
+	return strings.Join([]string{
+		instance + " --target-type AWS_ACCOUNT",
+		"--target-id " + quote(a.AccountID),
+		"--permission-set-arn " + quote(a.PermissionSetARN),
+		"--principal-type " + quote(string(a.PrincipalType)),
+		"--principal-id " + quote(a.PrincipalID),
+	}, " ")
+}
+
+func oktaMembershipURL(groupID string, userID string) string {
+	return fmt.Sprintf(`"$OKTA_ORG_URL/api/v1/groups/%s/users/%s"`, escapeDoubleQuoted(url.PathEscape(groupID)), escapeDoubleQuoted(url.PathEscape(userID)))
+}
+
+func (s Script) RemoveGroupMember(ctx context.Context, groupID string, userID string) error {
+	return s.command(`curl -sSf -X DELETE -H "Authorization: SSWS $OKTA_API_TOKEN" %s`, oktaMembershipURL(groupID, userID))
+}
+
+func (s Script) AddGroupMember(ctx context.Context, groupID string, userID string) error {
+	return s.command(`curl -sSf -X PUT -H "Authorization: SSWS $OKTA_API_TOKEN" %s`, oktaMembershipURL(groupID, userID))
+}
+
+const azureRoleAssignmentsURL = "https://graph.microsoft.com/v1.0/roleManagement/directory/roleAssignments"
+
+func (s Script) DeleteRoleAssignment(ctx context.Context, a RoleAssignment) error {
+	return s.command("az rest --method DELETE --url %s", quote(azureRoleAssignmentsURL+"/"+url.PathEscape(a.ID)))
+}
+
+func (s Script) CreateRoleAssignment(ctx context.Context, a RoleAssignment) error {
+	body, err := json.Marshal(map[string]string{
+		"principalId":      a.PrincipalID,
+		"roleDefinitionId": a.RoleDefinitionID,
+		"directoryScopeId": a.DirectoryScopeID,
+	})
+	if err != nil {
+		return err
+	}
+	return s.command("az rest --method POST --url %s --body %s", azureRoleAssignmentsURL, quote(string(body)))
+}
+
+// gcloudGroups are the gcloud command groups for each type of resource.
+var gcloudGroups = map[string]string{
+	"projects":      "gcloud projects",
+	"folders":       "gcloud resource-manager folders",
+	"organizations": "gcloud organizations",
+}
+
+func (s Script) iamPolicyBinding(command string, b IAMBinding) error {
+	t, id, err := splitResource(b.Resource)
+	if err != nil {
+		return err
+	}
+	return s.command("%s %s %s --member=%s --role=%s", gcloudGroups[t], command, quote(id), quote(b.Member), quote(b.Role))
+}
+
+func (s Script) RemoveIAMBinding(ctx context.Context, b IAMBinding) error {
+	return s.iamPolicyBinding("remove-iam-policy-binding", b)
+}
+
+func (s Script) AddIAMBinding(ctx context.Context, b IAMBinding) error {
+	return s.iamPolicyBinding("add-iam-policy-binding", b)
+}
+
+// scriptEnv are the environment variables which the commands of each provider need.
+var scriptEnv = map[string][]string{
+	"okta": {"OKTA_ORG_URL", "OKTA_API_TOKEN"},
+}
+
+// usedProviders returns the names of the providers of entitlements.
+func usedProviders(entitlements []Entitlement) map[string]bool {
+	used := map[string]bool{}
+	for _, e := range entitlements {
+		used[e.Provider] = true
+	}
+	return used
+}
+
+// ValidateScript checks that a script can be written for every entitlement.
+func ValidateScript(opts ScriptOptions, entitlements []Entitlement) error {
+	err := Supported().Validate(entitlements)
+	if err != nil {
+		return err
+	}
+	for i, e := range entitlements {
+		if e.Provider != (AWSSSO{}).Name() {
+			continue
+		}
+		if _, ok := opts.instance(assignment(e)); !ok {
+			return fmt.Errorf("entitlement %d: the AWS SSO instance ARN and region are needed to %s account assignments", i+1, opts.action())
+		}
+	}
+	return nil
+}
+
+// WriteEntitlementScript writes a bash script which removes or restores entitlements in any provider.
+// Every entitlement is checked with ValidateScript before the script is written.
+func WriteEntitlementScript(ctx context.Context, w io.Writer, opts ScriptOptions, entitlements []Entitlement) error {
+	err := ValidateScript(opts, entitlements)
+	if err != nil {
+		return err
+	}
+	opts.Action = opts.action()
+	providers := ScriptProviders(w, opts)
+	used := usedProviders(entitlements)
+
+	_, err = io.WriteString(w, "#!/bin/bash\n")
+	if err != nil {
+		return err
+	}
+	if opts.InstanceARN != "" {
+		_, err = fmt.Fprintf(w, "SSO_INSTANCE_ARN=%s\nSSO_REGION=%s\n", opts.InstanceARN, opts.Region)
+		if err != nil {
+			return err
+		}
+	}
+	for _, name := range providers.Names() {
+		if !used[name] {
+			continue
+		}
+		for _, env := range scriptEnv[name] {
+			_, err = fmt.Fprintf(w, ": \"${%s:?%s must be set to %s %s entitlements}\"\n", env, env, opts.Action, name)
+			if err != nil {
+				return err
+			}
+		}
+	}
+	_, err = io.WriteString(w, "\n")
+	if err != nil {
+		return err
+	}
+
+	for i, e := range entitlements {
+		err = writeStep(ctx, w, providers, opts.Action, e, i+1, len(entitlements))
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// writeStep writes the commands to remove or restore an entitlement, where n is
+// the position of the entitlement in the script and total is the number of entitlements.
+func writeStep(ctx context.Context, w io.Writer, providers Providers, action Action, e Entitlement, n int, total int) error {
+	p, err := providers.Get(e.Provider)
+	if err != nil {
+		return err
+	}
+	if e.Comment != "" {
+		_, err = fmt.Fprintf(w, "# %s\n", e.Comment)
+		if err != nil {
+			return err
+		}
+	}
+	verb := "removing"
+	if action == ActionRestore {
+		verb = "restoring"
+	}
+	_, err = fmt.Fprintf(w, "echo \"(%d/%d) %s %s\"\n", n, total, verb, escapeDoubleQuoted(p.Describe(e)))
+	if err != nil {
+		return err
+	}
+
+	if action == ActionRestore {
+		err = p.Restore(ctx, e)
+	} else {
+		err = p.Remove(ctx, e)
+	}
+	if err != nil {
+		return err
+	}
+	_, err = io.WriteString(w, "\n")
+	return err
+}
+
+// safeShellWord matches arguments which don't need quoting.
+var safeShellWord = regexp.MustCompile(`^[A-Za-z0-9_\-.,:/@=+%]+$`)
+
+// quote quotes a shell argument if it contains special characters.
+func quote(s string) string {
+	if safeShellWord.MatchString(s) {
+		return s
+	}
+	return "'" + strings.ReplaceAll(s, "'", `'\''`) + "'"
+}
+
+// escapeDoubleQuoted escapes the characters which are special inside double quotes.
+func escapeDoubleQuoted(s string) string {
+	return strings.NewReplacer(`\`, `\\`, `"`, `\"`, "$", `\$`, "`", "\\`").Replace(s)
+}
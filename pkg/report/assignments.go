@@ -0,0 +1,121 @@
+package report
+
+import (
+	"fmt"
+
+	"github.com/jmoiron/sqlx"
+)
+
+// GroupAssignment is an AWS SSO account assignment which a user holds
+// because they are a member of a group.
+type GroupAssignment struct {
+	AccountAssignmentID string `db:"id"`
+	Account             string `db:"account"`
+	AccountName         string `db:"account_name"`
+	PermissionSetARN    string `db:"permission_set_arn"`
+	PermissionSetName   string `db:"permission_set_name"`
+	GroupID             string `db:"group_id"`
+	GroupName           string `db:"group_name"`
+	UserEmail           string `db:"email"`
+	UserID              string `db:"user_id"`
+	// Instance is the instance holding the assignment. It's empty for reports scanned
+	// before several instances were supported.
+	Instance string `db:"instance"`
+}
+
+// UserAssignment is an AWS SSO account assignment made directly to a user.
+type UserAssignment struct {
+	AccountAssignmentID string `db:"id"`
+	Account             string `db:"account"`
+	AccountName         string `db:"account_name"`
+	PermissionSetARN    string `db:"permission_set_arn"`
+	PermissionSetName   string `db:"permission_set_name"`
+	UserEmail           string `db:"email"`
+	UserID              string `db:"user_id"`
+	// Instance is the instance holding the assignment. It's empty for reports scanned
+	// before several instances were supported.
+	Instance string `db:"instance"`
+}
+
+// sameInstance returns a join condition matching a table to the instance of another table,
+// so that resources with the same ID in different instances aren't joined. It returns an
+// empty condition for reports scanned before several instances were supported.
+func sameInstance(db *sqlx.DB) (func(table, other string) string, error) {
+	scoped, err := ColumnExists(db, "accountassignment", InstanceColumn)
+	if err != nil {
+		return nil, err
+	}
+	return func(table, other string) string {
+		if !scoped {
+			return ""
+		}
+		return fmt.Sprintf(` AND %s.%s = %s.%s`, table, InstanceColumn, other, InstanceColumn)
+	}, nil
+}
+
+// GroupAssignments finds AWS SSO entitlements assigned to groups,
+// returning one row for each member of the group.
+func GroupAssignments(db *sqlx.DB) ([]GroupAssignment, error) {
+	var groupAssignments []GroupAssignment
+
+	on, err := sameInstance(db)
+	if err != nil {
+		return nil, err
+	}
+	instance, err := instanceSelect(db, "accountassignment")
+	if err != nil {
+		return nil, err
+	}
+
+	err = db.Select(&groupAssignments, `
+SELECT
+    accountassignment.id,
+    accountassignment.account,
+    account.name as account_name,
+    accountassignment.permission_set as permission_set_arn,
+    permissionset.name as permission_set_name,
+	"group".id as group_id,
+	"group".name as group_name,
+    user.email,
+	user.id as user_id,
+    `+instance+`
+FROM accountassignment
+INNER JOIN account ON accountassignment.account = account.id`+on("account", "accountassignment")+`
+INNER JOIN permissionset ON accountassignment.permission_set = permissionset.id`+on("permissionset", "accountassignment")+`
+INNER JOIN groupmembership ON accountassignment."group" = groupmembership."group"`+on("groupmembership", "accountassignment")+`
+INNER JOIN user ON groupmembership."user" = user.id`+on("user", "groupmembership")+`
+INNER JOIN "group" ON groupmembership."group" = "group".id`+on(`"group"`, "groupmembership")+`
+		`)
+	return groupAssignments, err
+}
+
+// UserAssignments finds AWS SSO entitlements assigned directly to users.
+func UserAssignments(db *sqlx.DB) ([]UserAssignment, error) {
+	var userAssignments []UserAssignment
+
+	on, err := sameInstance(db)
+	if err != nil {
+		return nil, err
+	}
+	instance, err := instanceSelect(db, "accountassignment")
+	if err != nil {
+		return nil, err
+	}
+
+	err = db.Select(&userAssignments, `
+SELECT
+    accountassignment.id,
+    accountassignment.account,
+    account.name as account_name,
+    accountassignment.permission_set as permission_set_arn,
+    permissionset.name as permission_set_name,
+    user.email,
+	user.id as user_id,
+    `+instance+`
+FROM accountassignment
+INNER JOIN account ON accountassignment.account = account.id`+on("account", "accountassignment")+`
+INNER JOIN permissionset ON accountassignment.permission_set = permissionset.id`+on("permissionset", "accountassignment")+`
+INNER JOIN user ON accountassignment."user" = user.id`+on("user", "accountassignment")+`
+		`)
+	return userAssignments, err
+}
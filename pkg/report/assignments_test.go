@@ -0,0 +1,77 @@
+package report_test
+
+import (
+	"sort"
+	"testing"
+
+	"github.com/common-fate/access-inspector/internal/reporttest"
+	"github.com/common-fate/access-inspector/pkg/report"
+	"github.com/common-fate/provider-registry-sdk-go/pkg/msg"
+)
+
+// TestAssignmentsJoinWithinInstances scans two instances which use the same IDs for
+// different users, groups and assignments, and checks that each assignment is only
+// joined to the resources in its own instance.
+func TestAssignmentsJoinWithinInstances(t *testing.T) {
+	db := reporttest.New(t,
+		reporttest.Instance{Name: "org-a", Resources: []msg.Resource{
+			reporttest.User("u-1", "Alice", "alice@a.example.com"),
+			reporttest.Group("g-1", "admins"),
+			reporttest.Account("111111111111", "prod", nil),
+			reporttest.PermissionSet("ps-1", "AdministratorAccess"),
+			reporttest.UserAssignment("aa-1", "111111111111", "ps-1", "u-1"),
+			reporttest.GroupAssignment("aa-2", "111111111111", "ps-1", "g-1"),
+			reporttest.Membership("gm-1", "u-1", "g-1"),
+		}},
+		reporttest.Instance{Name: "org-b", Resources: []msg.Resource{
+			reporttest.User("u-1", "Bob", "bob@b.example.com"),
+			reporttest.Group("g-1", "developers"),
+			reporttest.Account("111111111111", "sandbox", nil),
+			reporttest.PermissionSet("ps-1", "ReadOnlyAccess"),
+			reporttest.UserAssignment("aa-1", "111111111111", "ps-1", "u-1"),
+			reporttest.GroupAssignment("aa-2", "111111111111", "ps-1", "g-1"),
+			reporttest.Membership("gm-1", "u-1", "g-1"),
+		}},
+	)
+
+	users, err := report.UserAssignments(db)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var gotUsers []string
+	for _, ua := range users {
+		gotUsers = append(gotUsers, ua.UserEmail+" "+ua.AccountName+" "+ua.PermissionSetName)
+	}
+	sort.Strings(gotUsers)
+	assertRows(t, "user assignments", gotUsers, []string{
+		"alice@a.example.com prod AdministratorAccess",
+		"bob@b.example.com sandbox ReadOnlyAccess",
+	})
+
+	groups, err := report.GroupAssignments(db)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var gotGroups []string
+	for _, ga := range groups {
+		gotGroups = append(gotGroups, ga.UserEmail+" "+ga.GroupName+" "+ga.AccountName+" "+ga.PermissionSetName)
+	}
+	sort.Strings(gotGroups)
+	assertRows(t, "group assignments", gotGroups, []string{
+		"alice@a.example.com admins prod AdministratorAccess",
+		"bob@b.example.com developers sandbox ReadOnlyAccess",
+	})
+}
+
+func assertRows(t *testing.T, name string, got []string, want []string) {
+	t.Helper()
+	if len(got) != len(want) {
+		t.Fatalf("got %s %q, want %q", name, got, want)
+	}
+	for i := range want {
+		if got[i] != want[i] {
+			t.Errorf("got %s %q, want %q", name, got, want)
+			return
+		}
+	}
+}
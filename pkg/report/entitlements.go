@@ -0,0 +1,212 @@
+package report
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/common-fate/clio"
+	"github.com/jmoiron/sqlx"
+	"github.com/pkg/errors"
+)
+
+// Sources of entitlements.
+const (
+	// SourceDirect is an entitlement assigned to the principal itself.
+	SourceDirect = "direct"
+	// SourceGroup is an entitlement a user holds through membership of a group.
+	SourceGroup = "group"
+	// SourceJIT is an entitlement granted just-in-time by a Common Fate Access Request.
+	SourceJIT = "jit"
+)
+
+// Entitlement is a row of the entitlement table, which holds the access in a report in
+// the same shape for every provider: a principal holding a role on a resource.
+type Entitlement struct {
+	Provider string `db:"provider" json:"provider"`
+	// PrincipalType is USER or GROUP.
+	PrincipalType  string `db:"principal_type" json:"principalType"`
+	PrincipalID    string `db:"principal_id" json:"principalId"`
+	PrincipalName  string `db:"principal_name" json:"principalName"`
+	PrincipalEmail string `db:"principal_email" json:"principalEmail,omitempty"`
+	// ResourceType is the type of the resource in the provider, such as account.
+	ResourceType string `db:"resource_type" json:"resourceType"`
+	ResourceID   string `db:"resource_id" json:"resourceId"`
+	ResourceName string `db:"resource_name" json:"resourceName"`
+	RoleID       string `db:"role_id" json:"roleId"`
+	RoleName     string `db:"role_name" json:"roleName"`
+	// Source is how the principal holds the entitlement: direct, group or jit.
+	Source string `db:"source" json:"source"`
+	// ViaID and ViaName are the group the entitlement is held through, or the Access Request granting it.
+	ViaID   string `db:"via_id" json:"viaId,omitempty"`
+	ViaName string `db:"via_name" json:"viaName,omitempty"`
+	// AssignmentID is the assignment in the provider, for standing access.
+	AssignmentID string `db:"assignment_id" json:"assignmentId,omitempty"`
+	// Instance is the instance of the provider holding the entitlement, in reports scanned from several instances.
+	Instance string `db:"instance" json:"instance,omitempty"`
+}
+
+// Key identifies an entitlement, so that entitlements in different reports can be compared.
+func (e Entitlement) Key() string {
+	return strings.Join([]string{e.Provider, e.PrincipalType, e.PrincipalID, e.ResourceType, e.ResourceID, e.RoleID, e.Source, e.ViaID}, "+")
+}
+
+// entitlementColumns are the columns of the entitlement table, in the order they are selected by
+// entitlement rules. Rules select the instance column last, which older entitlement tables don't have.
+var entitlementColumns = []string{
+	"provider", "principal_type", "principal_id", "principal_name", "principal_email",
+	"resource_type", "resource_id", "resource_name", "role_id", "role_name",
+	"source", "via_id", "via_name", "assignment_id",
+}
+
+// EntitlementRule maps the tables of a provider to rows of the entitlement table.
+type EntitlementRule struct {
+	Provider    string
+	Description string
+	// Tables are the tables and views the rule reads. The rule is only run if the report contains them.
+	Tables []string
+	// Columns are columns the rule reads which reports scanned by earlier releases don't have,
+	// as table.column. The rule is only run if the report contains them.
+	Columns []string
+	// SQL selects the columns of the entitlement table, in order, followed by the instance.
+	SQL string
+}
+
+// EntitlementRules fill the entitlement table. Support for another provider is added by
+// writing rules mapping its tables, after which the entitlement table can be queried in the same way.
+var EntitlementRules = []EntitlementRule{
+	{
+		Provider:    "aws-sso",
+		Description: "account assignments made directly to users",
+		Tables:      []string{"v_direct_assignments"},
+		Columns:     []string{"v_direct_assignments.instance"},
+		SQL: `
+SELECT
+    'aws-sso', 'USER', user_id, user_name, user_email,
+    'account', account_id, account_name, permission_set_arn, permission_set_name,
+    'direct', NULL, NULL, assignment_id, instance
+FROM v_direct_assignments`,
+	},
+	{
+		Provider:    "aws-sso",
+		Description: "account assignments made to groups",
+		Tables:      []string{"v_group_assignments"},
+		Columns:     []string{"v_group_assignments.instance"},
+		SQL: `
+SELECT
+    'aws-sso', 'GROUP', group_id, group_name, NULL,
+    'account', account_id, account_name, permission_set_arn, permission_set_name,
+    'direct', NULL, NULL, assignment_id, instance
+FROM v_group_assignments`,
+	},
+	{
+		Provider:    "aws-sso",
+		Description: "account assignments held by users through their groups",
+		Tables:      []string{"v_effective_access"},
+		Columns:     []string{"v_effective_access.instance"},
+		SQL: `
+SELECT
+    'aws-sso', 'USER', user_id, user_name, user_email,
+    'account', account_id, account_name, permission_set_arn, permission_set_name,
+    'group', group_id, group_name, assignment_id, instance
+FROM v_effective_access
+WHERE source = 'group'`,
+	},
+	{
+		Provider:    "aws-sso",
+		Description: "active grants of Common Fate Access Requests imported into the report",
+		Tables:      []string{"v_commonfate_aws_sso_requests", "user"},
+		SQL: `
+SELECT
+    'aws-sso', 'USER', coalesce(user.id, requests.requestor_id), coalesce(user.name, requests.requestor_email), requests.requestor_email,
+    'account', requests.account_id, requests.account_name, requests.permission_set_arn, requests.permission_set_name,
+    'jit', requests.request_id, requests.access_rule_name, NULL, NULL
+FROM v_commonfate_aws_sso_requests requests
+LEFT JOIN user ON lower(user.email) = lower(requests.requestor_email)
+WHERE requests.grant_status = 'ACTIVE'`,
+	},
+}
+
+// CreateEntitlements recreates the entitlement table, running each rule whose tables are in the report.
+// The views must be created first, as rules may read them.
+func CreateEntitlements(db *sqlx.DB) error {
+	var rules []EntitlementRule
+	for _, rule := range EntitlementRules {
+		ok, err := hasTables(db, rule.Tables)
+		if err != nil {
+			return err
+		}
+		if !ok {
+			clio.Debugw("skipping entitlement rule as the report doesn't contain its tables", "provider", rule.Provider, "rule", rule.Description, "tables", rule.Tables)
+			continue
+		}
+		ok, err = hasColumns(db, rule.Columns)
+		if err != nil {
+			return err
+		}
+		if !ok {
+			clio.Debugw("skipping entitlement rule as the report doesn't contain its columns", "provider", rule.Provider, "rule", rule.Description, "columns", rule.Columns)
+			continue
+		}
+		rules = append(rules, rule)
+	}
+
+	tx, err := db.Beginx()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	_, err = tx.Exec(`DROP TABLE IF EXISTS entitlement`)
+	if err != nil {
+		return errors.Wrap(err, "dropping the entitlement table")
+	}
+
+	columns := append(entitlementColumns, InstanceColumn)
+	var defs []string
+	for _, c := range columns {
+		defs = append(defs, fmt.Sprintf(`"%s" TEXT`, c))
+	}
+	_, err = tx.Exec(fmt.Sprintf(`CREATE TABLE entitlement (%s)`, strings.Join(defs, ", ")))
+	if err != nil {
+		return errors.Wrap(err, "creating the entitlement table")
+	}
+
+	for _, rule := range rules {
+		stmt := fmt.Sprintf(`INSERT INTO entitlement (%s) %s`, strings.Join(columns, ", "), rule.SQL)
+		clio.Debugw("filling entitlements", "provider", rule.Provider, "rule", rule.Description, "sql", stmt)
+
+		_, err = tx.Exec(stmt)
+		if err != nil {
+			return errors.Wrapf(err, "filling %s entitlements (%s)", rule.Provider, rule.Description)
+		}
+	}
+
+	return tx.Commit()
+}
+
+// Entitlements returns the rows of the entitlement table. If the report doesn't have one, it returns nil.
+func Entitlements(db *sqlx.DB) ([]Entitlement, error) {
+	exists, err := TableExists(db, "entitlement")
+	if err != nil || !exists {
+		return nil, err
+	}
+
+	var cols []string
+	columns := entitlementColumns
+	tagged, err := ColumnExists(db, "entitlement", InstanceColumn)
+	if err != nil {
+		return nil, err
+	}
+	if tagged {
+		columns = append(columns, InstanceColumn)
+	}
+	for _, c := range columns {
+		cols = append(cols, fmt.Sprintf(`coalesce("%s", '') AS "%s"`, c, c))
+	}
+	entitlements := []Entitlement{}
+	err = db.Select(&entitlements, fmt.Sprintf(`SELECT %s FROM entitlement ORDER BY provider, principal_type, principal_name, resource_name, role_name, source`, strings.Join(cols, ", ")))
+	if err != nil {
+		return nil, errors.Wrap(err, "reading entitlements")
+	}
+	return entitlements, nil
+}
@@ -0,0 +1,118 @@
+package report
+
+import (
+	"encoding/json"
+
+	"github.com/common-fate/provider-registry-sdk-go/pkg/providerregistrysdk"
+	"github.com/jmoiron/sqlx"
+	"github.com/pkg/errors"
+)
+
+// InstanceColumn is the column of every resource table which holds the name of the
+// provider configuration the resource was scanned with, such as an AWS SSO instance.
+const InstanceColumn = "instance"
+
+// DefaultInstance is the name of the instance scanned with the PROVIDER_CONFIG_* environment variables.
+const DefaultInstance = "default"
+
+// Instance is a provider configuration scanned into the report.
+type Instance struct {
+	Name string `json:"name"`
+	// InstanceARN and Region are the AWS SSO instance, read from the provider config.
+	InstanceARN string `json:"instanceArn"`
+	Region      string `json:"region"`
+}
+
+func newInstance(name string, describe providerregistrysdk.DescribeResponse) Instance {
+	i := Instance{Name: name}
+	i.InstanceARN, _ = describe.Config["sso_instance_arn"].(string)
+	i.Region, _ = describe.Config["sso_region"].(string)
+	return i
+}
+
+// ColumnExists returns true if a table in the report has a column with the given name.
+func ColumnExists(db *sqlx.DB, table string, column string) (bool, error) {
+	var count int
+	err := db.Get(&count, `SELECT count(*) FROM pragma_table_info($1) WHERE name = $2`, table, column)
+	if err != nil {
+		return false, err
+	}
+	return count > 0, nil
+}
+
+// Instances lists the instances in the report, in the order they were scanned. Reports
+// scanned before several instances were supported have one instance, named default.
+func Instances(db *sqlx.DB) ([]Instance, error) {
+	named, err := ColumnExists(db, "__common_fate_meta", InstanceColumn)
+	if err != nil {
+		return nil, err
+	}
+	if !named {
+		describe, err := Describe(db)
+		if err != nil {
+			return nil, err
+		}
+		return []Instance{newInstance(DefaultInstance, describe)}, nil
+	}
+
+	rows, err := db.Query(`SELECT instance, describe FROM __common_fate_meta ORDER BY rowid`)
+	if err != nil {
+		return nil, errors.Wrap(err, "querying for instances")
+	}
+	defer rows.Close()
+
+	var instances []Instance
+	for rows.Next() {
+		var name, describeStr string
+		err = rows.Scan(&name, &describeStr)
+		if err != nil {
+			return nil, err
+		}
+		var describe providerregistrysdk.DescribeResponse
+		err = json.Unmarshal([]byte(describeStr), &describe)
+		if err != nil {
+			return nil, errors.Wrapf(err, "parsing the provider describe data of instance %s", name)
+		}
+		instances = append(instances, newInstance(name, describe))
+	}
+	return instances, rows.Err()
+}
+
+// PermissionSetInstances maps the ARN of each permission set to the instance holding it,
+// which is also the instance holding the account assignments of the permission set.
+// It returns an empty map if the report doesn't record the instance of each permission set.
+func PermissionSetInstances(db *sqlx.DB) (map[string]Instance, error) {
+	exists, err := TableExists(db, "permissionset")
+	if err != nil || !exists {
+		return map[string]Instance{}, err
+	}
+	tagged, err := ColumnExists(db, "permissionset", InstanceColumn)
+	if err != nil || !tagged {
+		return map[string]Instance{}, err
+	}
+
+	instances, err := Instances(db)
+	if err != nil {
+		return nil, err
+	}
+	byName := map[string]Instance{}
+	for _, i := range instances {
+		byName[i.Name] = i
+	}
+
+	var rows []struct {
+		ID       string `db:"id"`
+		Instance string `db:"instance"`
+	}
+	err = db.Select(&rows, `SELECT id, coalesce(instance, '') AS instance FROM permissionset`)
+	if err != nil {
+		return nil, errors.Wrap(err, "querying for the instances of permission sets")
+	}
+	result := map[string]Instance{}
+	for _, r := range rows {
+		if i, ok := byName[r.Instance]; ok {
+			result[r.ID] = i
+		}
+	}
+	return result, nil
+}
@@ -0,0 +1,89 @@
+package report
+
+import "fmt"
+
+// Reference is a reference from a relation field to another resource.
+type Reference struct {
+	Type string `json:"type"`
+	ID   string `json:"id"`
+}
+
+// RelationType returns the resource type referenced by a field, if the field is a relation.
+// The relation may be declared on the field itself, or on the items of an array field.
+func RelationType(schema map[string]any) (string, bool) {
+	if rel, ok := schema["relation"].(string); ok {
+		return rel, true
+	}
+	if items, ok := schema["items"].(map[string]any); ok {
+		if rel, ok := items["relation"].(string); ok {
+			return rel, true
+		}
+	}
+	return "", false
+}
+
+// IsRelation returns true if a field is a relation to another resource.
+func IsRelation(schema map[string]any) bool {
+	_, ok := RelationType(schema)
+	return ok
+}
+
+// References parses the value of a relation field. Values may be a single ID,
+// an object with a type and an ID (for relations which may reference several types of resource),
+// or an array of either. Any values which can't be interpreted as a reference are returned as errors.
+func References(relationType string, value any) ([]Reference, []error) {
+	switch v := value.(type) {
+	case nil:
+		return nil, nil
+	case string:
+		if v == "" {
+			return nil, nil
+		}
+		return []Reference{{Type: relationType, ID: v}}, nil
+	case map[string]any:
+		ref, err := compositeReference(relationType, v)
+		if err != nil {
+			return nil, []error{err}
+		}
+		return []Reference{ref}, nil
+	case []any:
+		var refs []Reference
+		var errs []error
+		for _, item := range v {
+			r, e := References(relationType, item)
+			refs = append(refs, r...)
+			errs = append(errs, e...)
+		}
+		return refs, errs
+	case []string:
+		var refs []Reference
+		for _, id := range v {
+			if id != "" {
+				refs = append(refs, Reference{Type: relationType, ID: id})
+			}
+		}
+		return refs, nil
+	}
+	return nil, []error{fmt.Errorf("unsupported relation value %+v", value)}
+}
+
+// compositeReference parses an object reference such as {"type": "Group", "id": "123"}.
+func compositeReference(relationType string, v map[string]any) (Reference, error) {
+	ref := Reference{Type: relationType}
+	for _, k := range []string{"type", "Type", "resourceType", "resource_type"} {
+		if t, ok := v[k].(string); ok && t != "" {
+			ref.Type = t
+			break
+		}
+	}
+	for _, k := range []string{"id", "ID", "Id", "resourceId", "resource_id"} {
+		if id, ok := v[k].(string); ok && id != "" {
+			ref.ID = id
+			break
+		}
+	}
+	if ref.Type == "" || ref.ID == "" {
+		return ref, fmt.Errorf("could not find a type and ID in relation value %+v", v)
+	}
+	return ref, nil
+}
@@ -0,0 +1,186 @@
+// Package report reads the SQLite databases written by the scan command.
+package report
+
+import (
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"os"
+
+	"github.com/common-fate/provider-registry-sdk-go/pkg/providerregistrysdk"
+	"github.com/jmoiron/sqlx"
+	_ "github.com/mattn/go-sqlite3"
+	"github.com/pkg/errors"
+)
+
+// Open opens an existing report database. Unlike sqlx.Open it returns an
+// error if the file doesn't exist, rather than silently creating an empty database.
+func Open(path string) (*sqlx.DB, error) {
+	if _, err := os.Stat(path); err != nil {
+		return nil, errors.Wrap(err, "opening report")
+	}
+	return sqlx.Open("sqlite3", fmt.Sprintf("file:%s", path))
+}
+
+// OpenReadOnly opens an existing report database which can't be written to, for
+// commands which only read the report.
+func OpenReadOnly(path string) (*sqlx.DB, error) {
+	if _, err := os.Stat(path); err != nil {
+		return nil, errors.Wrap(err, "opening report")
+	}
+	return sqlx.Open("sqlite3", fmt.Sprintf("file:%s?mode=ro", path))
+}
+
+// Describe loads the provider describe data which was stored in the report during the scan.
+func Describe(db *sqlx.DB) (providerregistrysdk.DescribeResponse, error) {
+	var describe providerregistrysdk.DescribeResponse
+	var describeStr string
+
+	err := db.QueryRow("SELECT describe from __common_fate_meta LIMIT 1").Scan(&describeStr)
+	if err != nil {
+		return describe, errors.Wrap(err, "querying for provider describe data")
+	}
+
+	err = json.Unmarshal([]byte(describeStr), &describe)
+	if err != nil {
+		return describe, err
+	}
+	return describe, nil
+}
+
+// Table is the contents of a report table, with every value rendered as a string.
+type Table struct {
+	Name    string     `json:"name"`
+	Columns []string   `json:"columns"`
+	Rows    [][]string `json:"rows"`
+}
+
+// LoadTable reads every row in a table.
+func LoadTable(db *sqlx.DB, name string) (Table, error) {
+	t, err := QueryTable(db, name, fmt.Sprintf(`SELECT * FROM "%s"`, name))
+	if err != nil {
+		return t, errors.Wrapf(err, "querying table %s", name)
+	}
+	return t, nil
+}
+
+// QueryTable runs a query, returning the results as a table with the given name.
+func QueryTable(db *sqlx.DB, name string, query string, args ...any) (Table, error) {
+	t := Table{Name: name}
+
+	rows, err := db.Queryx(query, args...)
+	if err != nil {
+		return t, err
+	}
+	defer rows.Close()
+
+	t.Columns, err = rows.Columns()
+	if err != nil {
+		return t, err
+	}
+
+	for rows.Next() {
+		vals := make([]sql.NullString, len(t.Columns))
+		ptrs := make([]any, len(vals))
+		for i := range vals {
+			ptrs[i] = &vals[i]
+		}
+		err = rows.Scan(ptrs...)
+		if err != nil {
+			return t, err
+		}
+		row := make([]string, len(vals))
+		for i, v := range vals {
+			row[i] = v.String
+		}
+		t.Rows = append(t.Rows, row)
+	}
+	return t, rows.Err()
+}
+
+// TableExists returns true if the report contains a table or view with the given name.
+func TableExists(db *sqlx.DB, name string) (bool, error) {
+	var count int
+	err := db.Get(&count, `SELECT count(*) FROM sqlite_master WHERE type IN ('table', 'view') AND name = $1`, name)
+	if err != nil {
+		return false, err
+	}
+	return count > 0, nil
+}
+
+// User is a user in the report.
+type User struct {
+	ID    string `db:"id"`
+	Name  string `db:"name"`
+	Email string `db:"email"`
+	// Instance is the instance holding the user. It's empty for reports scanned
+	// before several instances were supported.
+	Instance string `db:"instance"`
+}
+
+// Users lists the users in the report.
+func Users(db *sqlx.DB) ([]User, error) {
+	instance, err := instanceSelect(db, "user")
+	if err != nil {
+		return nil, err
+	}
+	var users []User
+	err = db.Select(&users, `SELECT id, coalesce(name, '') as name, coalesce(email, '') as email, `+instance+` FROM user ORDER BY email`)
+	return users, err
+}
+
+// instanceSelect returns the column to select the instance of the resources in a table,
+// which is empty for reports scanned before several instances were supported.
+func instanceSelect(db *sqlx.DB, table string) (string, error) {
+	scoped, err := ColumnExists(db, table, InstanceColumn)
+	if err != nil {
+		return "", err
+	}
+	if !scoped {
+		return `'' as ` + InstanceColumn, nil
+	}
+	return fmt.Sprintf(`coalesce("%s"."%s", '') as %s`, table, InstanceColumn, InstanceColumn), nil
+}
+
+// NamedResource is the ID and name of a resource, and the instance holding it.
+type NamedResource struct {
+	ID   string `db:"id"`
+	Name string `db:"name"`
+	// Instance is empty for reports scanned before several instances were supported.
+	Instance string `db:"instance"`
+}
+
+// NamedResources lists the ID, name and instance of every resource in a table.
+// Unlike Names, resources with the same ID in different instances are all returned.
+func NamedResources(db *sqlx.DB, table string) ([]NamedResource, error) {
+	instance, err := instanceSelect(db, table)
+	if err != nil {
+		return nil, err
+	}
+	var resources []NamedResource
+	err = db.Select(&resources, fmt.Sprintf(`SELECT id, coalesce(name, '') as name, %s FROM "%s"`, instance, table))
+	if err != nil {
+		return nil, errors.Wrapf(err, "querying table %s", table)
+	}
+	return resources, nil
+}
+
+// Names returns a map of resource ID to resource name for a table.
+func Names(db *sqlx.DB, table string) (map[string]string, error) {
+	rows, err := db.Query(fmt.Sprintf(`SELECT id, coalesce(name, '') FROM "%s"`, table))
+	if err != nil {
+		return nil, errors.Wrapf(err, "querying table %s", table)
+	}
+	defer rows.Close()
+
+	names := map[string]string{}
+	for rows.Next() {
+		var id, name string
+		err = rows.Scan(&id, &name)
+		if err != nil {
+			return nil, err
+		}
+		names[id] = name
+	}
+	return names, rows.Err()
+}
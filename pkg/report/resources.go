@@ -0,0 +1,148 @@
+package report
+
+import (
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/common-fate/provider-registry-sdk-go/pkg/msg"
+	"github.com/common-fate/provider-registry-sdk-go/pkg/providerregistrysdk"
+	"github.com/jmoiron/sqlx"
+	"github.com/pkg/errors"
+)
+
+// TableName gets the SQLite table name for a particular resource type.
+func TableName(resourceType string) string {
+	return strings.ToLower(resourceType)
+}
+
+// DataFields returns the schema of each data field of a resource type, keyed by field name.
+func DataFields(describe providerregistrysdk.DescribeResponse, resourceType string) (map[string]map[string]any, error) {
+	if describe.Schema.Resources == nil {
+		return nil, errors.New("the provider schema doesn't contain any resources")
+	}
+	resource, ok := describe.Schema.Resources.Types[resourceType]
+	if !ok {
+		return nil, fmt.Errorf("resource type %s is not in the provider schema", resourceType)
+	}
+	resourceData, _ := resource.(map[string]any)
+	properties, _ := resourceData["properties"].(map[string]any)
+	dataProps, _ := properties["data"].(map[string]any)
+
+	fields := map[string]map[string]any{}
+	for k, v := range dataProps {
+		schema, _ := v.(map[string]any)
+		fields[k] = schema
+	}
+	return fields, nil
+}
+
+// Resources loads every resource stored in the report, using the provider schema
+// stored during the scan to find the tables and decode their columns. Resources scanned
+// from a named instance hold the instance in the instance data field.
+func Resources(db *sqlx.DB, describe providerregistrysdk.DescribeResponse) ([]msg.Resource, error) {
+	if describe.Schema.Resources == nil {
+		return nil, errors.New("the provider schema doesn't contain any resources")
+	}
+
+	var types []string
+	for t := range describe.Schema.Resources.Types {
+		types = append(types, t)
+	}
+	sort.Strings(types)
+
+	var resources []msg.Resource
+
+	for _, resourceType := range types {
+		fields, err := DataFields(describe, resourceType)
+		if err != nil {
+			return nil, err
+		}
+
+		table := TableName(resourceType)
+		exists, err := TableExists(db, table)
+		if err != nil {
+			return nil, err
+		}
+		if !exists {
+			continue
+		}
+
+		rows, err := db.Queryx(fmt.Sprintf(`SELECT * FROM "%s"`, table))
+		if err != nil {
+			return nil, errors.Wrapf(err, "querying table %s", table)
+		}
+
+		cols, err := rows.Columns()
+		if err != nil {
+			rows.Close()
+			return nil, err
+		}
+
+		for rows.Next() {
+			vals := make([]sql.NullString, len(cols))
+			ptrs := make([]any, len(vals))
+			for i := range vals {
+				ptrs[i] = &vals[i]
+			}
+			err = rows.Scan(ptrs...)
+			if err != nil {
+				rows.Close()
+				return nil, err
+			}
+
+			r := msg.Resource{Type: resourceType, Data: map[string]any{}}
+			for i, col := range cols {
+				switch col {
+				case "id":
+					r.ID = vals[i].String
+				case "name":
+					r.Name = vals[i].String
+				case InstanceColumn:
+					// resources in the default instance are stored without one, so that
+					// reports scanned from a single provider config read the same as before
+					if vals[i].Valid && vals[i].String != DefaultInstance {
+						r.Data[InstanceColumn] = vals[i].String
+					}
+				default:
+					schema, ok := fields[col]
+					if !ok || !vals[i].Valid {
+						continue
+					}
+					r.Data[col] = decodeColumn(schema, vals[i].String)
+				}
+			}
+			resources = append(resources, r)
+		}
+		err = rows.Err()
+		rows.Close()
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	return resources, nil
+}
+
+// decodeColumn reverses the encoding used by the scan command, which stores strings
+// as-is and JSON encodes every other value.
+func decodeColumn(schema map[string]any, val string) any {
+	t, _ := schema["type"].(string)
+	switch t {
+	case "string":
+		return val
+	case "":
+		// without a type in the schema, only decode values which are clearly JSON,
+		// so that IDs such as AWS account numbers stay as strings
+		if !strings.HasPrefix(val, "[") && !strings.HasPrefix(val, "{") {
+			return val
+		}
+	}
+	var decoded any
+	if err := json.Unmarshal([]byte(val), &decoded); err == nil {
+		return decoded
+	}
+	return val
+}
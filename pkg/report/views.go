@@ -0,0 +1,208 @@
+package report
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/common-fate/clio"
+	"github.com/jmoiron/sqlx"
+	"github.com/pkg/errors"
+)
+
+// View is a SQL view which joins the raw resource tables, so that the report can be
+// queried without knowing the provider schema. The names and columns of views are stable.
+type View struct {
+	Name        string
+	Description string
+	// Tables are the tables the view reads. The view is only created if the report contains them.
+	Tables []string
+	// Columns are columns the view reads which reports scanned by earlier releases don't have,
+	// as table.column. The view is only created if the report contains them.
+	Columns []string
+	SQL     string
+}
+
+// Views are the views created in reports by the scan and import-requests commands.
+var Views = []View{
+	{
+		Name:        "v_direct_assignments",
+		Description: "AWS SSO account assignments made directly to users",
+		Tables:      []string{"accountassignment", "account", "permissionset", "user"},
+		Columns:     []string{"accountassignment.instance", "account.instance", "permissionset.instance", "user.instance"},
+		SQL: `
+SELECT
+    accountassignment.id as assignment_id,
+    accountassignment.account as account_id,
+    account.name as account_name,
+    accountassignment.permission_set as permission_set_arn,
+    permissionset.name as permission_set_name,
+    accountassignment."user" as user_id,
+    user.name as user_name,
+    user.email as user_email,
+    accountassignment.instance
+FROM accountassignment
+LEFT JOIN account ON accountassignment.account = account.id AND account.instance = accountassignment.instance
+LEFT JOIN permissionset ON accountassignment.permission_set = permissionset.id AND permissionset.instance = accountassignment.instance
+LEFT JOIN user ON accountassignment."user" = user.id AND user.instance = accountassignment.instance
+WHERE accountassignment."user" IS NOT NULL AND accountassignment."user" != ''`,
+	},
+	{
+		Name:        "v_group_assignments",
+		Description: "AWS SSO account assignments made to groups, with the number of members of the group",
+		Tables:      []string{"accountassignment", "account", "permissionset", "group", "groupmembership"},
+		Columns:     []string{"accountassignment.instance", "account.instance", "permissionset.instance", "group.instance", "groupmembership.instance"},
+		SQL: `
+SELECT
+    accountassignment.id as assignment_id,
+    accountassignment.account as account_id,
+    account.name as account_name,
+    accountassignment.permission_set as permission_set_arn,
+    permissionset.name as permission_set_name,
+    accountassignment."group" as group_id,
+    "group".name as group_name,
+    (SELECT count(*) FROM groupmembership WHERE groupmembership."group" = accountassignment."group" AND groupmembership.instance = accountassignment.instance) as member_count,
+    accountassignment.instance
+FROM accountassignment
+LEFT JOIN account ON accountassignment.account = account.id AND account.instance = accountassignment.instance
+LEFT JOIN permissionset ON accountassignment.permission_set = permissionset.id AND permissionset.instance = accountassignment.instance
+LEFT JOIN "group" ON accountassignment."group" = "group".id AND "group".instance = accountassignment.instance
+WHERE accountassignment."group" IS NOT NULL AND accountassignment."group" != ''`,
+	},
+	{
+		Name:        "v_effective_access",
+		Description: "every permission set each user has in each account, with a row for each account assignment granting it",
+		Tables:      []string{"accountassignment", "account", "permissionset", "user", "group", "groupmembership"},
+		Columns:     []string{"accountassignment.instance", "account.instance", "permissionset.instance", "user.instance", "group.instance", "groupmembership.instance"},
+		SQL: `
+SELECT
+    user_id,
+    user_name,
+    user_email,
+    account_id,
+    account_name,
+    permission_set_arn,
+    permission_set_name,
+    'direct' as source,
+    NULL as group_id,
+    NULL as group_name,
+    assignment_id,
+    instance
+FROM v_direct_assignments
+UNION ALL
+SELECT
+    groupmembership."user" as user_id,
+    user.name as user_name,
+    user.email as user_email,
+    v_group_assignments.account_id,
+    v_group_assignments.account_name,
+    v_group_assignments.permission_set_arn,
+    v_group_assignments.permission_set_name,
+    'group' as source,
+    v_group_assignments.group_id,
+    v_group_assignments.group_name,
+    v_group_assignments.assignment_id,
+    v_group_assignments.instance
+FROM v_group_assignments
+INNER JOIN groupmembership ON v_group_assignments.group_id = groupmembership."group" AND groupmembership.instance = v_group_assignments.instance
+LEFT JOIN user ON groupmembership."user" = user.id AND user.instance = v_group_assignments.instance`,
+	},
+	{
+		Name:        "v_commonfate_aws_sso_requests",
+		Description: "Common Fate Access Requests for AWS SSO, with the requestor, account, permission set and grant",
+		Tables:      []string{"commonfate_request", "commonfate_requestor", "commonfate_access_rule", "commonfate_request_argument", "commonfate_grant"},
+		SQL: `
+SELECT
+    commonfate_request.id as request_id,
+    commonfate_request.status as request_status,
+    commonfate_request.requested_at,
+    commonfate_request.requestor as requestor_id,
+    commonfate_requestor.email as requestor_email,
+    commonfate_access_rule.name as access_rule_name,
+    account.value as account_id,
+    account.label as account_name,
+    permission_set.value as permission_set_arn,
+    permission_set.label as permission_set_name,
+    commonfate_grant.status as grant_status,
+    commonfate_grant.start as grant_start,
+    commonfate_grant."end" as grant_end
+FROM commonfate_request
+LEFT JOIN commonfate_requestor ON commonfate_request.requestor = commonfate_requestor.id
+INNER JOIN commonfate_access_rule ON commonfate_request.access_rule_id = commonfate_access_rule.id AND commonfate_request.access_rule_version = commonfate_access_rule.version
+LEFT JOIN commonfate_request_argument account ON commonfate_request.id = account.request_id AND account.name = 'accountId'
+LEFT JOIN commonfate_request_argument permission_set ON commonfate_request.id = permission_set.request_id AND permission_set.name = 'permissionSetArn'
+LEFT JOIN commonfate_grant ON commonfate_request.id = commonfate_grant.request_id
+WHERE commonfate_access_rule.provider_type = 'aws-sso'`,
+	},
+}
+
+// CreateViews creates the views whose tables are in the report, replacing any existing
+// definitions. Views are created in order, so a view may read the views before it.
+func CreateViews(db *sqlx.DB) error {
+	// views reading columns which the report doesn't have are skipped, and listed in one warning
+	var skipped []string
+	for _, v := range Views {
+		ok, err := hasTables(db, v.Tables)
+		if err != nil {
+			return err
+		}
+		if !ok {
+			clio.Debugw("skipping view as the report doesn't contain its tables", "view", v.Name, "tables", v.Tables)
+			continue
+		}
+		ok, err = hasColumns(db, v.Columns)
+		if err != nil {
+			return err
+		}
+		if !ok {
+			clio.Debugw("skipping view as the report doesn't contain its columns", "view", v.Name, "columns", v.Columns)
+			skipped = append(skipped, v.Name)
+			continue
+		}
+
+		_, err = db.Exec(fmt.Sprintf(`DROP VIEW IF EXISTS "%s"`, v.Name))
+		if err != nil {
+			return errors.Wrapf(err, "dropping view %s", v.Name)
+		}
+
+		stmt := fmt.Sprintf(`CREATE VIEW "%s" AS %s`, v.Name, v.SQL)
+		clio.Debugw("creating view", "sql", stmt)
+
+		_, err = db.Exec(stmt)
+		if err != nil {
+			return errors.Wrapf(err, "creating view %s", v.Name)
+		}
+	}
+
+	if len(skipped) > 0 {
+		clio.Warnf("the %s views weren't created as the report was scanned by an earlier release: scan the provider again to create them", strings.Join(skipped, ", "))
+	}
+	return nil
+}
+
+func hasTables(db *sqlx.DB, tables []string) (bool, error) {
+	for _, t := range tables {
+		exists, err := TableExists(db, t)
+		if err != nil {
+			return false, err
+		}
+		if !exists {
+			return false, nil
+		}
+	}
+	return true, nil
+}
+
+// hasColumns returns true if the report contains every column, given as table.column.
+func hasColumns(db *sqlx.DB, columns []string) (bool, error) {
+	for _, c := range columns {
+		table, column, _ := strings.Cut(c, ".")
+		exists, err := ColumnExists(db, table, column)
+		if err != nil {
+			return false, err
+		}
+		if !exists {
+			return false, nil
+		}
+	}
+	return true, nil
+}
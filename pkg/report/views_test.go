@@ -0,0 +1,138 @@
+package report_test
+
+import (
+	"path/filepath"
+	"testing"
+
+	"github.com/common-fate/access-inspector/internal/reporttest"
+	"github.com/common-fate/access-inspector/pkg/report"
+	"github.com/common-fate/provider-registry-sdk-go/pkg/msg"
+	"github.com/jmoiron/sqlx"
+)
+
+// TestViewsJoinWithinInstances scans two instances which use the same IDs for different
+// users, groups and accounts, as identity stores in different organizations may.
+func TestViewsJoinWithinInstances(t *testing.T) {
+	db := reporttest.New(t,
+		reporttest.Instance{Name: "org-a", Resources: []msg.Resource{
+			reporttest.User("u-1", "Alice", "alice@a.example.com"),
+			reporttest.Group("g-1", "admins"),
+			reporttest.Account("111111111111", "prod", nil),
+			reporttest.PermissionSet("ps-a", "AdministratorAccess"),
+			reporttest.UserAssignment("aa-1", "111111111111", "ps-a", "u-1"),
+			reporttest.Membership("gm-1", "u-1", "g-1"),
+		}},
+		reporttest.Instance{Name: "org-b", Resources: []msg.Resource{
+			reporttest.User("u-1", "Bob", "bob@b.example.com"),
+			reporttest.User("u-2", "Carol", "carol@b.example.com"),
+			reporttest.Group("g-1", "developers"),
+			reporttest.Account("222222222222", "dev", nil),
+			reporttest.PermissionSet("ps-b", "ReadOnlyAccess"),
+			reporttest.GroupAssignment("aa-2", "222222222222", "ps-b", "g-1"),
+			reporttest.Membership("gm-1", "u-2", "g-1"),
+		}},
+	)
+
+	var groups []struct {
+		GroupName   string `db:"group_name"`
+		MemberCount int    `db:"member_count"`
+		Instance    string `db:"instance"`
+	}
+	err := db.Select(&groups, `SELECT group_name, member_count, instance FROM v_group_assignments`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(groups) != 1 || groups[0].GroupName != "developers" || groups[0].MemberCount != 1 || groups[0].Instance != "org-b" {
+		t.Errorf("got group assignments %+v, want developers in org-b with 1 member", groups)
+	}
+
+	var access []struct {
+		UserName    string `db:"user_name"`
+		AccountName string `db:"account_name"`
+		Source      string `db:"source"`
+		Instance    string `db:"instance"`
+	}
+	err = db.Select(&access, `SELECT user_name, account_name, source, instance FROM v_effective_access ORDER BY instance`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []struct{ user, account, source, instance string }{
+		{"Alice", "prod", "direct", "org-a"},
+		{"Carol", "dev", "group", "org-b"},
+	}
+	if len(access) != len(want) {
+		t.Fatalf("got effective access %+v, want %+v", access, want)
+	}
+	for i, w := range want {
+		a := access[i]
+		if a.UserName != w.user || a.AccountName != w.account || a.Source != w.source || a.Instance != w.instance {
+			t.Errorf("row %d: got %+v, want %+v", i, a, w)
+		}
+	}
+
+	entitlements, err := report.Entitlements(db)
+	if err != nil {
+		t.Fatal(err)
+	}
+	instances := map[string]string{}
+	for _, e := range entitlements {
+		instances[e.PrincipalName+" "+e.Source] = e.Instance
+	}
+	wantInstances := map[string]string{"Alice direct": "org-a", "developers direct": "org-b", "Carol group": "org-b"}
+	if len(instances) != len(wantInstances) {
+		t.Errorf("got entitlements %+v, want %v", entitlements, wantInstances)
+	}
+	for k, v := range wantInstances {
+		if instances[k] != v {
+			t.Errorf("got instance %q for %s, want %s", instances[k], k, v)
+		}
+	}
+}
+
+// TestViewsSkipReportsWithoutInstances checks that reports scanned before several instances
+// were supported are left without views, rather than failing. The tables are created by
+// hand, as the scan command no longer writes reports in this shape.
+func TestViewsSkipReportsWithoutInstances(t *testing.T) {
+	db, err := sqlx.Open("sqlite3", "file:"+filepath.Join(t.TempDir(), "report.db"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+	for _, stmt := range []string{
+		`CREATE TABLE "user" (id TEXT PRIMARY KEY, name TEXT, email TEXT)`,
+		`CREATE TABLE "group" (id TEXT PRIMARY KEY, name TEXT, description TEXT)`,
+		`CREATE TABLE "account" (id TEXT PRIMARY KEY, name TEXT, tags TEXT)`,
+		`CREATE TABLE "permissionset" (id TEXT PRIMARY KEY, name TEXT)`,
+		`CREATE TABLE "accountassignment" (id TEXT PRIMARY KEY, name TEXT, account TEXT, permission_set TEXT, user TEXT, "group" TEXT)`,
+		`CREATE TABLE "groupmembership" (id TEXT PRIMARY KEY, name TEXT, user TEXT, "group" TEXT)`,
+		`INSERT INTO accountassignment VALUES ('aa-1', '', '111111111111', 'ps-a', 'u-1', NULL)`,
+	} {
+		if _, err := db.Exec(stmt); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	err = report.CreateViews(db)
+	if err != nil {
+		t.Fatal(err)
+	}
+	exists, err := report.TableExists(db, "v_effective_access")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if exists {
+		t.Error("created v_effective_access for a report without instance columns")
+	}
+
+	err = report.CreateEntitlements(db)
+	if err != nil {
+		t.Fatal(err)
+	}
+	entitlements, err := report.Entitlements(db)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(entitlements) != 0 {
+		t.Errorf("got entitlements %+v, want none", entitlements)
+	}
+}
@@ -0,0 +1,175 @@
+package report
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+
+	"github.com/common-fate/clio"
+	"github.com/common-fate/provider-registry-sdk-go/pkg/msg"
+	"github.com/common-fate/provider-registry-sdk-go/pkg/providerregistrysdk"
+	"github.com/jmoiron/sqlx"
+	"github.com/pkg/errors"
+)
+
+// relationTableName gets the SQLite table name for a relation field which may reference
+// several resources. The table holds a row for each reference, so that the relation can be
+// joined in SQL without decoding the JSON stored in the resource table.
+func relationTableName(table string, field string) string {
+	return table + "_" + field
+}
+
+// isMultiRelation returns true if a relation field may hold several references or
+// composite references, rather than a single ID.
+func isMultiRelation(schema map[string]any) bool {
+	if !IsRelation(schema) {
+		return false
+	}
+	t, _ := schema["type"].(string)
+	return t == "array" || t == "object"
+}
+
+// CreateTables creates a table for each resource type in the provider schema, keyed by
+// ID and instance, and the __common_fate_meta table holding the describe data of each instance.
+func CreateTables(db *sqlx.DB, describe providerregistrysdk.DescribeResponse) error {
+	if describe.Schema.Resources == nil {
+		return errors.New("the provider schema doesn't contain any resources")
+	}
+
+	for resourceType, resource := range describe.Schema.Resources.Types {
+
+		table := TableName(resourceType)
+
+		resourceData := resource.(map[string]any)
+		properties := resourceData["properties"].(map[string]any)
+
+		cols := []string{
+			`"id" TEXT`,
+			`"name" TEXT`,
+			fmt.Sprintf(`"%s" TEXT`, InstanceColumn),
+		}
+
+		if _, ok := properties["data"]; ok {
+			dataProps := properties["data"].(map[string]any)
+
+			for property, propertySchema := range dataProps {
+				if property == InstanceColumn {
+					return fmt.Errorf("the %s resource has a %s field, which clashes with the column holding the instance the resource was scanned from", resourceType, property)
+				}
+
+				// default to TEXT columns for everything for now
+				col := fmt.Sprintf(`"%s" TEXT`, property)
+				cols = append(cols, col)
+
+				schema, _ := propertySchema.(map[string]any)
+				if !isMultiRelation(schema) {
+					continue
+				}
+
+				relationTable := relationTableName(table, property)
+				stmt := fmt.Sprintf(`CREATE TABLE "%s" ("source" TEXT, "type" TEXT, "id" TEXT, "%s" TEXT)`, relationTable, InstanceColumn)
+
+				clio.Debugw("creating relation table", "sql", stmt)
+
+				_, err := db.Exec(stmt)
+				if err != nil {
+					return errors.Wrapf(err, "creating table %s", relationTable)
+				}
+			}
+		}
+
+		// the same resource may be in more than one instance
+		cols = append(cols, fmt.Sprintf(`PRIMARY KEY ("id", "%s")`, InstanceColumn))
+
+		stmt := fmt.Sprintf(`CREATE TABLE "%s" (%s)`, table, strings.Join(cols, ", "))
+
+		clio.Debugw("creating table", "sql", stmt)
+
+		_, err := db.Exec(stmt)
+		if err != nil {
+			return errors.Wrapf(err, "creating table %s", table)
+		}
+	}
+
+	_, err := db.Exec(fmt.Sprintf(`CREATE TABLE __common_fate_meta (describe TEXT, "%s" TEXT)`, InstanceColumn))
+	return err
+}
+
+// AddInstance stores the provider describe data of an instance.
+func AddInstance(db *sqlx.DB, instance string, describe providerregistrysdk.DescribeResponse) error {
+	describeBytes, err := json.Marshal(describe)
+	if err != nil {
+		return err
+	}
+	_, err = db.Exec(fmt.Sprintf(`INSERT INTO __common_fate_meta (describe, "%s") VALUES ($1, $2)`, InstanceColumn), string(describeBytes), instance)
+	return err
+}
+
+// InsertResources inserts the resources loaded from an instance into their tables.
+func InsertResources(db *sqlx.DB, describe providerregistrysdk.DescribeResponse, instance string, resources []msg.Resource) error {
+	for _, r := range resources {
+		table := TableName(r.Type)
+
+		fields, err := DataFields(describe, r.Type)
+		if err != nil {
+			return err
+		}
+
+		cols := []string{`"id"`, `"name"`, `"` + InstanceColumn + `"`}
+		vals := []any{r.ID, r.Name, instance}
+
+		// add the data fields to the cols/vals
+		// so that they can be inserted into the database
+
+		for k, v := range r.Data {
+			if v == nil {
+				continue
+			}
+
+			cols = append(cols, `"`+k+`"`)
+
+			switch val := v.(type) {
+			case string:
+				vals = append(vals, val)
+			default:
+				// JSON encode the value if it's not a string
+				valBytes, err := json.Marshal(val)
+				if err != nil {
+					return err
+				}
+				vals = append(vals, string(valBytes))
+			}
+
+			if !isMultiRelation(fields[k]) {
+				continue
+			}
+
+			relationType, _ := RelationType(fields[k])
+			refs, errs := References(relationType, v)
+			for _, err := range errs {
+				clio.Warnf("unresolved relation: %s/%s field %s: %s", r.Type, r.ID, k, err)
+			}
+			for _, ref := range refs {
+				_, err = db.Exec(fmt.Sprintf(`INSERT INTO "%s" ("source", "type", "id", "%s") VALUES ($1, $2, $3, $4)`, relationTableName(table, k), InstanceColumn), r.ID, ref.Type, ref.ID, instance)
+				if err != nil {
+					return errors.Wrapf(err, "inserting relation %s of %+v into database", k, r)
+				}
+			}
+		}
+
+		placeholders := make([]string, len(vals))
+		for i := range placeholders {
+			placeholders[i] = fmt.Sprintf("$%d", i+1)
+		}
+
+		stmt := fmt.Sprintf(`INSERT INTO "%s" (%s) VALUES (%s)`, table, strings.Join(cols, ", "), strings.Join(placeholders, ", "))
+
+		clio.Debugw("inserting data", "sql", stmt, "values", vals)
+
+		_, err = db.Exec(stmt, vals...)
+		if err != nil {
+			return errors.Wrapf(err, "inserting %+v into database", r)
+		}
+	}
+	return nil
+}
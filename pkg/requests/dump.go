@@ -0,0 +1,329 @@
+package requests
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"regexp"
+	"sort"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/common-fate/clio"
+	"github.com/common-fate/common-fate/pkg/types"
+	"golang.org/x/sync/errgroup"
+)
+
+// API is the part of the Common Fate API used to export Access Requests.
+// It is implemented by *types.ClientWithResponses.
+type API interface {
+	AdminListRequestsWithResponse(ctx context.Context, params *types.AdminListRequestsParams, reqEditors ...types.RequestEditorFn) (*types.AdminListRequestsResponse, error)
+	AdminGetRequestWithResponse(ctx context.Context, requestId string, reqEditors ...types.RequestEditorFn) (*types.AdminGetRequestResponse, error)
+	UserGetUserWithResponse(ctx context.Context, userId string, reqEditors ...types.RequestEditorFn) (*types.UserGetUserResponse, error)
+	UserListRequestEventsWithResponse(ctx context.Context, requestId string, reqEditors ...types.RequestEditorFn) (*types.UserListRequestEventsResponse, error)
+}
+
+// Retry configures how calls which are rate limited or fail with a server error are retried.
+type Retry struct {
+	// MaxAttempts is the number of times a call is made before giving up.
+	MaxAttempts int
+	// BaseDelay is the delay before the first retry, which doubles with each attempt up to MaxDelay.
+	BaseDelay time.Duration
+	MaxDelay  time.Duration
+}
+
+// DefaultRetry retries for up to about a minute.
+var DefaultRetry = Retry{MaxAttempts: 6, BaseDelay: time.Second, MaxDelay: 30 * time.Second}
+
+// Dumper exports Access Requests from Common Fate.
+type Dumper struct {
+	API API
+	// Concurrency is the number of requests fetched at once.
+	Concurrency int
+	Retry       Retry
+	// History exports every request in any status, with its events, rather than only the requests with active grants.
+	History bool
+	// Now is used to find the active grants. It defaults to the current time.
+	Now func() time.Time
+	// Filter selects the requests to export.
+	Filter Filter
+	// Since skips requests which haven't been updated after it, for incremental exports.
+	// The list API can't filter by update time, so every page is still listed: Since only
+	// saves fetching the details and requestors of the requests which haven't changed.
+	Since time.Time
+	// Latest is the latest update time of the requests which have been listed, which
+	// can be used as Since in the next export.
+	Latest time.Time
+
+	mu sync.Mutex
+	// users caches requestor lookups, as the same users make many requests.
+	users map[string]*cachedUser
+}
+
+type cachedUser struct {
+	once sync.Once
+	user types.User
+	err  error
+}
+
+func (d *Dumper) now() time.Time {
+	if d.Now != nil {
+		return d.Now()
+	}
+	return time.Now()
+}
+
+// Dump returns the Access Requests, ordered by when they were requested.
+func (d *Dumper) Dump(ctx context.Context) ([]Entry, error) {
+	entries := []Entry{}
+	err := d.Stream(ctx, func(e Entry) error {
+		entries = append(entries, e)
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	sort.Slice(entries, func(i, j int) bool {
+		if !entries[i].Request.RequestedAt.Equal(entries[j].Request.RequestedAt) {
+			return entries[i].Request.RequestedAt.Before(entries[j].Request.RequestedAt)
+		}
+		return entries[i].Request.ID < entries[j].Request.ID
+	})
+	return entries, nil
+}
+
+// Stream lists the Access Requests a page at a time, fetching the details and requestor of the
+// requests on each page and passing them to emit as they are fetched. emit isn't called concurrently.
+func (d *Dumper) Stream(ctx context.Context, emit func(Entry) error) error {
+	params := types.AdminListRequestsParams{}
+	if !d.History {
+		approved := types.AdminListRequestsParamsStatus("APPROVED")
+		params.Status = &approved
+	}
+
+	var emitMu sync.Mutex
+
+	for page := 1; ; page++ {
+		if d.History {
+			clio.Infof("finding Access Requests in Common Fate (page %d)", page)
+		} else {
+			clio.Infof("finding active Access Requests in Common Fate (page %d)", page)
+		}
+
+		var res *types.AdminListRequestsResponse
+		err := d.retry(ctx, fmt.Sprintf("listing Access Requests (page %d)", page), func() (*http.Response, error) {
+			var err error
+			res, err = d.API.AdminListRequestsWithResponse(ctx, &params)
+			if res == nil {
+				return nil, err
+			}
+			return res.HTTPResponse, err
+		})
+		if err != nil {
+			return err
+		}
+		if res.JSON200 == nil {
+			return fmt.Errorf("listing Access Requests: %s", res.Status())
+		}
+
+		g, gctx := errgroup.WithContext(ctx)
+		if d.Concurrency > 0 {
+			g.SetLimit(d.Concurrency)
+		}
+
+		now := d.now()
+		for _, req := range res.JSON200.Requests {
+			if req.UpdatedAt.After(d.Latest) {
+				d.Latest = req.UpdatedAt
+			}
+			if !d.History && (req.Grant == nil ||
+				req.Grant.Status != types.GrantStatusACTIVE ||
+				!req.Grant.Start.Before(now) || !req.Grant.End.After(now)) {
+				continue
+			}
+			if !d.Since.IsZero() && !req.UpdatedAt.After(d.Since) {
+				continue
+			}
+			if !d.Filter.matchesSummary(req) {
+				continue
+			}
+
+			reqID := req.ID
+			g.Go(func() error {
+				e, err := d.entry(gctx, reqID)
+				if err != nil {
+					return err
+				}
+				if !d.Filter.matchesDetail(e) {
+					return nil
+				}
+				emitMu.Lock()
+				defer emitMu.Unlock()
+				return emit(e)
+			})
+		}
+
+		err = g.Wait()
+		if err != nil {
+			return err
+		}
+
+		if res.JSON200.Next == nil {
+			return nil
+		}
+		params.NextToken = res.JSON200.Next
+	}
+}
+
+func (d *Dumper) entry(ctx context.Context, reqID string) (Entry, error) {
+	var res *types.AdminGetRequestResponse
+	err := d.retry(ctx, "getting Access Request "+reqID, func() (*http.Response, error) {
+		var err error
+		res, err = d.API.AdminGetRequestWithResponse(ctx, reqID)
+		if res == nil {
+			return nil, err
+		}
+		return res.HTTPResponse, err
+	})
+	if err != nil {
+		return Entry{}, err
+	}
+	if res.JSON200 == nil {
+		return Entry{}, fmt.Errorf("getting Access Request %s: %s", reqID, res.Status())
+	}
+
+	user, err := d.user(ctx, res.JSON200.Requestor)
+	if err != nil {
+		return Entry{}, err
+	}
+
+	e := Entry{Request: *res.JSON200, User: user}
+
+	if d.History {
+		var events *types.UserListRequestEventsResponse
+		err = d.retry(ctx, "listing events of Access Request "+reqID, func() (*http.Response, error) {
+			var err error
+			events, err = d.API.UserListRequestEventsWithResponse(ctx, reqID)
+			if events == nil {
+				return nil, err
+			}
+			return events.HTTPResponse, err
+		})
+		if err != nil {
+			return Entry{}, err
+		}
+		if events.JSON200 == nil {
+			return Entry{}, fmt.Errorf("listing events of Access Request %s: %s", reqID, events.Status())
+		}
+		e.Events = events.JSON200.Events
+	}
+
+	return e, nil
+}
+
+// user looks up a requestor, fetching each user only once.
+func (d *Dumper) user(ctx context.Context, id string) (types.User, error) {
+	d.mu.Lock()
+	if d.users == nil {
+		d.users = map[string]*cachedUser{}
+	}
+	c, ok := d.users[id]
+	if !ok {
+		c = &cachedUser{}
+		d.users[id] = c
+	}
+	d.mu.Unlock()
+
+	c.once.Do(func() {
+		var res *types.UserGetUserResponse
+		c.err = d.retry(ctx, "getting user "+id, func() (*http.Response, error) {
+			var err error
+			res, err = d.API.UserGetUserWithResponse(ctx, id)
+			if res == nil {
+				return nil, err
+			}
+			return res.HTTPResponse, err
+		})
+		if c.err == nil && res.JSON200 == nil {
+			c.err = fmt.Errorf("getting user %s: %s", id, res.Status())
+		}
+		if c.err == nil {
+			c.user = *res.JSON200
+		}
+	})
+	return c.user, c.err
+}
+
+// errorCode matches the status code in the errors returned by the Common Fate CLI client,
+// which turns error responses into errors rather than returning them.
+var errorCode = regexp.MustCompile(`\(code (\d+)\)`)
+
+// statusCode returns the status of a call, from either the response or the error.
+func statusCode(res *http.Response, err error) int {
+	if res != nil {
+		return res.StatusCode
+	}
+	if err != nil {
+		if m := errorCode.FindStringSubmatch(err.Error()); m != nil {
+			code, _ := strconv.Atoi(m[1])
+			return code
+		}
+	}
+	return 0
+}
+
+func retryable(code int) bool {
+	return code == http.StatusTooManyRequests || code >= 500
+}
+
+// retry makes a call until it isn't rate limited or failing with a server error, backing off
+// exponentially between attempts. A Retry-After header sets the delay if it is longer.
+func (d *Dumper) retry(ctx context.Context, op string, call func() (*http.Response, error)) error {
+	p := d.Retry
+	if p.MaxAttempts < 1 {
+		p.MaxAttempts = 1
+	}
+	delay := p.BaseDelay
+
+	for attempt := 1; ; attempt++ {
+		res, err := call()
+		code := statusCode(res, err)
+		if !retryable(code) {
+			return err
+		}
+		if attempt >= p.MaxAttempts {
+			if err != nil {
+				return fmt.Errorf("%s: giving up after %d attempts: %w", op, attempt, err)
+			}
+			return fmt.Errorf("%s: giving up after %d attempts: the Common Fate API returned %s", op, attempt, res.Status)
+		}
+
+		wait := delay
+		if after := retryAfter(res); after > wait {
+			wait = after
+		}
+		if p.MaxDelay > 0 && wait > p.MaxDelay {
+			wait = p.MaxDelay
+		}
+		clio.Warnf("%s: the Common Fate API returned %d, retrying in %s (attempt %d of %d)", op, code, wait, attempt+1, p.MaxAttempts)
+
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-time.After(wait):
+		}
+		delay *= 2
+	}
+}
+
+func retryAfter(res *http.Response) time.Duration {
+	if res == nil {
+		return 0
+	}
+	seconds, err := strconv.Atoi(res.Header.Get("Retry-After"))
+	if err != nil || seconds < 0 {
+		return 0
+	}
+	return time.Duration(seconds) * time.Second
+}
@@ -0,0 +1,237 @@
+package requests
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/common-fate/common-fate/pkg/types"
+	openapi_types "github.com/deepmap/oapi-codegen/pkg/types"
+)
+
+var now = time.Date(2023, 3, 1, 12, 0, 0, 0, time.UTC)
+
+// fakeAPI is a Common Fate API serving active Access Requests in two pages. Each
+// request is made by one of the users, in turn.
+type fakeAPI struct {
+	requests int
+	users    []string
+	// fail returns the status codes of the first responses to calls to a path, before the call succeeds.
+	fail map[string][]int
+	// delay is how long calls to get a request or user take.
+	delay time.Duration
+
+	mu       sync.Mutex
+	calls    map[string]int
+	inFlight int
+	// maxInFlight is the largest number of calls which were in progress at once.
+	maxInFlight int
+}
+
+func (f *fakeAPI) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	f.mu.Lock()
+	if f.calls == nil {
+		f.calls = map[string]int{}
+	}
+	f.calls[r.URL.Path]++
+	attempt := f.calls[r.URL.Path]
+	f.inFlight++
+	if f.inFlight > f.maxInFlight {
+		f.maxInFlight = f.inFlight
+	}
+	f.mu.Unlock()
+
+	defer func() {
+		f.mu.Lock()
+		f.inFlight--
+		f.mu.Unlock()
+	}()
+
+	w.Header().Set("Content-Type", "application/json")
+	if codes := f.fail[r.URL.Path]; attempt <= len(codes) {
+		if codes[attempt-1] == http.StatusTooManyRequests {
+			w.Header().Set("Retry-After", "1")
+		}
+		w.WriteHeader(codes[attempt-1])
+		_ = json.NewEncoder(w).Encode(map[string]string{"error": "unavailable"})
+		return
+	}
+
+	switch {
+	case r.URL.Path == "/api/v1/admin/requests":
+		f.listRequests(w, r)
+	case strings.HasPrefix(r.URL.Path, "/api/v1/admin/requests/"):
+		time.Sleep(f.delay)
+		f.getRequest(w, strings.TrimPrefix(r.URL.Path, "/api/v1/admin/requests/"))
+	case strings.HasPrefix(r.URL.Path, "/api/v1/users/"):
+		time.Sleep(f.delay)
+		id := strings.TrimPrefix(r.URL.Path, "/api/v1/users/")
+		_ = json.NewEncoder(w).Encode(types.User{Id: id, Email: id + "@example.com"})
+	default:
+		w.WriteHeader(http.StatusNotFound)
+	}
+}
+
+// count returns the number of calls made to a path.
+func (f *fakeAPI) count(path string) int {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return f.calls[path]
+}
+
+func (f *fakeAPI) request(i int) types.Request {
+	requestor := f.users[i%len(f.users)]
+	return types.Request{
+		ID:          fmt.Sprintf("req-%02d", i),
+		Requestor:   requestor,
+		Status:      types.RequestStatusAPPROVED,
+		RequestedAt: now.Add(-time.Duration(f.requests-i) * time.Minute),
+		UpdatedAt:   now.Add(-time.Duration(f.requests-i) * time.Minute),
+		Grant: &types.Grant{
+			Status: types.GrantStatusACTIVE,
+			Start:  now.Add(-time.Hour),
+			End:    now.Add(time.Hour),
+			// Email checks the address when it's marshalled, so it can't be empty
+			Subject: openapi_types.Email(requestor + "@example.com"),
+		},
+	}
+}
+
+func (f *fakeAPI) listRequests(w http.ResponseWriter, r *http.Request) {
+	start := 0
+	if next := r.URL.Query().Get("nextToken"); next != "" {
+		fmt.Sscan(next, &start)
+	}
+	end := start + f.requests/2 + 1
+	if end > f.requests {
+		end = f.requests
+	}
+
+	res := types.ListRequestsResponse{Requests: []types.Request{}}
+	for i := start; i < end; i++ {
+		res.Requests = append(res.Requests, f.request(i))
+	}
+	if end < f.requests {
+		next := fmt.Sprint(end)
+		res.Next = &next
+	}
+	_ = json.NewEncoder(w).Encode(res)
+}
+
+func (f *fakeAPI) getRequest(w http.ResponseWriter, id string) {
+	var i int
+	fmt.Sscanf(id, "req-%d", &i)
+	req := f.request(i)
+	_ = json.NewEncoder(w).Encode(types.RequestDetail{
+		ID:          req.ID,
+		Requestor:   req.Requestor,
+		Status:      req.Status,
+		RequestedAt: req.RequestedAt,
+		UpdatedAt:   req.UpdatedAt,
+		Grant:       req.Grant,
+	})
+}
+
+func testDumper(t *testing.T, api *fakeAPI, concurrency int, retry Retry) *Dumper {
+	t.Helper()
+	ts := httptest.NewServer(api)
+	t.Cleanup(ts.Close)
+	client, err := types.NewClientWithResponses(ts.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return &Dumper{
+		API:         client,
+		Concurrency: concurrency,
+		Retry:       retry,
+		Now:         func() time.Time { return now },
+	}
+}
+
+func TestDumpFetchesEachRequestorOnce(t *testing.T) {
+	api := &fakeAPI{requests: 30, users: []string{"usr-alice", "usr-bob", "usr-carol"}, delay: 10 * time.Millisecond}
+	d := testDumper(t, api, 4, Retry{MaxAttempts: 1})
+
+	entries, err := d.Dump(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(entries) != 30 {
+		t.Fatalf("got %d entries, want 30", len(entries))
+	}
+	for i, e := range entries {
+		want := api.request(i)
+		if e.Request.ID != want.ID || e.User.Id != want.Requestor || e.User.Email != want.Requestor+"@example.com" {
+			t.Errorf("entry %d: got request %s by %s (%s), want %s by %s", i, e.Request.ID, e.User.Id, e.User.Email, want.ID, want.Requestor)
+		}
+	}
+
+	for _, u := range api.users {
+		if n := api.count("/api/v1/users/" + u); n != 1 {
+			t.Errorf("user %s was fetched %d times, want once", u, n)
+		}
+	}
+	api.mu.Lock()
+	defer api.mu.Unlock()
+	if api.maxInFlight > 4 {
+		t.Errorf("%d calls were in flight at once, want at most the concurrency of 4", api.maxInFlight)
+	}
+	if api.maxInFlight < 2 {
+		t.Errorf("at most %d call was in flight at once, want requests fetched concurrently", api.maxInFlight)
+	}
+}
+
+func TestDumpRetries(t *testing.T) {
+	api := &fakeAPI{
+		requests: 4,
+		users:    []string{"usr-alice"},
+		fail: map[string][]int{
+			"/api/v1/admin/requests":        {http.StatusTooManyRequests},
+			"/api/v1/admin/requests/req-01": {http.StatusServiceUnavailable, http.StatusInternalServerError},
+		},
+	}
+	d := testDumper(t, api, 2, Retry{MaxAttempts: 3, BaseDelay: time.Millisecond, MaxDelay: 5 * time.Second})
+
+	start := time.Now()
+	entries, err := d.Dump(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(entries) != 4 {
+		t.Fatalf("got %d entries, want 4", len(entries))
+	}
+	if n := api.count("/api/v1/admin/requests"); n != 3 {
+		t.Errorf("listed requests %d times, want the rate limited first page to be retried once and a second page", n)
+	}
+	if n := api.count("/api/v1/admin/requests/req-01"); n != 3 {
+		t.Errorf("got req-01 %d times, want two server errors to be retried", n)
+	}
+	if elapsed := time.Since(start); elapsed < time.Second {
+		t.Errorf("finished in %s, want the Retry-After of 1 second to be waited for", elapsed)
+	}
+}
+
+func TestDumpGivesUp(t *testing.T) {
+	api := &fakeAPI{
+		requests: 4,
+		users:    []string{"usr-alice"},
+		fail: map[string][]int{
+			"/api/v1/admin/requests/req-02": {http.StatusBadGateway, http.StatusBadGateway, http.StatusBadGateway, http.StatusBadGateway},
+		},
+	}
+	d := testDumper(t, api, 1, Retry{MaxAttempts: 3, BaseDelay: time.Millisecond, MaxDelay: time.Millisecond})
+
+	_, err := d.Dump(context.Background())
+	if err == nil || !strings.Contains(err.Error(), "giving up after 3 attempts") {
+		t.Fatalf("got error %v, want the dump to give up after 3 attempts", err)
+	}
+	if n := api.count("/api/v1/admin/requests/req-02"); n != 3 {
+		t.Errorf("got req-02 %d times, want MaxAttempts of 3", n)
+	}
+}
@@ -0,0 +1,62 @@
+package requests
+
+import (
+	"strings"
+	"time"
+
+	"github.com/common-fate/common-fate/pkg/types"
+)
+
+// Filter selects the Access Requests to export. Empty fields match every request.
+type Filter struct {
+	// ProviderTypes are provider types such as aws-sso.
+	ProviderTypes []string
+	AccessRuleIDs []string
+	// Requestors are user IDs or email addresses.
+	Requestors []string
+	// GrantsFrom and GrantsTo select requests with grants which were active at some point between them.
+	// If either is set, requests without grants don't match.
+	GrantsFrom time.Time
+	GrantsTo   time.Time
+}
+
+// matchesSummary checks the fields which are returned when listing requests, so that the details
+// of requests which don't match aren't fetched.
+func (f Filter) matchesSummary(r types.Request) bool {
+	if len(f.AccessRuleIDs) > 0 && !containsFold(f.AccessRuleIDs, r.AccessRuleId) {
+		return false
+	}
+	if !f.GrantsFrom.IsZero() || !f.GrantsTo.IsZero() {
+		g := r.Grant
+		if g == nil {
+			return false
+		}
+		if !f.GrantsFrom.IsZero() && !g.End.After(f.GrantsFrom) {
+			return false
+		}
+		if !f.GrantsTo.IsZero() && g.Start.After(f.GrantsTo) {
+			return false
+		}
+	}
+	return true
+}
+
+// matchesDetail checks the fields which are only known once the request details and requestor have been fetched.
+func (f Filter) matchesDetail(e Entry) bool {
+	if len(f.ProviderTypes) > 0 && !containsFold(f.ProviderTypes, e.ProviderType()) {
+		return false
+	}
+	if len(f.Requestors) > 0 && !containsFold(f.Requestors, e.Request.Requestor) && !containsFold(f.Requestors, e.User.Email) {
+		return false
+	}
+	return true
+}
+
+func containsFold(values []string, s string) bool {
+	for _, v := range values {
+		if strings.EqualFold(v, s) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,312 @@
+package requests
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"sort"
+	"strings"
+	"sync"
+
+	"github.com/common-fate/clio"
+	"github.com/pkg/errors"
+)
+
+const (
+	// KindAWSSSO is an AWS SSO account assignment.
+	KindAWSSSO = "aws-sso"
+
+	// FieldAccountID and FieldPermissionSetARN identify an AWS SSO account assignment.
+	FieldAccountID        = "accountId"
+	FieldPermissionSetARN = "permissionSetArn"
+
+	// KindOkta is a user's membership of an Okta group.
+	KindOkta = "okta"
+	// FieldGroupID identifies an Okta group.
+	FieldGroupID = "groupId"
+)
+
+// builtinFields are the fields of the entitlement kinds which have built-in mappers.
+var builtinFields = map[string][]string{
+	KindAWSSSO: {FieldAccountID, FieldPermissionSetARN},
+	KindOkta:   {FieldGroupID},
+}
+
+// Granted is an entitlement which an Access Request grants, such as an AWS SSO account assignment.
+type Granted struct {
+	Kind string `json:"kind"`
+	// Fields identify the entitlement, such as the account ID and permission set ARN.
+	Fields map[string]string `json:"fields"`
+}
+
+// AccountAssignment is an AWS SSO account assignment granted by an Access Request.
+type AccountAssignment struct {
+	AccountID        string
+	PermissionSetARN string
+}
+
+// Mapper turns an Access Request into the entitlements it grants.
+type Mapper interface {
+	Map(e Entry) ([]Granted, error)
+}
+
+// FieldMapper maps the arguments of a request to the fields of an entitlement.
+// Arguments with several values, such as a list of accounts, grant an entitlement
+// for every combination of values.
+type FieldMapper struct {
+	Kind string
+	// Arguments map the fields of the entitlement to the IDs of the request arguments holding them.
+	Arguments map[string]string
+	// Values are fields with the same value for every request.
+	Values map[string]string
+}
+
+func (m FieldMapper) Map(e Entry) ([]Granted, error) {
+	base := map[string]string{}
+	for k, v := range m.Values {
+		base[k] = v
+	}
+	granted := []Granted{{Kind: m.Kind, Fields: base}}
+
+	var fields []string
+	for field := range m.Arguments {
+		fields = append(fields, field)
+	}
+	sort.Strings(fields)
+
+	for _, field := range fields {
+		arg := m.Arguments[field]
+		values := splitValues(e.Argument(arg))
+		if len(values) == 0 {
+			return nil, fmt.Errorf("the request has no %s argument", arg)
+		}
+
+		var next []Granted
+		for _, g := range granted {
+			for _, v := range values {
+				fields := map[string]string{field: v}
+				for k, existing := range g.Fields {
+					fields[k] = existing
+				}
+				next = append(next, Granted{Kind: g.Kind, Fields: fields})
+			}
+		}
+		granted = next
+	}
+	return granted, nil
+}
+
+// splitValues splits an argument holding several values, either as a JSON array or separated by commas.
+func splitValues(s string) []string {
+	s = strings.TrimSpace(s)
+	if s == "" {
+		return nil
+	}
+	var values []string
+	if strings.HasPrefix(s, "[") && json.Unmarshal([]byte(s), &values) == nil {
+		return values
+	}
+	for _, v := range strings.Split(s, ",") {
+		if v = strings.TrimSpace(v); v != "" {
+			values = append(values, v)
+		}
+	}
+	return values
+}
+
+// awsSSO maps the arguments of the built-in AWS SSO providers.
+var awsSSO = FieldMapper{
+	Kind: KindAWSSSO,
+	Arguments: map[string]string{
+		FieldAccountID:        FieldAccountID,
+		FieldPermissionSetARN: FieldPermissionSetARN,
+	},
+}
+
+// okta maps the arguments of the built-in Okta provider.
+var okta = FieldMapper{
+	Kind:      KindOkta,
+	Arguments: map[string]string{FieldGroupID: FieldGroupID},
+}
+
+// Registry finds the mapper for the target of an Access Request.
+type Registry struct {
+	// mappers are keyed by provider type, provider ID or target group ID.
+	mappers map[string]Mapper
+	// fallbacks are tried in order for requests whose target has no mapper.
+	// The first which maps the request without an error is used.
+	fallbacks []Mapper
+
+	mu sync.Mutex
+	// warned records the targets and requests which have been warned about, so that each is only logged once.
+	warned map[string]bool
+}
+
+// NewRegistry creates a registry with the built-in mappers for AWS SSO and Okta.
+//
+// The built-in AWS SSO mapper is registered for the aws-sso provider type, which is used
+// by every version of the built-in provider. Target groups created from the Common Fate AWS
+// provider use the same argument IDs, so requests for any target with accountId and
+// permissionSetArn arguments are mapped to AWS SSO account assignments too.
+func NewRegistry() *Registry {
+	r := &Registry{mappers: map[string]Mapper{}, warned: map[string]bool{}}
+	r.Register("aws-sso", awsSSO)
+	r.Register("okta", okta)
+	r.fallbacks = append(r.fallbacks, awsSSO)
+	return r
+}
+
+// Register sets the mapper for a provider type, provider ID or target group ID, replacing any existing mapper.
+func (r *Registry) Register(target string, m Mapper) {
+	r.mappers[target] = m
+}
+
+// target describes the target of a request in log messages.
+func target(e Entry) string {
+	p := e.Request.AccessRule.Target.Provider
+	if p.Type == "" {
+		return p.Id
+	}
+	return p.Type + " (" + p.Id + ")"
+}
+
+// Mapper returns the mapper for a request. Mappers registered for the provider ID,
+// which is the target group ID for rules using target groups, take precedence over
+// those registered for the provider type.
+func (r *Registry) Mapper(e Entry) (Mapper, bool) {
+	p := e.Request.AccessRule.Target.Provider
+	for _, key := range []string{p.Id, p.Type} {
+		if m, ok := r.mappers[key]; ok && key != "" {
+			return m, true
+		}
+	}
+	return nil, false
+}
+
+// Granted returns the entitlements granted by a request. Requests which can't be mapped are logged and skipped.
+func (r *Registry) Granted(e Entry) []Granted {
+	if m, ok := r.Mapper(e); ok {
+		granted, err := m.Map(e)
+		if err != nil {
+			r.warnOnce(e.Request.ID, "skipping Access Request %s for %s: %s", e.Request.ID, target(e), err)
+			return nil
+		}
+		return granted
+	}
+
+	for _, m := range r.fallbacks {
+		granted, err := m.Map(e)
+		if err == nil {
+			return granted
+		}
+	}
+
+	t := target(e)
+	r.warnOnce(t, "skipping Access Requests for %s, as there is no mapping for it: declare one in a target mappings file", t)
+	return nil
+}
+
+// warnOnce logs a warning, unless one has already been logged with the same key.
+func (r *Registry) warnOnce(key string, format string, a ...interface{}) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.warned[key] {
+		return
+	}
+	r.warned[key] = true
+	clio.Warnf(format, a...)
+}
+
+// AccountAssignments returns the AWS SSO account assignments granted by a request.
+func (r *Registry) AccountAssignments(e Entry) []AccountAssignment {
+	var assignments []AccountAssignment
+	for _, g := range r.Granted(e) {
+		if g.Kind != KindAWSSSO {
+			continue
+		}
+		assignments = append(assignments, AccountAssignment{
+			AccountID:        g.Fields[FieldAccountID],
+			PermissionSetARN: g.Fields[FieldPermissionSetARN],
+		})
+	}
+	return assignments
+}
+
+// MappingConfig is the format of the target mappings file.
+//
+//	{"targets": [{"target": "aws-prod", "kind": "aws-sso", "arguments": {"accountId": "account", "permissionSetArn": "role"}}]}
+type MappingConfig struct {
+	Targets []TargetMapping `json:"targets"`
+}
+
+// TargetMapping declares how requests for a target map to entitlements.
+type TargetMapping struct {
+	// Target is the provider type, provider ID or target group ID of the access rules.
+	Target string `json:"target"`
+	// Kind is the kind of entitlement granted, such as aws-sso.
+	Kind string `json:"kind"`
+	// Arguments map the fields of the entitlement to the IDs of the request arguments holding them.
+	// For built-in kinds, fields which aren't given are read from the argument with the same ID.
+	Arguments map[string]string `json:"arguments"`
+	// Values are fields with the same value for every request, such as the permission set of a target group which only grants one.
+	Values map[string]string `json:"values"`
+}
+
+// Mapper builds the mapper for the target.
+func (t TargetMapping) Mapper() (FieldMapper, error) {
+	if t.Target == "" {
+		return FieldMapper{}, fmt.Errorf("target mappings must have a target")
+	}
+	if t.Kind == "" {
+		return FieldMapper{}, fmt.Errorf("the mapping for %s must have a kind", t.Target)
+	}
+
+	m := FieldMapper{Kind: t.Kind, Arguments: map[string]string{}, Values: map[string]string{}}
+	for k, v := range t.Arguments {
+		m.Arguments[k] = v
+	}
+	for k, v := range t.Values {
+		if _, ok := m.Arguments[k]; ok {
+			return FieldMapper{}, fmt.Errorf("the mapping for %s gives %s as both an argument and a value", t.Target, k)
+		}
+		m.Values[k] = v
+	}
+	for _, field := range builtinFields[t.Kind] {
+		_, isArg := m.Arguments[field]
+		_, isValue := m.Values[field]
+		if !isArg && !isValue {
+			m.Arguments[field] = field
+		}
+	}
+	if len(m.Arguments) == 0 && len(m.Values) == 0 {
+		return FieldMapper{}, fmt.Errorf("the mapping for %s must have arguments or values, as %s isn't a built-in kind", t.Target, t.Kind)
+	}
+	return m, nil
+}
+
+// LoadMappers creates a registry with the built-in mappers and the mappings in a file, which take precedence.
+// If path is empty, only the built-in mappers are used.
+func LoadMappers(path string) (*Registry, error) {
+	r := NewRegistry()
+	if path == "" {
+		return r, nil
+	}
+
+	b, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	var cfg MappingConfig
+	err = json.Unmarshal(b, &cfg)
+	if err != nil {
+		return nil, errors.Wrapf(err, "parsing target mappings file %s", path)
+	}
+	for _, t := range cfg.Targets {
+		m, err := t.Mapper()
+		if err != nil {
+			return nil, errors.Wrapf(err, "parsing target mappings file %s", path)
+		}
+		r.Register(t.Target, m)
+	}
+	return r, nil
+}
@@ -0,0 +1,177 @@
+// Package requests reads the Common Fate Access Requests written by the dump-requests command.
+package requests
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/common-fate/clio"
+	"github.com/common-fate/common-fate/pkg/types"
+	"github.com/pkg/errors"
+)
+
+// Entry is an Access Request with the user who made it.
+type Entry struct {
+	Request types.RequestDetail `json:"request"`
+	User    types.User          `json:"user"`
+	// Events are the changes made to the request. They are only exported with the full request history.
+	Events []types.RequestEvent `json:"events,omitempty"`
+}
+
+// Load reads the Access Requests in a file written by the dump-requests command, in either
+// JSON or NDJSON format. NDJSON files written incrementally may hold a request more than once,
+// in which case the last one is used.
+func Load(path string) ([]Entry, error) {
+	b, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	trimmed := bytes.TrimSpace(b)
+	if len(trimmed) == 0 {
+		return nil, nil
+	}
+	if trimmed[0] == '[' {
+		var entries []Entry
+		err = json.Unmarshal(b, &entries)
+		if err != nil {
+			return nil, errors.Wrapf(err, "reading Access Requests from %s", path)
+		}
+		return entries, nil
+	}
+
+	var entries []Entry
+	index := map[string]int{}
+	dec := json.NewDecoder(bytes.NewReader(b))
+	for line := 1; ; line++ {
+		var e Entry
+		err = dec.Decode(&e)
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, errors.Wrapf(err, "reading Access Request %d from %s", line, path)
+		}
+		if i, ok := index[e.Request.ID]; ok {
+			entries[i] = e
+			continue
+		}
+		index[e.Request.ID] = len(entries)
+		entries = append(entries, e)
+	}
+	return entries, nil
+}
+
+// IsActive returns true if the request has a grant which is active at a time.
+func (e Entry) IsActive(now time.Time) bool {
+	g := e.Request.Grant
+	return g != nil && g.Status == types.GrantStatusACTIVE && !g.Start.After(now) && g.End.After(now)
+}
+
+// Active returns the requests with grants which are active at a time. Grants which have
+// expired are skipped even if their status wasn't updated before the requests were exported.
+func Active(entries []Entry, now time.Time) []Entry {
+	var active []Entry
+	for _, e := range entries {
+		if !e.IsActive(now) {
+			clio.Debugw("skipping Access Request without an active grant", "request", e.Request.ID, "status", e.Request.Status)
+			continue
+		}
+		active = append(active, e)
+	}
+	return active
+}
+
+// Argument returns the value of a target argument of the request.
+func (e Entry) Argument(name string) string {
+	return e.Request.Arguments.AdditionalProperties[name].Value
+}
+
+// ProviderType is the type of the provider the access was requested for, such as aws-sso.
+func (e Entry) ProviderType() string {
+	return e.Request.AccessRule.Target.Provider.Type
+}
+
+// ApprovedAt returns when the request was approved, which is only known if the request events were exported.
+func (e Entry) ApprovedAt() (time.Time, bool) {
+	for _, ev := range e.Events {
+		if ev.ToStatus != nil && *ev.ToStatus == types.RequestStatusAPPROVED {
+			return ev.CreatedAt, true
+		}
+	}
+	return time.Time{}, false
+}
+
+// GrantDuration returns how long access was granted for.
+func (e Entry) GrantDuration() (time.Duration, bool) {
+	g := e.Request.Grant
+	if g == nil || !g.End.After(g.Start) {
+		return 0, false
+	}
+	return g.End.Sub(g.Start), true
+}
+
+// Entitlement is what was requested: a provider and the values of its target arguments.
+type Entitlement struct {
+	Provider string `json:"provider"`
+	// Arguments are the argument values, keyed by argument ID.
+	Arguments map[string]string `json:"arguments"`
+	// Labels are the human-readable labels of the argument values, keyed by argument ID.
+	Labels map[string]string `json:"labels"`
+}
+
+// EntitlementOf returns the entitlement a request was made for.
+func EntitlementOf(e Entry) Entitlement {
+	ent := Entitlement{
+		Provider:  e.ProviderType(),
+		Arguments: map[string]string{},
+		Labels:    map[string]string{},
+	}
+	for k, v := range e.Request.Arguments.AdditionalProperties {
+		ent.Arguments[k] = v.Value
+		ent.Labels[k] = v.Label
+	}
+	return ent
+}
+
+func (e Entitlement) argumentNames() []string {
+	var names []string
+	for k := range e.Arguments {
+		names = append(names, k)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// Key identifies the entitlement, so that requests for the same access can be grouped.
+func (e Entitlement) Key() string {
+	parts := []string{e.Provider}
+	for _, k := range e.argumentNames() {
+		parts = append(parts, k+"="+e.Arguments[k])
+	}
+	return strings.Join(parts, ",")
+}
+
+func (e Entitlement) label(name string) string {
+	if l := e.Labels[name]; l != "" {
+		return l
+	}
+	return e.Arguments[name]
+}
+
+func (e Entitlement) String() string {
+	if e.Provider == "aws-sso" {
+		return fmt.Sprintf("%s in %s", e.label("permissionSetArn"), e.label("accountId"))
+	}
+	var args []string
+	for _, k := range e.argumentNames() {
+		args = append(args, k+"="+e.label(k))
+	}
+	return e.Provider + " " + strings.Join(args, ", ")
+}
@@ -0,0 +1,35 @@
+package requests
+
+import (
+	"testing"
+	"time"
+
+	"github.com/common-fate/common-fate/pkg/types"
+)
+
+func TestActive(t *testing.T) {
+	entry := func(id string, g *types.Grant) Entry {
+		return Entry{Request: types.RequestDetail{ID: id, Grant: g}}
+	}
+	grant := func(status types.GrantStatus, start, end time.Time) *types.Grant {
+		return &types.Grant{Status: status, Start: start, End: end}
+	}
+
+	entries := []Entry{
+		entry("active", grant(types.GrantStatusACTIVE, now.Add(-time.Hour), now.Add(time.Hour))),
+		// the export was taken while the grant was active, and it has since expired
+		entry("expired", grant(types.GrantStatusACTIVE, now.Add(-2*time.Hour), now.Add(-time.Hour))),
+		entry("not-started", grant(types.GrantStatusACTIVE, now.Add(time.Hour), now.Add(2*time.Hour))),
+		entry("revoked", grant(types.GrantStatusREVOKED, now.Add(-time.Hour), now.Add(time.Hour))),
+		entry("no-grant", nil),
+	}
+
+	active := Active(entries, now)
+	if len(active) != 1 || active[0].Request.ID != "active" {
+		var ids []string
+		for _, e := range active {
+			ids = append(ids, e.Request.ID)
+		}
+		t.Errorf("got active requests %v, want [active]", ids)
+	}
+}
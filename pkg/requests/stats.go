@@ -0,0 +1,235 @@
+package requests
+
+import (
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/common-fate/access-inspector/pkg/remediation"
+	"github.com/common-fate/access-inspector/pkg/report"
+	"github.com/common-fate/common-fate/pkg/types"
+)
+
+// Stats summarise the requests made for an entitlement.
+type Stats struct {
+	Entitlement Entitlement `json:"entitlement"`
+	Requests    int         `json:"requests"`
+	Approved    int         `json:"approved"`
+	Declined    int         `json:"declined"`
+	Cancelled   int         `json:"cancelled"`
+	Pending     int         `json:"pending"`
+	// AutoApproved is the number of approved requests which didn't need a review.
+	AutoApproved int `json:"autoApproved"`
+	// Requestors is the number of users who requested the entitlement.
+	Requestors int `json:"requestors"`
+	// ApprovalRate is the share of reviewed or automatically approved requests which were approved.
+	ApprovalRate float64 `json:"approvalRate"`
+	// MedianApprovalSeconds is the median time between a request being made and approved.
+	// It is only set if request events were exported.
+	MedianApprovalSeconds *int `json:"medianApprovalSeconds,omitempty"`
+	// MedianDurationSeconds is the median length of the grants made for the requests.
+	MedianDurationSeconds *int      `json:"medianDurationSeconds,omitempty"`
+	LastRequested         time.Time `json:"lastRequested"`
+}
+
+// Compute summarises requests by entitlement, most requested first.
+func Compute(entries []Entry) []Stats {
+	type acc struct {
+		stats      Stats
+		requestors map[string]bool
+		approval   []time.Duration
+		duration   []time.Duration
+	}
+	byKey := map[string]*acc{}
+
+	for _, e := range entries {
+		ent := EntitlementOf(e)
+		a, ok := byKey[ent.Key()]
+		if !ok {
+			a = &acc{stats: Stats{Entitlement: ent}, requestors: map[string]bool{}}
+			byKey[ent.Key()] = a
+		}
+		s := &a.stats
+		s.Requests++
+		a.requestors[requestor(e)] = true
+		if e.Request.RequestedAt.After(s.LastRequested) {
+			s.LastRequested = e.Request.RequestedAt
+		}
+
+		switch e.Request.Status {
+		case types.RequestStatusAPPROVED:
+			s.Approved++
+			if e.Request.ApprovalMethod != nil && *e.Request.ApprovalMethod == types.AUTOMATIC {
+				s.AutoApproved++
+			}
+			if t, ok := e.ApprovedAt(); ok && !t.Before(e.Request.RequestedAt) {
+				a.approval = append(a.approval, t.Sub(e.Request.RequestedAt))
+			}
+		case types.RequestStatusDECLINED:
+			s.Declined++
+		case types.RequestStatusCANCELLED:
+			s.Cancelled++
+		case types.RequestStatusPENDING:
+			s.Pending++
+		}
+
+		if d, ok := e.GrantDuration(); ok {
+			a.duration = append(a.duration, d)
+		}
+	}
+
+	var stats []Stats
+	for _, a := range byKey {
+		s := a.stats
+		s.Requestors = len(a.requestors)
+		if decided := s.Approved + s.Declined; decided > 0 {
+			s.ApprovalRate = float64(s.Approved) / float64(decided)
+		}
+		s.MedianApprovalSeconds = medianSeconds(a.approval)
+		s.MedianDurationSeconds = medianSeconds(a.duration)
+		stats = append(stats, s)
+	}
+
+	sort.Slice(stats, func(i, j int) bool {
+		if stats[i].Requests != stats[j].Requests {
+			return stats[i].Requests > stats[j].Requests
+		}
+		return stats[i].Entitlement.Key() < stats[j].Entitlement.Key()
+	})
+	return stats
+}
+
+func requestor(e Entry) string {
+	if e.User.Email != "" {
+		return strings.ToLower(e.User.Email)
+	}
+	return e.Request.Requestor
+}
+
+func medianSeconds(durations []time.Duration) *int {
+	if len(durations) == 0 {
+		return nil
+	}
+	sort.Slice(durations, func(i, j int) bool { return durations[i] < durations[j] })
+	mid := len(durations) / 2
+	median := durations[mid]
+	if len(durations)%2 == 0 {
+		median = (durations[mid-1] + durations[mid]) / 2
+	}
+	seconds := int(median.Seconds())
+	return &seconds
+}
+
+// Candidate is a standing AWS SSO account assignment for an account and permission set which
+// people also request through Common Fate. These are the strongest candidates for moving to JIT access.
+type Candidate struct {
+	AssignmentID      string                    `json:"assignmentId"`
+	AccountID         string                    `json:"accountId"`
+	AccountName       string                    `json:"accountName"`
+	PermissionSetARN  string                    `json:"permissionSetArn"`
+	PermissionSetName string                    `json:"permissionSetName"`
+	PrincipalType     remediation.PrincipalType `json:"principalType"`
+	PrincipalID       string                    `json:"principalId"`
+	PrincipalName     string                    `json:"principalName"`
+	// StandingUsers is the number of users holding the assignment.
+	StandingUsers int `json:"standingUsers"`
+	// HoldersRequested is the number of users holding the assignment who have also requested it through Common Fate.
+	HoldersRequested int `json:"holdersRequested"`
+	// Requests, Approved and Requestors describe every request made for the account and permission set.
+	Requests   int `json:"requests"`
+	Approved   int `json:"approved"`
+	Requestors int `json:"requestors"`
+}
+
+// Candidates matches standing account assignments in a report with the AWS SSO entitlements
+// requested through Common Fate. They are sorted with the assignments whose holders requested
+// the same access first, followed by the most requested.
+func Candidates(direct []report.UserAssignment, group []report.GroupAssignment, entries []Entry, mappers *Registry) []Candidate {
+	type requested struct {
+		requests, approved int
+		requestors         map[string]bool
+	}
+	byEntitlement := map[string]*requested{}
+	for _, e := range entries {
+		for _, a := range mappers.AccountAssignments(e) {
+			k := a.AccountID + "+" + a.PermissionSetARN
+			r, ok := byEntitlement[k]
+			if !ok {
+				r = &requested{requestors: map[string]bool{}}
+				byEntitlement[k] = r
+			}
+			r.requests++
+			if e.Request.Status == types.RequestStatusAPPROVED {
+				r.approved++
+			}
+			r.requestors[requestor(e)] = true
+		}
+	}
+
+	byAssignment := map[string]*Candidate{}
+	holders := map[string]map[string]bool{}
+	var order []string
+
+	add := func(c Candidate, email string) {
+		r, ok := byEntitlement[c.AccountID+"+"+c.PermissionSetARN]
+		if !ok {
+			return
+		}
+		existing, ok := byAssignment[c.AssignmentID]
+		if !ok {
+			c.Requests = r.requests
+			c.Approved = r.approved
+			c.Requestors = len(r.requestors)
+			existing = &c
+			byAssignment[c.AssignmentID] = existing
+			holders[c.AssignmentID] = map[string]bool{}
+			order = append(order, c.AssignmentID)
+		}
+		email = strings.ToLower(email)
+		if holders[c.AssignmentID][email] {
+			return
+		}
+		holders[c.AssignmentID][email] = true
+		existing.StandingUsers++
+		if r.requestors[email] {
+			existing.HoldersRequested++
+		}
+	}
+
+	for _, a := range direct {
+		add(Candidate{
+			AssignmentID:      a.AccountAssignmentID,
+			AccountID:         a.Account,
+			AccountName:       a.AccountName,
+			PermissionSetARN:  a.PermissionSetARN,
+			PermissionSetName: a.PermissionSetName,
+			PrincipalType:     remediation.PrincipalUser,
+			PrincipalID:       a.UserID,
+			PrincipalName:     a.UserEmail,
+		}, a.UserEmail)
+	}
+	for _, a := range group {
+		add(Candidate{
+			AssignmentID:      a.AccountAssignmentID,
+			AccountID:         a.Account,
+			AccountName:       a.AccountName,
+			PermissionSetARN:  a.PermissionSetARN,
+			PermissionSetName: a.PermissionSetName,
+			PrincipalType:     remediation.PrincipalGroup,
+			PrincipalID:       a.GroupID,
+			PrincipalName:     a.GroupName,
+		}, a.UserEmail)
+	}
+
+	candidates := []Candidate{}
+	for _, id := range order {
+		candidates = append(candidates, *byAssignment[id])
+	}
+	sort.SliceStable(candidates, func(i, j int) bool {
+		if candidates[i].HoldersRequested != candidates[j].HoldersRequested {
+			return candidates[i].HoldersRequested > candidates[j].HoldersRequested
+		}
+		return candidates[i].Requests > candidates[j].Requests
+	})
+	return candidates
+}
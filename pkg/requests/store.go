@@ -0,0 +1,149 @@
+package requests
+
+import (
+	"database/sql"
+	"encoding/json"
+	"sort"
+
+	"github.com/common-fate/access-inspector/pkg/report"
+	"github.com/jmoiron/sqlx"
+	"github.com/pkg/errors"
+)
+
+// Tables are the tables Access Requests are stored in, in the order they are created.
+var Tables = []string{
+	"commonfate_access_rule",
+	"commonfate_requestor",
+	"commonfate_request",
+	"commonfate_request_argument",
+	"commonfate_grant",
+}
+
+var schema = []string{
+	`CREATE TABLE commonfate_access_rule ("id" TEXT, "version" TEXT, "name" TEXT, "description" TEXT, "provider_id" TEXT, "provider_type" TEXT, "max_duration_seconds" INTEGER, "is_current" BOOLEAN, PRIMARY KEY ("id", "version"))`,
+	`CREATE TABLE commonfate_requestor ("id" TEXT PRIMARY KEY, "email" TEXT, "first_name" TEXT, "last_name" TEXT, "status" TEXT)`,
+	`CREATE TABLE commonfate_request ("id" TEXT PRIMARY KEY, "access_rule_id" TEXT, "access_rule_version" TEXT, "requestor" TEXT, "status" TEXT, "approval_method" TEXT, "reason" TEXT, "requested_at" TIMESTAMP, "approved_at" TIMESTAMP, "updated_at" TIMESTAMP, "duration_seconds" INTEGER, "detail" TEXT)`,
+	`CREATE TABLE commonfate_request_argument ("request_id" TEXT, "name" TEXT, "value" TEXT, "label" TEXT, PRIMARY KEY ("request_id", "name"))`,
+	`CREATE TABLE commonfate_grant ("request_id" TEXT PRIMARY KEY, "provider" TEXT, "subject" TEXT, "status" TEXT, "start" TIMESTAMP, "end" TIMESTAMP)`,
+}
+
+// Save replaces the Access Requests stored in a report. Each request is stored with its
+// access rule, requestor, target arguments and grant, so that they can be queried in SQL.
+// The full request is kept in the detail column of commonfate_request, so that it can be loaded with Stored.
+func Save(db *sqlx.DB, entries []Entry) error {
+	tx, err := db.Beginx()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	for _, t := range Tables {
+		_, err = tx.Exec(`DROP TABLE IF EXISTS ` + t)
+		if err != nil {
+			return err
+		}
+	}
+	for i, stmt := range schema {
+		_, err = tx.Exec(stmt)
+		if err != nil {
+			return errors.Wrapf(err, "creating table %s", Tables[i])
+		}
+	}
+
+	for _, e := range entries {
+		r := e.Request
+
+		_, err = tx.Exec(`INSERT OR IGNORE INTO commonfate_access_rule ("id", "version", "name", "description", "provider_id", "provider_type", "max_duration_seconds", "is_current") VALUES ($1, $2, $3, $4, $5, $6, $7, $8)`,
+			r.AccessRule.ID, r.AccessRule.Version, r.AccessRule.Name, r.AccessRule.Description, r.AccessRule.Target.Provider.Id, r.AccessRule.Target.Provider.Type, r.AccessRule.TimeConstraints.MaxDurationSeconds, r.AccessRule.IsCurrent)
+		if err != nil {
+			return errors.Wrapf(err, "inserting access rule %s", r.AccessRule.ID)
+		}
+
+		_, err = tx.Exec(`INSERT OR IGNORE INTO commonfate_requestor ("id", "email", "first_name", "last_name", "status") VALUES ($1, $2, $3, $4, $5)`,
+			e.User.Id, e.User.Email, e.User.FirstName, e.User.LastName, string(e.User.Status))
+		if err != nil {
+			return errors.Wrapf(err, "inserting requestor %s", e.User.Id)
+		}
+
+		detail, err := json.Marshal(e)
+		if err != nil {
+			return err
+		}
+		var approvalMethod, reason sql.NullString
+		if r.ApprovalMethod != nil {
+			approvalMethod = sql.NullString{String: string(*r.ApprovalMethod), Valid: true}
+		}
+		if r.Reason != nil {
+			reason = sql.NullString{String: *r.Reason, Valid: true}
+		}
+		var approvedAt sql.NullTime
+		if t, ok := e.ApprovedAt(); ok {
+			approvedAt = sql.NullTime{Time: t.UTC(), Valid: true}
+		}
+		_, err = tx.Exec(`INSERT INTO commonfate_request ("id", "access_rule_id", "access_rule_version", "requestor", "status", "approval_method", "reason", "requested_at", "approved_at", "updated_at", "duration_seconds", "detail") VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12)`,
+			r.ID, r.AccessRule.ID, r.AccessRule.Version, r.Requestor, string(r.Status), approvalMethod, reason, r.RequestedAt.UTC(), approvedAt, r.UpdatedAt.UTC(), r.Timing.DurationSeconds, string(detail))
+		if err != nil {
+			return errors.Wrapf(err, "inserting Access Request %s", r.ID)
+		}
+
+		var names []string
+		for name := range r.Arguments.AdditionalProperties {
+			names = append(names, name)
+		}
+		sort.Strings(names)
+		for _, name := range names {
+			arg := r.Arguments.AdditionalProperties[name]
+			_, err = tx.Exec(`INSERT INTO commonfate_request_argument ("request_id", "name", "value", "label") VALUES ($1, $2, $3, $4)`, r.ID, name, arg.Value, arg.Label)
+			if err != nil {
+				return errors.Wrapf(err, "inserting argument %s of Access Request %s", name, r.ID)
+			}
+		}
+
+		if g := r.Grant; g != nil {
+			_, err = tx.Exec(`INSERT INTO commonfate_grant ("request_id", "provider", "subject", "status", "start", "end") VALUES ($1, $2, $3, $4, $5, $6)`,
+				r.ID, g.Provider, string(g.Subject), string(g.Status), g.Start.UTC(), g.End.UTC())
+			if err != nil {
+				return errors.Wrapf(err, "inserting grant of Access Request %s", r.ID)
+			}
+		}
+	}
+
+	err = tx.Commit()
+	if err != nil {
+		return err
+	}
+
+	// the views over the requests are only created once the tables exist
+	err = report.CreateViews(db)
+	if err != nil {
+		return err
+	}
+
+	// refill the entitlement table, so that it includes the active grants
+	return report.CreateEntitlements(db)
+}
+
+// Stored returns the Access Requests saved in a report. If no requests have been saved, it returns nil.
+func Stored(db *sqlx.DB) ([]Entry, error) {
+	exists, err := report.TableExists(db, "commonfate_request")
+	if err != nil || !exists {
+		return nil, err
+	}
+
+	var details []string
+	err = db.Select(&details, `SELECT "detail" FROM commonfate_request ORDER BY "requested_at", "id"`)
+	if err != nil {
+		return nil, err
+	}
+
+	entries := []Entry{}
+	for _, d := range details {
+		var e Entry
+		err = json.Unmarshal([]byte(d), &e)
+		if err != nil {
+			return nil, errors.Wrap(err, "reading stored Access Request")
+		}
+		entries = append(entries, e)
+	}
+	return entries, nil
+}
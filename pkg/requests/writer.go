@@ -0,0 +1,119 @@
+package requests
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"time"
+)
+
+// Format is the format Access Requests are written in.
+type Format string
+
+const (
+	// JSON writes a single array.
+	JSON Format = "json"
+	// NDJSON writes each request as a JSON object on its own line, so that files can be appended to.
+	NDJSON Format = "ndjson"
+)
+
+// ParseFormat parses an output format.
+func ParseFormat(s string) (Format, error) {
+	switch f := Format(s); f {
+	case JSON, NDJSON:
+		return f, nil
+	}
+	return "", fmt.Errorf("unsupported format %q: must be json or ndjson", s)
+}
+
+// Writer writes Access Requests as they are fetched, rather than holding them in memory.
+type Writer struct {
+	w      io.Writer
+	format Format
+	count  int
+}
+
+// NewWriter creates a writer.
+func NewWriter(w io.Writer, format Format) *Writer {
+	return &Writer{w: w, format: format}
+}
+
+// Write writes a request.
+func (w *Writer) Write(e Entry) error {
+	b, err := json.Marshal(e)
+	if err != nil {
+		return err
+	}
+	switch w.format {
+	case NDJSON:
+		b = append(b, '\n')
+	default:
+		if w.count == 0 {
+			b = append([]byte("["), b...)
+		} else {
+			b = append([]byte(","), b...)
+		}
+	}
+	_, err = w.w.Write(b)
+	if err != nil {
+		return err
+	}
+	w.count++
+	return nil
+}
+
+// Close finishes the output. It doesn't close the underlying writer.
+func (w *Writer) Close() error {
+	if w.format == NDJSON {
+		return nil
+	}
+	end := "]"
+	if w.count == 0 {
+		end = "[]"
+	}
+	_, err := io.WriteString(w.w, end)
+	return err
+}
+
+// Count is the number of requests written.
+func (w *Writer) Count() int {
+	return w.count
+}
+
+// Cursor records how far an incremental export has got.
+type Cursor struct {
+	// UpdatedAt is the latest update time of the requests which have been exported.
+	UpdatedAt time.Time `json:"updatedAt"`
+}
+
+// LoadCursor reads a cursor. If the file doesn't exist, the zero cursor is returned, which exports every request.
+func LoadCursor(path string) (Cursor, error) {
+	var c Cursor
+	b, err := os.ReadFile(path)
+	if os.IsNotExist(err) {
+		return c, nil
+	}
+	if err != nil {
+		return c, err
+	}
+	err = json.Unmarshal(b, &c)
+	if err != nil {
+		return c, fmt.Errorf("reading cursor %s: %w", path, err)
+	}
+	return c, nil
+}
+
+// SaveCursor writes a cursor, replacing the file only once it has been written in full.
+func SaveCursor(path string, c Cursor) error {
+	b, err := json.Marshal(c)
+	if err != nil {
+		return err
+	}
+	tmp := path + ".tmp"
+	err = os.WriteFile(tmp, b, 0644)
+	if err != nil {
+		return err
+	}
+	return os.Rename(tmp, path)
+}
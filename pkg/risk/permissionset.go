@@ -0,0 +1,216 @@
+package risk
+
+import (
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/common-fate/access-inspector/pkg/policy"
+)
+
+// check is a privilege which a permission set may grant.
+type check struct {
+	score  int
+	reason string
+	// actions are probed against the permission set's policies. The check matches if any are allowed,
+	// or if all are allowed when all is true.
+	actions []string
+	all     bool
+}
+
+func (c check) matches(set policy.Set) bool {
+	for _, a := range c.actions {
+		allowed := set.Allows(a)
+		if allowed && !c.all {
+			return true
+		}
+		if !allowed && c.all {
+			return false
+		}
+	}
+	return c.all
+}
+
+var checks = []check{
+	// "unknownservice" isn't a real AWS service, so only policies allowing every action match it
+	{100, "grants full administrator access (*:*)", []string{"iam:CreateUser", "organizations:CreateAccount", "unknownservice:DeleteEverything"}, true},
+	{80, "can modify IAM, allowing privilege escalation", []string{"iam:CreateUser", "iam:AttachUserPolicy", "iam:AttachRolePolicy", "iam:PutRolePolicy", "iam:CreateAccessKey", "iam:UpdateAssumeRolePolicy", "iam:CreatePolicyVersion"}, false},
+	{80, "can modify the AWS organization", []string{"organizations:CreateAccount", "organizations:LeaveOrganization", "organizations:AttachPolicy", "account:CloseAccount"}, false},
+	{70, "allows write actions on every service", []string{"unknownservice:DeleteEverything"}, false},
+	{60, "can disable audit logging", []string{"cloudtrail:StopLogging", "cloudtrail:DeleteTrail", "cloudtrail:UpdateTrail"}, false},
+	{50, "can modify billing", []string{"aws-portal:ModifyBilling", "aws-portal:ModifyAccount", "billing:PutContractInformation"}, false},
+	{40, "can pass IAM roles to services", []string{"iam:PassRole"}, false},
+}
+
+// readPrefixes are action name prefixes which don't modify resources.
+var readPrefixes = []string{"get", "list", "describe", "batchget", "view", "search", "lookup", "query", "scan", "select", "head", "generate", "simulate"}
+
+// IsReadOnlyAction returns true if an action, or an action pattern such as "s3:Get*", only reads data.
+func IsReadOnlyAction(action string) bool {
+	_, name, ok := strings.Cut(action, ":")
+	if !ok {
+		return false
+	}
+	name = strings.ToLower(name)
+	for _, p := range readPrefixes {
+		if strings.HasPrefix(name, p) {
+			return true
+		}
+	}
+	return false
+}
+
+// ScorePermissionSet scores a row from the permissionset table. The managed and inline
+// policies are read from any columns containing "managed_polic" and "inline_polic".
+func ScorePermissionSet(columns []string, row []string) PermissionSet {
+	ps := PermissionSet{Evaluated: true}
+	var set policy.Set
+	var reasons []scored
+
+	for i, col := range columns {
+		val := row[i]
+		name := strings.ToLower(col)
+		switch {
+		case name == "id":
+			ps.ARN = val
+		case name == "name":
+			ps.Name = val
+		case val == "" || val == "null":
+			continue
+		case strings.Contains(name, "managed_polic"):
+			for _, ref := range policyRefs(val) {
+				doc, ok := policy.ManagedPolicy(ref)
+				if ok {
+					set = append(set, doc)
+					continue
+				}
+				ps.Evaluated = false
+				reasons = append(reasons, unknownPolicy(ref, strings.Contains(name, "customer")))
+			}
+		case strings.Contains(name, "inline_polic"):
+			doc, err := policy.Parse(val)
+			if err != nil {
+				ps.Evaluated = false
+				reasons = append(reasons, scored{30, fmt.Sprintf("has an inline policy which could not be parsed: %s", err)})
+				continue
+			}
+			set = append(set, doc)
+		}
+	}
+
+	ps.Policies = set
+	reasons = append(reasons, scoreSet(set)...)
+
+	sort.SliceStable(reasons, func(i, j int) bool {
+		return reasons[i].score > reasons[j].score
+	})
+
+	for i, r := range reasons {
+		if i == 0 {
+			ps.Score = r.score
+		}
+		ps.Reasons = append(ps.Reasons, r.reason)
+	}
+	return ps
+}
+
+type scored struct {
+	score  int
+	reason string
+}
+
+// scoreSet runs each check against the policies.
+func scoreSet(set policy.Set) []scored {
+	if len(set) == 0 {
+		return nil
+	}
+
+	var reasons []scored
+	for _, c := range checks {
+		if c.matches(set) {
+			reasons = append(reasons, scored{c.score, c.reason})
+		}
+	}
+
+	// look for wildcards on a whole service, and any write access at all
+	services := map[string]bool{}
+	write := false
+	for _, d := range set {
+		for _, st := range d.Statement {
+			if !strings.EqualFold(st.Effect, "Allow") {
+				continue
+			}
+			if len(st.NotAction) > 0 {
+				write = true
+			}
+			for _, a := range st.Action {
+				service, name, _ := strings.Cut(a, ":")
+				if name == "*" && service != "*" {
+					services[strings.ToLower(service)] = true
+				}
+				if !IsReadOnlyAction(a) {
+					write = true
+				}
+			}
+		}
+	}
+
+	var wildcards []string
+	for s := range services {
+		wildcards = append(wildcards, s)
+	}
+	sort.Strings(wildcards)
+	if len(wildcards) > 0 {
+		reasons = append(reasons, scored{40, fmt.Sprintf("grants all actions on %s", strings.Join(wildcards, ", "))})
+	}
+
+	if write {
+		reasons = append(reasons, scored{30, "allows write actions"})
+	} else {
+		reasons = append(reasons, scored{10, "read-only"})
+	}
+	return reasons
+}
+
+// unknownPolicy scores a managed policy whose contents aren't known, based on its name.
+func unknownPolicy(ref string, customer bool) scored {
+	name := policy.ManagedPolicyName(ref)
+	lower := strings.ToLower(name)
+	kind := "AWS managed"
+	if customer || !strings.Contains(ref, ":aws:policy/") && strings.HasPrefix(ref, "arn:") {
+		kind = "customer managed"
+	}
+	switch {
+	case strings.Contains(lower, "admin"):
+		return scored{60, fmt.Sprintf("has %s policy %s which appears to grant administrative access", kind, name)}
+	case strings.Contains(lower, "fullaccess"):
+		return scored{50, fmt.Sprintf("has %s policy %s which appears to grant full access to a service", kind, name)}
+	case strings.Contains(lower, "readonly"):
+		return scored{10, fmt.Sprintf("has %s policy %s which appears to be read-only", kind, name)}
+	}
+	return scored{20, fmt.Sprintf("has %s policy %s which could not be evaluated", kind, name)}
+}
+
+// policyRefs parses a managed policies column, which may be a JSON list of ARNs or names,
+// a list of objects with an arn or name field, or a single string.
+func policyRefs(val string) []string {
+	var strs []string
+	if err := json.Unmarshal([]byte(val), &strs); err == nil {
+		return strs
+	}
+	var objs []map[string]any
+	if err := json.Unmarshal([]byte(val), &objs); err == nil {
+		var refs []string
+		for _, o := range objs {
+			for _, k := range []string{"arn", "Arn", "ARN", "name", "Name"} {
+				if s, ok := o[k].(string); ok && s != "" {
+					refs = append(refs, s)
+					break
+				}
+			}
+		}
+		return refs
+	}
+	return []string{val}
+}
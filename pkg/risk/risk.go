@@ -0,0 +1,257 @@
+// Package risk scores the privilege of permission sets and the sensitivity of accounts,
+// so that the most dangerous standing access can be prioritised for removal.
+package risk
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"sort"
+	"strings"
+
+	"github.com/common-fate/access-inspector/pkg/policy"
+	"github.com/common-fate/access-inspector/pkg/report"
+	"github.com/jmoiron/sqlx"
+	"github.com/pkg/errors"
+)
+
+// Level is the sensitivity of an account.
+type Level string
+
+const (
+	Low      Level = "low"
+	Medium   Level = "medium"
+	High     Level = "high"
+	Critical Level = "critical"
+)
+
+// Weight is the multiplier applied to permission set scores for assignments in an account
+// with this sensitivity.
+func (l Level) Weight() int {
+	switch l {
+	case Low:
+		return 1
+	case High:
+		return 3
+	case Critical:
+		return 4
+	default:
+		return 2
+	}
+}
+
+// ParseLevel parses a sensitivity level, such as the value of an account tag.
+func ParseLevel(s string) (Level, error) {
+	l := Level(strings.ToLower(strings.TrimSpace(s)))
+	switch l {
+	case Low, Medium, High, Critical:
+		return l, nil
+	}
+	return "", fmt.Errorf("invalid account sensitivity %q: must be one of low, medium, high, critical", s)
+}
+
+// PermissionSet is the privilege score of a permission set.
+type PermissionSet struct {
+	ARN   string `json:"arn"`
+	Name  string `json:"name"`
+	Score int    `json:"score"`
+	// Reasons explain the score, with the highest scoring reason first.
+	Reasons []string `json:"reasons"`
+	// Policies are the managed and inline policies attached to the permission set
+	// which could be evaluated.
+	Policies policy.Set `json:"-"`
+	// Evaluated is true if every policy attached to the permission set could be evaluated,
+	// meaning that Policies describes all of the access the permission set grants.
+	Evaluated bool `json:"evaluated"`
+}
+
+// Summary returns the most significant reason for the score.
+func (p PermissionSet) Summary() string {
+	if len(p.Reasons) == 0 {
+		return "no policies attached"
+	}
+	return p.Reasons[0]
+}
+
+// Score is the risk of an account assignment.
+type Score struct {
+	// Value is the permission set score multiplied by the account sensitivity weight.
+	Value         int           `json:"value"`
+	PermissionSet PermissionSet `json:"permissionSet"`
+	Sensitivity   Level         `json:"sensitivity"`
+}
+
+func (s Score) String() string {
+	return fmt.Sprintf("%d (%s, %s account: %s)", s.Value, s.PermissionSet.Name, s.Sensitivity, s.PermissionSet.Summary())
+}
+
+// Options configure how account sensitivity is determined.
+type Options struct {
+	// SensitivityFile is an optional JSON file mapping account IDs or names to sensitivity levels.
+	SensitivityFile string
+	// SensitivityTag is the account tag holding the sensitivity level.
+	SensitivityTag string
+}
+
+// SensitivityConfig is the format of the account sensitivity file.
+//
+//	{"default": "medium", "accounts": {"123456789012": "critical", "sandbox": "low"}}
+type SensitivityConfig struct {
+	Default  Level            `json:"default"`
+	Accounts map[string]Level `json:"accounts"`
+}
+
+// Scorer scores account assignments.
+type Scorer struct {
+	// PermissionSets are keyed by ARN.
+	PermissionSets map[string]PermissionSet
+	// Accounts are keyed by account ID.
+	Accounts map[string]Level
+	Default  Level
+}
+
+// Assignment scores an account assignment.
+func (s *Scorer) Assignment(accountID, permissionSetARN string) Score {
+	ps, ok := s.PermissionSets[permissionSetARN]
+	if !ok {
+		ps = PermissionSet{ARN: permissionSetARN, Name: permissionSetARN}
+	}
+	level := s.Account(accountID)
+	return Score{
+		Value:         ps.Score * level.Weight(),
+		PermissionSet: ps,
+		Sensitivity:   level,
+	}
+}
+
+// Account returns the sensitivity of an account.
+func (s *Scorer) Account(accountID string) Level {
+	if l, ok := s.Accounts[accountID]; ok {
+		return l
+	}
+	return s.Default
+}
+
+// Ranked returns the permission sets ordered from most to least risky.
+func (s *Scorer) Ranked() []PermissionSet {
+	var ranked []PermissionSet
+	for _, ps := range s.PermissionSets {
+		ranked = append(ranked, ps)
+	}
+	sort.SliceStable(ranked, func(i, j int) bool {
+		if ranked[i].Score != ranked[j].Score {
+			return ranked[i].Score > ranked[j].Score
+		}
+		return ranked[i].Name < ranked[j].Name
+	})
+	return ranked
+}
+
+// Load builds a Scorer from the permissionset and account tables in a report.
+func Load(db *sqlx.DB, opts Options) (*Scorer, error) {
+	s := Scorer{
+		PermissionSets: map[string]PermissionSet{},
+		Accounts:       map[string]Level{},
+		Default:        Medium,
+	}
+
+	permissionSets, err := report.LoadTable(db, "permissionset")
+	if err != nil {
+		return nil, err
+	}
+	for _, row := range permissionSets.Rows {
+		ps := ScorePermissionSet(permissionSets.Columns, row)
+		s.PermissionSets[ps.ARN] = ps
+	}
+
+	accounts, err := report.LoadTable(db, "account")
+	if err != nil {
+		return nil, err
+	}
+
+	var cfg SensitivityConfig
+	if opts.SensitivityFile != "" {
+		b, err := os.ReadFile(opts.SensitivityFile)
+		if err != nil {
+			return nil, err
+		}
+		err = json.Unmarshal(b, &cfg)
+		if err != nil {
+			return nil, errors.Wrapf(err, "parsing account sensitivity file %s", opts.SensitivityFile)
+		}
+		for k, v := range cfg.Accounts {
+			l, err := ParseLevel(string(v))
+			if err != nil {
+				return nil, errors.Wrapf(err, "account %s", k)
+			}
+			cfg.Accounts[k] = l
+		}
+		if cfg.Default != "" {
+			s.Default, err = ParseLevel(string(cfg.Default))
+			if err != nil {
+				return nil, err
+			}
+		}
+	}
+
+	for _, row := range accounts.Rows {
+		var id, name, tags string
+		for i, col := range accounts.Columns {
+			switch col {
+			case "id":
+				id = row[i]
+			case "name":
+				name = row[i]
+			case "tags":
+				tags = row[i]
+			}
+		}
+
+		// the sensitivity file takes precedence over account tags
+		if l, ok := cfg.Accounts[id]; ok {
+			s.Accounts[id] = l
+			continue
+		}
+		if l, ok := cfg.Accounts[name]; ok && name != "" {
+			s.Accounts[id] = l
+			continue
+		}
+		if opts.SensitivityTag != "" && tags != "" {
+			if v, ok := tagValue(tags, opts.SensitivityTag); ok {
+				l, err := ParseLevel(v)
+				if err != nil {
+					return nil, errors.Wrapf(err, "account %s tag %s", id, opts.SensitivityTag)
+				}
+				s.Accounts[id] = l
+			}
+		}
+	}
+
+	return &s, nil
+}
+
+// tagValue looks up a tag from a JSON encoded tags column. Tags may be stored either
+// as an object, or as a list of {"Key": ..., "Value": ...} pairs as returned by the AWS APIs.
+func tagValue(tags string, key string) (string, bool) {
+	var m map[string]string
+	if err := json.Unmarshal([]byte(tags), &m); err == nil {
+		v, ok := m[key]
+		return v, ok
+	}
+	var list []map[string]string
+	if err := json.Unmarshal([]byte(tags), &list); err == nil {
+		for _, t := range list {
+			k := t["Key"]
+			if k == "" {
+				k = t["key"]
+			}
+			if k == key {
+				if v, ok := t["Value"]; ok {
+					return v, true
+				}
+				return t["value"], true
+			}
+		}
+	}
+	return "", false
+}
@@ -0,0 +1,180 @@
+package risk
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/common-fate/access-inspector/internal/reporttest"
+)
+
+func TestScorePermissionSet(t *testing.T) {
+	columns := []string{"id", "name", "managed_policies", "inline_policy"}
+
+	tests := []struct {
+		name          string
+		managed       string
+		inline        string
+		wantScore     int
+		wantSummary   string
+		wantEvaluated bool
+	}{
+		{
+			name:          "administrator",
+			managed:       `["arn:aws:iam::aws:policy/AdministratorAccess"]`,
+			wantScore:     100,
+			wantSummary:   "grants full administrator access (*:*)",
+			wantEvaluated: true,
+		},
+		{
+			name:          "read-only",
+			managed:       `["arn:aws:iam::aws:policy/job-function/ViewOnlyAccess"]`,
+			wantScore:     10,
+			wantSummary:   "read-only",
+			wantEvaluated: true,
+		},
+		{
+			name:          "NotAction",
+			managed:       `["arn:aws:iam::aws:policy/PowerUserAccess"]`,
+			wantScore:     70,
+			wantSummary:   "allows write actions on every service",
+			wantEvaluated: true,
+		},
+		{
+			name:          "service wildcard",
+			inline:        `{"Statement": {"Effect": "Allow", "Action": "s3:*", "Resource": "*"}}`,
+			wantScore:     40,
+			wantSummary:   "grants all actions on s3",
+			wantEvaluated: true,
+		},
+		{
+			// the Deny only applies to one role, so IAM can still be modified
+			name:          "administrator with a scoped Deny",
+			managed:       `["arn:aws:iam::aws:policy/AdministratorAccess"]`,
+			inline:        `{"Statement": {"Effect": "Deny", "Action": "iam:*", "Resource": "arn:aws:iam::*:role/breakglass"}}`,
+			wantScore:     100,
+			wantSummary:   "grants full administrator access (*:*)",
+			wantEvaluated: true,
+		},
+		{
+			name:          "administrator with IAM denied",
+			managed:       `["arn:aws:iam::aws:policy/AdministratorAccess"]`,
+			inline:        `{"Statement": {"Effect": "Deny", "Action": "iam:*", "Resource": "*"}}`,
+			wantScore:     80,
+			wantSummary:   "can modify the AWS organization",
+			wantEvaluated: true,
+		},
+		{
+			name:          "unknown customer managed policy",
+			managed:       `["arn:aws:iam::123456789012:policy/ProdAdmin"]`,
+			wantScore:     60,
+			wantSummary:   "has customer managed policy ProdAdmin which appears to grant administrative access",
+			wantEvaluated: false,
+		},
+		{
+			name:          "invalid inline policy",
+			inline:        `{"Statement": `,
+			wantScore:     30,
+			wantEvaluated: false,
+		},
+		{
+			name:          "no policies",
+			wantScore:     0,
+			wantSummary:   "no policies attached",
+			wantEvaluated: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ps := ScorePermissionSet(columns, []string{"ps-1", tt.name, tt.managed, tt.inline})
+			if ps.Score != tt.wantScore {
+				t.Errorf("got score %d, want %d: %v", ps.Score, tt.wantScore, ps.Reasons)
+			}
+			if tt.wantSummary != "" && ps.Summary() != tt.wantSummary {
+				t.Errorf("got summary %q, want %q", ps.Summary(), tt.wantSummary)
+			}
+			if ps.Evaluated != tt.wantEvaluated {
+				t.Errorf("got evaluated %v, want %v", ps.Evaluated, tt.wantEvaluated)
+			}
+		})
+	}
+}
+
+func TestParseLevel(t *testing.T) {
+	tests := []struct {
+		in      string
+		want    Level
+		wantErr bool
+	}{
+		{in: "low", want: Low},
+		{in: " Critical ", want: Critical},
+		{in: "HIGH", want: High},
+		{in: "severe", wantErr: true},
+		{in: "", wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.in, func(t *testing.T) {
+			got, err := ParseLevel(tt.in)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("got error %v, want error %v", err, tt.wantErr)
+			}
+			if got != tt.want {
+				t.Errorf("got %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestLoadSensitivity(t *testing.T) {
+	db := reporttest.New(t, reporttest.Default(
+		reporttest.Account("111111111111", "prod", map[string]string{"sensitivity": "critical"}),
+		// the sensitivity file takes precedence over the tag
+		reporttest.Account("222222222222", "staging", map[string]string{"sensitivity": "critical"}),
+		reporttest.Account("333333333333", "sandbox", nil),
+		reporttest.Account("444444444444", "shared", nil),
+		reporttest.PermissionSet("ps-admin", "AdministratorAccess", "arn:aws:iam::aws:policy/AdministratorAccess"),
+	))
+
+	file := filepath.Join(t.TempDir(), "sensitivity.json")
+	err := os.WriteFile(file, []byte(`{"default": "high", "accounts": {"222222222222": "medium", "sandbox": "LOW"}}`), 0644)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	s, err := Load(db, Options{SensitivityFile: file, SensitivityTag: "sensitivity"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		account   string
+		want      Level
+		wantValue int
+	}{
+		{"111111111111", Critical, 400},
+		{"222222222222", Medium, 200},
+		{"333333333333", Low, 100},
+		{"444444444444", High, 300},
+	}
+	for _, tt := range tests {
+		t.Run(tt.account, func(t *testing.T) {
+			score := s.Assignment(tt.account, "ps-admin")
+			if score.Sensitivity != tt.want {
+				t.Errorf("got sensitivity %s, want %s", score.Sensitivity, tt.want)
+			}
+			if score.Value != tt.wantValue {
+				t.Errorf("got risk %d, want %d", score.Value, tt.wantValue)
+			}
+		})
+	}
+}
+
+func TestLoadInvalidTag(t *testing.T) {
+	db := reporttest.New(t, reporttest.Default(
+		reporttest.Account("111111111111", "prod", map[string]string{"sensitivity": "severe"}),
+	))
+	_, err := Load(db, Options{SensitivityTag: "sensitivity"})
+	if err == nil {
+		t.Error("expected an error for an invalid sensitivity tag")
+	}
+}
@@ -0,0 +1,134 @@
+package server
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+
+	"github.com/common-fate/access-inspector/pkg/accessgraph"
+	"github.com/common-fate/access-inspector/pkg/remediation"
+)
+
+// maxGraphNodes limits the size of graphs returned without a starting resource.
+const maxGraphNodes = 2000
+
+// graph returns the nodes and edges of the entitlement graph, filtered with the same
+// options as the graph command: from, depth, includeType, excludeType and collapseGroups.
+func (s *Server) graph(w http.ResponseWriter, r *http.Request) {
+	query := r.URL.Query()
+	depth, err := intParam(r, "depth", 0)
+	if err != nil {
+		writeError(w, http.StatusBadRequest, err)
+		return
+	}
+	filter := accessgraph.Filter{
+		Depth:          depth,
+		IncludeTypes:   query["includeType"],
+		ExcludeTypes:   query["excludeType"],
+		CollapseGroups: query.Get("collapseGroups") == "true",
+	}
+	for _, from := range query["from"] {
+		v, err := accessgraph.FindVertex(s.g, from)
+		var invalid accessgraph.InvalidResourceError
+		if errors.As(err, &invalid) {
+			writeError(w, http.StatusBadRequest, fmt.Errorf("from %s", err))
+			return
+		}
+		if err != nil {
+			writeError(w, http.StatusNotFound, err)
+			return
+		}
+		filter.Start = append(filter.Start, v)
+	}
+
+	g, err := accessgraph.Subgraph(s.g, s.m, filter)
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, err)
+		return
+	}
+	e, err := accessgraph.Flatten(g)
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, err)
+		return
+	}
+	if len(e.Nodes) > maxGraphNodes {
+		writeError(w, http.StatusBadRequest, fmt.Errorf("the graph has %d nodes, which is more than the limit of %d: use from, depth or the type filters to select part of it", len(e.Nodes), maxGraphNodes))
+		return
+	}
+	if e.Nodes == nil {
+		e.Nodes = []accessgraph.Node{}
+	}
+	if e.Edges == nil {
+		e.Edges = []accessgraph.Edge{}
+	}
+	writeJSON(w, http.StatusOK, e)
+}
+
+// RemovalRequest identifies an account assignment to include in a remediation script.
+type RemovalRequest struct {
+	PrincipalType    remediation.PrincipalType `json:"principalType"`
+	PrincipalID      string                    `json:"principalId"`
+	AccountID        string                    `json:"accountId"`
+	PermissionSetARN string                    `json:"permissionSetArn"`
+	// Instance is the instance holding the account assignment. It's empty for the default instance.
+	Instance string `json:"instance,omitempty"`
+}
+
+// remediationScript builds a script which removes the posted account assignments.
+// It doesn't change the report or the provider: the script must be reviewed and run separately.
+func (s *Server) remediationScript(w http.ResponseWriter, r *http.Request) {
+	var reqs []RemovalRequest
+	err := json.NewDecoder(r.Body).Decode(&reqs)
+	if err != nil {
+		writeError(w, http.StatusBadRequest, fmt.Errorf("invalid request body: %w", err))
+		return
+	}
+
+	var removals []remediation.Removal
+	for _, req := range reqs {
+		removal := remediation.Removal{
+			PrincipalType:     req.PrincipalType,
+			PrincipalID:       req.PrincipalID,
+			AccountID:         req.AccountID,
+			AccountName:       s.name(accessgraph.Key(s.m.Account, req.Instance, req.AccountID)),
+			PermissionSetARN:  req.PermissionSetARN,
+			PermissionSetName: s.name(accessgraph.Key(s.m.PermissionSet, req.Instance, req.PermissionSetARN)),
+		}
+		switch req.PrincipalType {
+		case remediation.PrincipalUser:
+			u, err := s.g.Vertex(accessgraph.Key(s.m.User, req.Instance, req.PrincipalID))
+			if err != nil {
+				writeError(w, http.StatusBadRequest, fmt.Errorf("user %s not found", req.PrincipalID))
+				return
+			}
+			removal.PrincipalName = u.Name
+			if email, ok := u.Data["email"].(string); ok && email != "" {
+				removal.PrincipalName = email
+			}
+		case remediation.PrincipalGroup:
+			removal.PrincipalName = s.name(accessgraph.Key(s.m.Group, req.Instance, req.PrincipalID))
+		default:
+			writeError(w, http.StatusBadRequest, fmt.Errorf("invalid principal type %q: must be USER or GROUP", req.PrincipalType))
+			return
+		}
+		if removal.AccountName == "" {
+			removal.AccountName = req.AccountID
+		}
+		if removal.PermissionSetName == "" {
+			removal.PermissionSetName = req.PermissionSetARN
+		}
+		removals = append(removals, removal)
+	}
+
+	var b bytes.Buffer
+	err = remediation.WriteScript(&b, remediation.ScriptOptions{InstanceARN: s.instanceARN, Region: s.region, Instances: s.instances}, removals)
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, err)
+		return
+	}
+	w.Header().Set("Content-Type", "text/x-shellscript")
+	w.Header().Set("Content-Disposition", `attachment; filename="remediation.sh"`)
+	_, _ = w.Write(b.Bytes())
+}
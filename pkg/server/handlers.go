@@ -0,0 +1,308 @@
+package server
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+
+	"github.com/common-fate/access-inspector/pkg/accessgraph"
+	"github.com/common-fate/access-inspector/pkg/findings"
+	"github.com/common-fate/access-inspector/pkg/report"
+	"github.com/common-fate/access-inspector/pkg/risk"
+	"github.com/common-fate/access-inspector/pkg/snapshot"
+	"github.com/go-chi/chi/v5"
+	"github.com/jmoiron/sqlx"
+)
+
+// Resource is a row of a resource table, keyed by column name.
+type Resource map[string]string
+
+func (s *Server) loadResources(resourceType string) ([]Resource, []string, error) {
+	t, err := report.LoadTable(s.db, report.TableName(resourceType))
+	if err != nil {
+		return nil, nil, err
+	}
+	resources := []Resource{}
+	for _, row := range t.Rows {
+		res := Resource{}
+		for i, col := range t.Columns {
+			res[col] = row[i]
+		}
+		resources = append(resources, res)
+	}
+	return resources, t.Columns, nil
+}
+
+// listResources lists resources of a type. The q parameter searches every column, and
+// any other parameter named after a column filters on that column's value.
+func (s *Server) listResources(resourceType string) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		resources, columns, err := s.loadResources(resourceType)
+		if err != nil {
+			writeError(w, http.StatusInternalServerError, err)
+			return
+		}
+
+		query := r.URL.Query()
+		filters := map[string]string{}
+		for k := range query {
+			if reservedParams[k] {
+				continue
+			}
+			found := false
+			for _, c := range columns {
+				if c == k {
+					found = true
+					break
+				}
+			}
+			if !found {
+				writeError(w, http.StatusBadRequest, fmt.Errorf("unknown filter %q: must be one of limit, offset, q, %s", k, strings.Join(columns, ", ")))
+				return
+			}
+			filters[k] = query.Get(k)
+		}
+		search := strings.ToLower(query.Get("q"))
+
+		matching := []Resource{}
+		for _, res := range resources {
+			ok := true
+			for k, v := range filters {
+				if !matchesFilter(v, res[k]) {
+					ok = false
+					break
+				}
+			}
+			if ok && search != "" {
+				ok = false
+				for _, v := range res {
+					if strings.Contains(strings.ToLower(v), search) {
+						ok = true
+						break
+					}
+				}
+			}
+			if ok {
+				matching = append(matching, res)
+			}
+		}
+		paginate(w, r, matching)
+	}
+}
+
+// resourceID returns the ID in the path, which is the {id} parameter or the wildcard.
+func resourceID(r *http.Request) string {
+	if id := chi.URLParam(r, "id"); id != "" {
+		return id
+	}
+	return chi.URLParam(r, "*")
+}
+
+func (s *Server) getResource(resourceType string) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		id := resourceID(r)
+		resources, _, err := s.loadResources(resourceType)
+		if err != nil {
+			writeError(w, http.StatusInternalServerError, err)
+			return
+		}
+		for _, res := range resources {
+			if res["id"] == id {
+				writeJSON(w, http.StatusOK, res)
+				return
+			}
+		}
+		writeError(w, http.StatusNotFound, fmt.Errorf("%s %s not found", resourceType, id))
+	}
+}
+
+// Access is a path granting a user a permission set in an account.
+type Access struct {
+	UserID            string               `json:"userId"`
+	UserName          string               `json:"userName,omitempty"`
+	AccountID         string               `json:"accountId"`
+	AccountName       string               `json:"accountName,omitempty"`
+	PermissionSetID   string               `json:"permissionSetId"`
+	PermissionSetName string               `json:"permissionSetName,omitempty"`
+	PathType          accessgraph.PathType `json:"pathType"`
+	AssignmentID      string               `json:"assignmentId"`
+	Instance          string               `json:"instance,omitempty"`
+	Path              []accessgraph.Hop    `json:"path"`
+}
+
+// name returns the name of the resource with a vertex key.
+func (s *Server) name(key string) string {
+	if r, err := s.g.Vertex(key); err == nil {
+		return r.Name
+	}
+	return ""
+}
+
+// filterAccess converts access to API responses, applying the account, permissionSet, pathType and instance filters.
+func (s *Server) filterAccess(r *http.Request, access []accessgraph.Access) []Access {
+	query := r.URL.Query()
+	items := []Access{}
+	for _, a := range access {
+		item := Access{
+			UserID:            a.UserID,
+			UserName:          s.name(a.UserKey(s.m)),
+			AccountID:         a.AccountID,
+			AccountName:       s.name(a.AccountKey(s.m)),
+			PermissionSetID:   a.PermissionSetID,
+			PermissionSetName: s.name(a.PermissionSetKey(s.m)),
+			PathType:          a.PathType,
+			AssignmentID:      a.AssignmentID,
+			Instance:          a.Instance,
+			Path:              accessgraph.Hops(s.g, a.Path),
+		}
+		if !matchesFilter(query.Get("account"), item.AccountID, item.AccountName) ||
+			!matchesFilter(query.Get("permissionSet"), item.PermissionSetID, item.PermissionSetName) ||
+			!matchesFilter(query.Get("pathType"), string(item.PathType)) ||
+			!matchesFilter(query.Get("instance"), item.Instance) {
+			continue
+		}
+		items = append(items, item)
+	}
+	return items
+}
+
+// exists returns true if a resource with the ID is in any instance.
+func (s *Server) exists(t string, id string) bool {
+	keys, err := accessgraph.Lookup(s.g, t, id)
+	return err == nil && len(keys) > 0
+}
+
+func (s *Server) userEffectiveAccess(w http.ResponseWriter, r *http.Request) {
+	id := chi.URLParam(r, "id")
+	if !s.exists(s.m.User, id) {
+		writeError(w, http.StatusNotFound, fmt.Errorf("user %s not found", id))
+		return
+	}
+	var access []accessgraph.Access
+	for _, a := range s.access {
+		if a.UserID == id {
+			access = append(access, a)
+		}
+	}
+	paginate(w, r, s.filterAccess(r, access))
+}
+
+func (s *Server) accountPrincipals(w http.ResponseWriter, r *http.Request) {
+	id := chi.URLParam(r, "id")
+	if !s.exists(s.m.Account, id) {
+		writeError(w, http.StatusNotFound, fmt.Errorf("account %s not found", id))
+		return
+	}
+	var access []accessgraph.Access
+	for _, a := range s.access {
+		if a.AccountID == id {
+			access = append(access, a)
+		}
+	}
+	paginate(w, r, s.filterAccess(r, access))
+}
+
+// listFindings lists standing access, filtered by the status, account, user, permissionSet,
+// sensitivity and minRisk parameters.
+func (s *Server) listFindings(w http.ResponseWriter, r *http.Request) {
+	query := r.URL.Query()
+	minRisk, err := intParam(r, "minRisk", 0)
+	if err != nil {
+		writeError(w, http.StatusBadRequest, err)
+		return
+	}
+	var sensitivity risk.Level
+	if v := query.Get("sensitivity"); v != "" {
+		sensitivity, err = risk.ParseLevel(v)
+		if err != nil {
+			writeError(w, http.StatusBadRequest, err)
+			return
+		}
+	}
+
+	items := []findings.Finding{}
+	for _, f := range s.findings {
+		if !matchesFilter(query.Get("status"), string(f.Status)) ||
+			!matchesFilter(query.Get("account"), f.AccountID, f.AccountName) ||
+			!matchesFilter(query.Get("user"), f.UserID, f.UserEmail) ||
+			!matchesFilter(query.Get("permissionSet"), f.PermissionSetARN, f.PermissionSetName) ||
+			(sensitivity != "" && f.Risk.Sensitivity != sensitivity) ||
+			f.Risk.Value < minRisk {
+			continue
+		}
+		items = append(items, f)
+	}
+	paginate(w, r, items)
+}
+
+func (s *Server) listSnapshots(w http.ResponseWriter, r *http.Request) {
+	if s.snapshotsDir == "" {
+		writeJSON(w, http.StatusOK, []string{})
+		return
+	}
+	names, err := snapshot.List(s.snapshotsDir)
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, err)
+		return
+	}
+	if names == nil {
+		names = []string{}
+	}
+	writeJSON(w, http.StatusOK, names)
+}
+
+// current is the name used in diffs for the report being served.
+const current = "current"
+
+// diff compares two snapshots, given by the from and to parameters. Either may be "current",
+// which is the served report, and to defaults to it.
+func (s *Server) diff(w http.ResponseWriter, r *http.Request) {
+	query := r.URL.Query()
+	from, to := query.Get("from"), query.Get("to")
+	if to == "" {
+		to = current
+	}
+	if from == "" {
+		writeError(w, http.StatusBadRequest, errors.New("the from parameter is required"))
+		return
+	}
+
+	fromDB, err := s.openSnapshot(from)
+	if err != nil {
+		writeError(w, http.StatusNotFound, err)
+		return
+	}
+	if fromDB != s.db {
+		defer fromDB.Close()
+	}
+	toDB, err := s.openSnapshot(to)
+	if err != nil {
+		writeError(w, http.StatusNotFound, err)
+		return
+	}
+	if toDB != s.db {
+		defer toDB.Close()
+	}
+
+	d, err := snapshot.Compare(fromDB, toDB, s.m)
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, err)
+		return
+	}
+	writeJSON(w, http.StatusOK, d)
+}
+
+func (s *Server) openSnapshot(name string) (*sqlx.DB, error) {
+	if name == current {
+		return s.db, nil
+	}
+	if s.snapshotsDir == "" {
+		return nil, fmt.Errorf("snapshot %s not found: the server was started without a snapshots directory", name)
+	}
+	path, ok := snapshot.Path(s.snapshotsDir, name)
+	if !ok {
+		return nil, fmt.Errorf("snapshot %s not found", name)
+	}
+	return report.OpenReadOnly(path)
+}
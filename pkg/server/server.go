@@ -0,0 +1,217 @@
+// Package server serves report data through a read-only JSON API and an embedded web UI.
+package server
+
+import (
+	_ "embed"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/common-fate/access-inspector/pkg/accessgraph"
+	"github.com/common-fate/access-inspector/pkg/findings"
+	"github.com/common-fate/access-inspector/pkg/remediation"
+	"github.com/common-fate/access-inspector/pkg/risk"
+	"github.com/common-fate/clio"
+	"github.com/go-chi/chi/v5"
+	"github.com/go-chi/chi/v5/middleware"
+	"github.com/jmoiron/sqlx"
+)
+
+//go:embed openapi.json
+var openAPI []byte
+
+// Options configure the server.
+type Options struct {
+	Model  accessgraph.Model
+	Scorer *risk.Scorer
+	// Findings configure which standing access is reported as removable.
+	Findings findings.Options
+	// SnapshotsDir is a directory of other reports which the served report can be compared against.
+	SnapshotsDir string
+	// InstanceARN and Region are used in remediation scripts.
+	InstanceARN string
+	Region      string
+	// Instances are the AWS SSO instances holding the account assignments of each permission set, keyed by ARN.
+	Instances map[string]remediation.SSOInstance
+}
+
+// Server serves a report.
+type Server struct {
+	db           *sqlx.DB
+	g            accessgraph.Graph
+	m            accessgraph.Model
+	access       []accessgraph.Access
+	findings     []findings.Finding
+	snapshotsDir string
+	instanceARN  string
+	region       string
+	instances    map[string]remediation.SSOInstance
+}
+
+// New creates a server for a report and its entitlement graph. Effective access
+// and findings are worked out once, as the report is read-only.
+func New(db *sqlx.DB, g accessgraph.Graph, opts Options) (*Server, error) {
+	access, err := accessgraph.AllEffectiveAccess(g, opts.Model)
+	if err != nil {
+		return nil, err
+	}
+
+	f, err := findings.Compute(db, opts.Scorer, opts.Findings)
+	if err != nil {
+		return nil, err
+	}
+
+	return &Server{
+		db:           db,
+		g:            g,
+		m:            opts.Model,
+		access:       access,
+		findings:     f,
+		snapshotsDir: opts.SnapshotsDir,
+		instanceARN:  opts.InstanceARN,
+		region:       opts.Region,
+		instances:    opts.Instances,
+	}, nil
+}
+
+// Routes returns the handler for the API, which is under /api, and the web UI.
+func (s *Server) Routes() chi.Router {
+	r := chi.NewRouter()
+	r.Use(middleware.Recoverer)
+	r.Use(requestLogger)
+
+	r.Route("/api", func(r chi.Router) {
+		r.Get("/openapi.json", func(w http.ResponseWriter, r *http.Request) {
+			w.Header().Set("Content-Type", "application/json")
+			_, _ = w.Write(openAPI)
+		})
+
+		r.Get("/users", s.listResources(s.m.User))
+		r.Get("/users/{id}", s.getResource(s.m.User))
+		r.Get("/users/{id}/effective-access", s.userEffectiveAccess)
+
+		r.Get("/groups", s.listResources(s.m.Group))
+		r.Get("/groups/{id}", s.getResource(s.m.Group))
+
+		r.Get("/accounts", s.listResources(s.m.Account))
+		r.Get("/accounts/{id}", s.getResource(s.m.Account))
+		r.Get("/accounts/{id}/principals", s.accountPrincipals)
+
+		r.Get("/permission-sets", s.listResources(s.m.PermissionSet))
+		// permission set IDs are ARNs, which contain slashes
+		r.Get("/permission-sets/*", s.getResource(s.m.PermissionSet))
+
+		r.Get("/findings", s.listFindings)
+
+		r.Get("/snapshots", s.listSnapshots)
+		r.Get("/diff", s.diff)
+
+		r.Get("/graph", s.graph)
+		r.Post("/remediation-script", s.remediationScript)
+	})
+
+	r.Handle("/*", uiHandler())
+
+	return r
+}
+
+func requestLogger(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		clio.Debugw("request", "method", r.Method, "path", r.URL.Path, "query", r.URL.RawQuery)
+		next.ServeHTTP(w, r)
+	})
+}
+
+// Error is the body of an error response.
+type Error struct {
+	Error string `json:"error"`
+}
+
+func writeJSON(w http.ResponseWriter, status int, v any) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	err := json.NewEncoder(w).Encode(v)
+	if err != nil {
+		clio.Errorf("writing response: %s", err)
+	}
+}
+
+func writeError(w http.ResponseWriter, status int, err error) {
+	writeJSON(w, status, Error{Error: err.Error()})
+}
+
+// Page is a page of results.
+type Page[T any] struct {
+	Items []T `json:"items"`
+	// Total is the number of results matching the filters, across every page.
+	Total  int `json:"total"`
+	Limit  int `json:"limit"`
+	Offset int `json:"offset"`
+}
+
+const (
+	defaultLimit = 100
+	maxLimit     = 1000
+)
+
+// paginate writes the page of items selected by the limit and offset query parameters.
+func paginate[T any](w http.ResponseWriter, r *http.Request, items []T) {
+	limit, err := intParam(r, "limit", defaultLimit)
+	if err != nil {
+		writeError(w, http.StatusBadRequest, err)
+		return
+	}
+	if limit < 1 || limit > maxLimit {
+		writeError(w, http.StatusBadRequest, fmt.Errorf("limit must be between 1 and %d", maxLimit))
+		return
+	}
+	offset, err := intParam(r, "offset", 0)
+	if err != nil {
+		writeError(w, http.StatusBadRequest, err)
+		return
+	}
+	if offset < 0 {
+		writeError(w, http.StatusBadRequest, fmt.Errorf("offset must not be negative"))
+		return
+	}
+
+	page := Page[T]{Items: []T{}, Total: len(items), Limit: limit, Offset: offset}
+	if offset < len(items) {
+		end := offset + limit
+		if end > len(items) {
+			end = len(items)
+		}
+		page.Items = items[offset:end]
+	}
+	writeJSON(w, http.StatusOK, page)
+}
+
+func intParam(r *http.Request, name string, def int) (int, error) {
+	v := r.URL.Query().Get(name)
+	if v == "" {
+		return def, nil
+	}
+	i, err := strconv.Atoi(v)
+	if err != nil {
+		return 0, fmt.Errorf("%s must be a number", name)
+	}
+	return i, nil
+}
+
+// reservedParams are query parameters which aren't column filters.
+var reservedParams = map[string]bool{"limit": true, "offset": true, "q": true}
+
+// matchesFilter returns true if value equals the filter, or the filter is empty.
+func matchesFilter(filter string, values ...string) bool {
+	if filter == "" {
+		return true
+	}
+	for _, v := range values {
+		if strings.EqualFold(v, filter) {
+			return true
+		}
+	}
+	return false
+}
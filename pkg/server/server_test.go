@@ -0,0 +1,225 @@
+package server
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/common-fate/access-inspector/internal/reporttest"
+	"github.com/common-fate/access-inspector/pkg/accessgraph"
+	"github.com/common-fate/access-inspector/pkg/report"
+	"github.com/common-fate/access-inspector/pkg/risk"
+	"github.com/common-fate/access-inspector/pkg/snapshot"
+	"github.com/common-fate/provider-registry-sdk-go/pkg/msg"
+)
+
+const (
+	adminARN    = "arn:aws:sso:::permissionSet/ssoins-1/ps-admin"
+	readOnlyARN = "arn:aws:sso:::permissionSet/ssoins-1/ps-ro"
+)
+
+// writeReport writes a report in which alice is assigned AdministratorAccess in prod and
+// the admins group is assigned ReadOnlyAccess in dev. Alice is a member of the admins group,
+// and so is bob if bobIsAdmin is set.
+func writeReport(t *testing.T, path string, bobIsAdmin bool) {
+	t.Helper()
+	resources := []msg.Resource{
+		reporttest.User("u-alice", "Alice", "alice@example.com"),
+		reporttest.User("u-bob", "Bob", "bob@example.com"),
+		reporttest.User("u-carol", "Carol", "carol@example.com"),
+		reporttest.Group("g-admins", "admins"),
+		reporttest.Account("111111111111", "prod", map[string]string{"environment": "production"}),
+		reporttest.Account("222222222222", "dev", nil),
+		reporttest.PermissionSet(adminARN, "AdministratorAccess", "arn:aws:iam::aws:policy/AdministratorAccess"),
+		reporttest.PermissionSet(readOnlyARN, "ReadOnlyAccess", "arn:aws:iam::aws:policy/ReadOnlyAccess"),
+		reporttest.UserAssignment("aa-1", "111111111111", adminARN, "u-alice"),
+		reporttest.GroupAssignment("aa-2", "222222222222", readOnlyARN, "g-admins"),
+		reporttest.Membership("gm-1", "u-alice", "g-admins"),
+	}
+	if bobIsAdmin {
+		resources = append(resources, reporttest.Membership("gm-2", "u-bob", "g-admins"))
+	}
+	reporttest.Write(t, path, reporttest.Default(resources...))
+}
+
+// testServer serves a report, with a snapshot named before in which bob isn't in the admins group.
+func testServer(t *testing.T) *httptest.Server {
+	t.Helper()
+	dir := t.TempDir()
+	snapshots := filepath.Join(dir, "snapshots")
+	reportPath := filepath.Join(dir, "report.db")
+	writeReport(t, reportPath, true)
+	if err := os.Mkdir(snapshots, 0755); err != nil {
+		t.Fatal(err)
+	}
+	writeReport(t, filepath.Join(snapshots, "before.db"), false)
+
+	db, err := report.OpenReadOnly(reportPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { db.Close() })
+
+	g, _, err := accessgraph.FromReport(db)
+	if err != nil {
+		t.Fatal(err)
+	}
+	scorer, err := risk.Load(db, risk.Options{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	s, err := New(db, g, Options{Model: accessgraph.AWSSSO, Scorer: scorer, SnapshotsDir: snapshots})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	ts := httptest.NewServer(s.Routes())
+	t.Cleanup(ts.Close)
+	return ts
+}
+
+// get requests a path, checking the status code and decoding the body into v if it's set.
+func get(t *testing.T, ts *httptest.Server, path string, status int, v any) {
+	t.Helper()
+	res, err := http.Get(ts.URL + path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer res.Body.Close()
+	if res.StatusCode != status {
+		var e Error
+		_ = json.NewDecoder(res.Body).Decode(&e)
+		t.Fatalf("GET %s: got status %d (%s), want %d", path, res.StatusCode, e.Error, status)
+	}
+	if v != nil {
+		if err := json.NewDecoder(res.Body).Decode(v); err != nil {
+			t.Fatalf("GET %s: decoding response: %s", path, err)
+		}
+	}
+}
+
+func TestBadRequests(t *testing.T) {
+	ts := testServer(t)
+
+	tests := []struct {
+		name string
+		path string
+	}{
+		{name: "zero limit", path: "/api/users?limit=0"},
+		{name: "limit over the maximum", path: "/api/users?limit=1001"},
+		{name: "limit not a number", path: "/api/users?limit=ten"},
+		{name: "negative offset", path: "/api/users?offset=-1"},
+		{name: "offset not a number", path: "/api/users?offset=two"},
+		{name: "unknown filter", path: "/api/users?colour=blue"},
+		{name: "access pagination", path: "/api/users/u-alice/effective-access?limit=0"},
+		{name: "diff without from", path: "/api/diff"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var e Error
+			get(t, ts, tt.path, http.StatusBadRequest, &e)
+			if e.Error == "" {
+				t.Error("the response doesn't explain the error")
+			}
+		})
+	}
+}
+
+func TestListResources(t *testing.T) {
+	ts := testServer(t)
+
+	var page Page[Resource]
+	get(t, ts, "/api/users?limit=2&offset=2", http.StatusOK, &page)
+	if page.Total != 3 || page.Limit != 2 || page.Offset != 2 || len(page.Items) != 1 || page.Items[0]["id"] != "u-carol" {
+		t.Errorf("got page %+v, want the last of 3 users", page)
+	}
+
+	get(t, ts, "/api/users?offset=10", http.StatusOK, &page)
+	if page.Total != 3 || len(page.Items) != 0 {
+		t.Errorf("got page %+v, want no users past the end", page)
+	}
+
+	get(t, ts, "/api/users?name=bob", http.StatusOK, &page)
+	if page.Total != 1 || page.Items[0]["id"] != "u-bob" {
+		t.Errorf("got page %+v, want bob", page)
+	}
+}
+
+func TestUserEffectiveAccess(t *testing.T) {
+	ts := testServer(t)
+
+	var page Page[Access]
+	get(t, ts, "/api/users/u-alice/effective-access", http.StatusOK, &page)
+	if page.Total != 2 {
+		t.Fatalf("got %d paths, want alice's direct and group assignments: %+v", page.Total, page.Items)
+	}
+
+	get(t, ts, "/api/users/u-alice/effective-access?account=dev", http.StatusOK, &page)
+	if page.Total != 1 {
+		t.Fatalf("got %d paths, want alice's group assignment in dev: %+v", page.Total, page.Items)
+	}
+	a := page.Items[0]
+	if a.AccountID != "222222222222" || a.PermissionSetID != readOnlyARN || a.PermissionSetName != "ReadOnlyAccess" ||
+		a.PathType != accessgraph.PathGroup || a.AssignmentID != "aa-2" {
+		t.Errorf("got %+v, want ReadOnlyAccess in dev through the admins group", a)
+	}
+
+	get(t, ts, "/api/users/u-carol/effective-access", http.StatusOK, &page)
+	if page.Total != 0 || page.Items == nil {
+		t.Errorf("got %+v, want an empty list for carol, who has no assignments", page)
+	}
+
+	get(t, ts, "/api/users/u-nobody/effective-access", http.StatusNotFound, nil)
+}
+
+func TestAccountPrincipals(t *testing.T) {
+	ts := testServer(t)
+
+	var page Page[Access]
+	get(t, ts, "/api/accounts/222222222222/principals", http.StatusOK, &page)
+	users := map[string]bool{}
+	for _, a := range page.Items {
+		users[a.UserID] = true
+		if a.PathType != accessgraph.PathGroup {
+			t.Errorf("got path type %s for %s, want group", a.PathType, a.UserID)
+		}
+	}
+	if page.Total != 2 || !users["u-alice"] || !users["u-bob"] {
+		t.Errorf("got %+v, want alice and bob through the admins group", page.Items)
+	}
+
+	get(t, ts, "/api/accounts/111111111111/principals?pathType=group", http.StatusOK, &page)
+	if page.Total != 0 {
+		t.Errorf("got %+v, want no group access in prod", page.Items)
+	}
+
+	get(t, ts, "/api/accounts/333333333333/principals", http.StatusNotFound, nil)
+}
+
+func TestDiff(t *testing.T) {
+	ts := testServer(t)
+
+	var d snapshot.Diff
+	get(t, ts, "/api/diff?from=before", http.StatusOK, &d)
+	if len(d.Added) != 1 || d.Added[0].ID != "gm-2" {
+		t.Errorf("got added resources %+v, want bob's group membership", d.Added)
+	}
+	if len(d.Removed) != 0 || len(d.AccessLost) != 0 {
+		t.Errorf("got removed resources %+v and lost access %+v, want none", d.Removed, d.AccessLost)
+	}
+	want := snapshot.AccessChange{UserID: "u-bob", AccountID: "222222222222", PermissionSetID: readOnlyARN}
+	if len(d.AccessGained) != 1 || d.AccessGained[0] != want {
+		t.Errorf("got gained access %+v, want %+v", d.AccessGained, want)
+	}
+
+	get(t, ts, "/api/diff?from=current&to=before", http.StatusOK, &d)
+	if len(d.AccessLost) != 1 || d.AccessLost[0] != want {
+		t.Errorf("got lost access %+v, want %+v", d.AccessLost, want)
+	}
+
+	get(t, ts, "/api/diff?from=missing", http.StatusNotFound, nil)
+	get(t, ts, "/api/diff?from=../report", http.StatusNotFound, nil)
+}
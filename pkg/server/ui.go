@@ -0,0 +1,21 @@
+package server
+
+import (
+	"embed"
+	"io/fs"
+	"net/http"
+)
+
+// ui is the web UI, a single page app which uses the API.
+//
+//go:embed ui
+var ui embed.FS
+
+func uiHandler() http.Handler {
+	sub, err := fs.Sub(ui, "ui")
+	if err != nil {
+		// the embedded directory always exists
+		panic(err)
+	}
+	return http.FileServer(http.FS(sub))
+}
@@ -0,0 +1,643 @@
+// The Access Inspector web UI. It uses the JSON API served alongside it and has no
+// external dependencies, so that it works offline.
+(function () {
+  "use strict";
+
+  var app = document.getElementById("app");
+
+  // resource kinds, keyed by the path segment used in the API and in page URLs
+  var kinds = {
+    users: { title: "Users", type: "User" },
+    groups: { title: "Groups", type: "Group" },
+    accounts: { title: "Accounts", type: "Account" },
+    "permission-sets": { title: "Permission sets", type: "PermissionSet" },
+  };
+
+  var typeColours = {
+    User: "#0969da",
+    Group: "#9a6700",
+    Account: "#1a7f37",
+    PermissionSet: "#8250df",
+    AccountAssignment: "#57606a",
+    CommonFateGrant: "#bf3989",
+  };
+
+  function kindForType(type) {
+    for (var k in kinds) {
+      if (kinds[k].type === type) return k;
+    }
+    return null;
+  }
+
+  // el creates an element with attributes and children
+  function el(tag, attrs) {
+    var e = document.createElement(tag);
+    for (var k in attrs || {}) {
+      if (k === "text") e.textContent = attrs[k];
+      else if (k.indexOf("on") === 0) e.addEventListener(k.slice(2), attrs[k]);
+      else e.setAttribute(k, attrs[k]);
+    }
+    for (var i = 2; i < arguments.length; i++) {
+      var c = arguments[i];
+      if (c === null || c === undefined) continue;
+      e.appendChild(typeof c === "string" ? document.createTextNode(c) : c);
+    }
+    return e;
+  }
+
+  function api(path) {
+    return fetch("api" + path).then(function (res) {
+      return res.json().then(function (body) {
+        if (!res.ok) throw new Error(body.error || res.statusText);
+        return body;
+      });
+    });
+  }
+
+  // fetchAll follows pagination to load every item of a list
+  function fetchAll(path) {
+    var sep = path.indexOf("?") === -1 ? "?" : "&";
+    var items = [];
+    function next(offset) {
+      return api(path + sep + "limit=1000&offset=" + offset).then(function (page) {
+        items = items.concat(page.items);
+        if (items.length < page.total && page.items.length > 0) return next(items.length);
+        return items;
+      });
+    }
+    return next(0);
+  }
+
+  function showError(err) {
+    app.appendChild(el("p", { class: "error", text: err.message }));
+  }
+
+  function resourceLink(type, id, name) {
+    var kind = kindForType(type);
+    var label = name || id;
+    if (!kind) return document.createTextNode(label);
+    return el("a", { href: "#/" + kind + "/" + encodeURIComponent(id) }, label);
+  }
+
+  function table(columns, rows) {
+    var thead = el("thead", null, el("tr", null));
+    columns.forEach(function (c) {
+      thead.firstChild.appendChild(el("th", { text: c }));
+    });
+    var tbody = el("tbody");
+    rows.forEach(function (cells) {
+      var tr = el("tr");
+      cells.forEach(function (c) {
+        tr.appendChild(c instanceof Node ? el("td", null, c) : el("td", { text: c === undefined ? "" : String(c) }));
+      });
+      tbody.appendChild(tr);
+    });
+    return el("table", null, thead, tbody);
+  }
+
+  // the remediation plan is kept in local storage, so that it survives reloads
+  var plan = {
+    load: function () {
+      try {
+        return JSON.parse(localStorage.getItem("remediationPlan")) || [];
+      } catch (e) {
+        return [];
+      }
+    },
+    save: function (items) {
+      localStorage.setItem("remediationPlan", JSON.stringify(items));
+      document.getElementById("plan-count").textContent = items.length || "";
+    },
+    key: function (r) {
+      return [r.instance || "", r.principalType, r.principalId, r.accountId, r.permissionSetArn].join("+");
+    },
+    has: function (r) {
+      var k = plan.key(r);
+      return plan.load().some(function (x) {
+        return plan.key(x) === k;
+      });
+    },
+    toggle: function (r) {
+      var k = plan.key(r);
+      var items = plan.load();
+      var without = items.filter(function (x) {
+        return plan.key(x) !== k;
+      });
+      if (without.length === items.length) without.push(r);
+      plan.save(without);
+    },
+  };
+
+  function planButton(removal) {
+    if (!removal) return el("span", { class: "muted", text: "managed by Common Fate" });
+    var b = el("button");
+    function update() {
+      b.textContent = plan.has(removal) ? "Remove from plan" : "Add to plan";
+      b.className = plan.has(removal) ? "danger" : "";
+    }
+    b.addEventListener("click", function () {
+      plan.toggle(removal);
+      update();
+    });
+    update();
+    return b;
+  }
+
+  // removalForAccess returns the account assignment to remove to take away an access path,
+  // which is made to the principal just before the assignment on the path
+  function removalForAccess(a) {
+    if (a.pathType === "jit" || a.path.length < 2) return null;
+    var principal = a.path[a.path.length - 2];
+    var principalType = principal.type === "User" ? "USER" : principal.type === "Group" ? "GROUP" : null;
+    if (!principalType) return null;
+    return {
+      principalType: principalType,
+      principalId: principal.id,
+      principalName: principal.name || principal.id,
+      accountId: a.accountId,
+      accountName: a.accountName || a.accountId,
+      permissionSetArn: a.permissionSetId,
+      permissionSetName: a.permissionSetName || a.permissionSetId,
+      instance: a.instance,
+    };
+  }
+
+  function pathCell(a) {
+    var span = el("span");
+    a.path.forEach(function (hop, i) {
+      if (i > 0) span.appendChild(document.createTextNode(" → "));
+      span.appendChild(resourceLink(hop.type, hop.id, hop.type + " " + (hop.name || hop.id)));
+    });
+    return span;
+  }
+
+  function accessTable(items, showUser) {
+    var columns = ["Account", "Permission set", "Path type", "Path", "Plan"];
+    if (showUser) columns.unshift("User");
+    return table(
+      columns,
+      items.map(function (a) {
+        var row = [
+          resourceLink("Account", a.accountId, a.accountName),
+          resourceLink("PermissionSet", a.permissionSetId, a.permissionSetName),
+          el("span", { class: "path-" + a.pathType, text: a.pathType }),
+          pathCell(a),
+          planButton(removalForAccess(a)),
+        ];
+        if (showUser) row.unshift(resourceLink("User", a.userId, a.userName));
+        return row;
+      })
+    );
+  }
+
+  // graphView draws an interactive force-directed layout of the graph around a resource
+  function graphView(from) {
+    var container = el("div");
+    var collapse = el("input", { type: "checkbox", checked: "checked" });
+    var depth = el("select");
+    [1, 2, 3, 4, 5, 6].forEach(function (d) {
+      var o = el("option", { value: d, text: "depth " + d });
+      if (d === 4) o.selected = true;
+      depth.appendChild(o);
+    });
+    var status = el("span", { class: "muted" });
+    var svg = document.createElementNS("http://www.w3.org/2000/svg", "svg");
+    svg.setAttribute("class", "graph");
+
+    var legend = el("div", { class: "legend" });
+    Object.keys(typeColours).forEach(function (t) {
+      legend.appendChild(el("span", null, el("i", { class: "swatch", style: "background:" + typeColours[t] }), t));
+    });
+
+    container.appendChild(el("div", { class: "filters" }, depth, el("label", null, collapse, " collapse group chains"), status));
+    container.appendChild(svg);
+    container.appendChild(legend);
+
+    var stop = null;
+    function load() {
+      if (stop) stop();
+      status.textContent = "loading...";
+      var q = "?from=" + encodeURIComponent(from) + "&depth=" + depth.value + (collapse.checked ? "&collapseGroups=true" : "");
+      api("/graph" + q)
+        .then(function (g) {
+          status.textContent = g.nodes.length + " resources, " + g.edges.length + " relations. Drag to move, click to open.";
+          stop = layout(svg, g, from);
+        })
+        .catch(function (err) {
+          status.textContent = err.message;
+        });
+    }
+    depth.addEventListener("change", load);
+    collapse.addEventListener("change", load);
+    load();
+    return container;
+  }
+
+  function svgEl(tag, attrs) {
+    var e = document.createElementNS("http://www.w3.org/2000/svg", tag);
+    for (var k in attrs) e.setAttribute(k, attrs[k]);
+    return e;
+  }
+
+  // layout runs a simple force simulation and draws the graph, returning a function which stops it
+  function layout(svg, g, start) {
+    while (svg.firstChild) svg.removeChild(svg.firstChild);
+    var width = svg.clientWidth || 800;
+    var height = svg.clientHeight || 560;
+
+    var index = {};
+    var nodes = g.nodes.map(function (n, i) {
+      index[n.id] = i;
+      var angle = (2 * Math.PI * i) / g.nodes.length;
+      return { n: n, x: width / 2 + Math.cos(angle) * 150, y: height / 2 + Math.sin(angle) * 150, vx: 0, vy: 0, fixed: false };
+    });
+    var edges = g.edges
+      .filter(function (e) {
+        return e.source in index && e.target in index;
+      })
+      .map(function (e) {
+        return { s: nodes[index[e.source]], t: nodes[index[e.target]], label: e.label };
+      });
+
+    var lines = edges.map(function (e) {
+      var line = svgEl("line", {});
+      line.appendChild(svgEl("title", {})).textContent = e.label;
+      svg.appendChild(line);
+      return line;
+    });
+    var dragging = null;
+    var moved = false;
+    var circles = nodes.map(function (d) {
+      var c = svgEl("circle", { r: 7, fill: typeColours[d.n.type] || "#57606a" });
+      if (d.n.id === start || d.n.type + "/" + d.n.name === start) c.setAttribute("class", "start");
+      c.appendChild(svgEl("title", {})).textContent = d.n.type + " " + d.n.resourceId + (d.n.name ? " (" + d.n.name + ")" : "");
+      c.addEventListener("mousedown", function (ev) {
+        dragging = d;
+        moved = false;
+        d.fixed = true;
+        ev.preventDefault();
+      });
+      c.addEventListener("click", function () {
+        var kind = kindForType(d.n.type);
+        if (!moved && kind) location.hash = "#/" + kind + "/" + encodeURIComponent(d.n.resourceId);
+      });
+      svg.appendChild(c);
+      return c;
+    });
+    var labels = nodes.map(function (d) {
+      var t = svgEl("text", { dx: 10, dy: 4 });
+      t.textContent = d.n.name || d.n.resourceId;
+      svg.appendChild(t);
+      return t;
+    });
+
+    function onMove(ev) {
+      if (!dragging) return;
+      var rect = svg.getBoundingClientRect();
+      dragging.x = ev.clientX - rect.left;
+      dragging.y = ev.clientY - rect.top;
+      moved = true;
+      alpha = Math.max(alpha, 0.3);
+    }
+    function onUp() {
+      dragging = null;
+    }
+    window.addEventListener("mousemove", onMove);
+    window.addEventListener("mouseup", onUp);
+
+    var alpha = 1;
+    var running = true;
+    function tick() {
+      if (!running) return;
+      if (alpha > 0.01) {
+        // repulsion between every pair of nodes
+        for (var i = 0; i < nodes.length; i++) {
+          for (var j = i + 1; j < nodes.length; j++) {
+            var a = nodes[i], b = nodes[j];
+            var dx = b.x - a.x, dy = b.y - a.y;
+            var dist2 = dx * dx + dy * dy || 1;
+            var f = (900 * alpha) / dist2;
+            a.vx -= dx * f; a.vy -= dy * f;
+            b.vx += dx * f; b.vy += dy * f;
+          }
+        }
+        // springs along edges
+        edges.forEach(function (e) {
+          var dx = e.t.x - e.s.x, dy = e.t.y - e.s.y;
+          var dist = Math.sqrt(dx * dx + dy * dy) || 1;
+          var f = ((dist - 80) / dist) * 0.05 * alpha;
+          e.s.vx += dx * f; e.s.vy += dy * f;
+          e.t.vx -= dx * f; e.t.vy -= dy * f;
+        });
+        nodes.forEach(function (d) {
+          // pull towards the centre, and keep inside the view
+          d.vx += (width / 2 - d.x) * 0.005 * alpha;
+          d.vy += (height / 2 - d.y) * 0.005 * alpha;
+          if (!d.fixed) {
+            d.x = Math.min(width - 10, Math.max(10, d.x + d.vx));
+            d.y = Math.min(height - 10, Math.max(10, d.y + d.vy));
+          }
+          d.vx *= 0.6;
+          d.vy *= 0.6;
+        });
+        alpha *= 0.99;
+      }
+      edges.forEach(function (e, i) {
+        lines[i].setAttribute("x1", e.s.x); lines[i].setAttribute("y1", e.s.y);
+        lines[i].setAttribute("x2", e.t.x); lines[i].setAttribute("y2", e.t.y);
+      });
+      nodes.forEach(function (d, i) {
+        circles[i].setAttribute("cx", d.x); circles[i].setAttribute("cy", d.y);
+        labels[i].setAttribute("x", d.x); labels[i].setAttribute("y", d.y);
+      });
+      requestAnimationFrame(tick);
+    }
+    requestAnimationFrame(tick);
+
+    return function () {
+      running = false;
+      window.removeEventListener("mousemove", onMove);
+      window.removeEventListener("mouseup", onUp);
+    };
+  }
+
+  // pages
+
+  function searchPage(params) {
+    var input = el("input", { class: "search", placeholder: "Search users, groups, accounts and permission sets", value: params.get("q") || "" });
+    var results = el("div");
+    app.appendChild(el("h2", { text: "Search" }));
+    app.appendChild(el("div", { class: "filters" }, input));
+    app.appendChild(results);
+
+    var timer = null;
+    function search() {
+      var q = input.value.trim();
+      history.replaceState(null, "", "#/" + (q ? "?q=" + encodeURIComponent(q) : ""));
+      Promise.all(
+        Object.keys(kinds).map(function (k) {
+          return api("/" + k + "?limit=50&q=" + encodeURIComponent(q)).then(function (page) {
+            return { kind: k, page: page };
+          });
+        })
+      )
+        .then(function (all) {
+          results.textContent = "";
+          all.forEach(function (r) {
+            var k = kinds[r.kind];
+            results.appendChild(el("h3", null, k.title + " ", el("small", { class: "muted", text: "(" + r.page.total + ")" })));
+            if (r.page.items.length === 0) {
+              results.appendChild(el("p", { class: "muted", text: "No matches" }));
+              return;
+            }
+            results.appendChild(
+              table(
+                ["Name", "ID", "Email"],
+                r.page.items.map(function (item) {
+                  return [resourceLink(k.type, item.id, item.name || item.id), item.id, item.email || ""];
+                })
+              )
+            );
+            if (r.page.total > r.page.items.length) {
+              results.appendChild(el("p", { class: "muted", text: "Showing the first " + r.page.items.length + " matches" }));
+            }
+          });
+        })
+        .catch(function (err) {
+          results.textContent = "";
+          results.appendChild(el("p", { class: "error", text: err.message }));
+        });
+    }
+    input.addEventListener("input", function () {
+      clearTimeout(timer);
+      timer = setTimeout(search, 200);
+    });
+    search();
+    input.focus();
+  }
+
+  function details(resource) {
+    var dl = el("dl");
+    Object.keys(resource)
+      .sort()
+      .forEach(function (k) {
+        dl.appendChild(el("dt", { text: k }));
+        dl.appendChild(el("dd", { text: resource[k] }));
+      });
+    return dl;
+  }
+
+  function resourcePage(kind, id) {
+    var k = kinds[kind];
+    api("/" + kind + "/" + (kind === "permission-sets" ? id : encodeURIComponent(id)))
+      .then(function (resource) {
+        app.appendChild(el("h2", null, (resource.name || resource.id) + " ", el("small", { text: k.type + " " + resource.id })));
+        var left = el("div", null, el("h3", { text: "Details" }), details(resource));
+        var right = el("div", null, el("h3", { text: "Entitlement graph" }), graphView(k.type + "/" + resource.id));
+        app.appendChild(el("div", { class: "columns" }, left, right));
+
+        var accessSection = el("div");
+        app.appendChild(accessSection);
+        if (kind === "users") {
+          accessSection.appendChild(el("h3", { text: "Effective access" }));
+          return fetchAll("/users/" + encodeURIComponent(id) + "/effective-access").then(function (items) {
+            accessSection.appendChild(items.length ? accessTable(items, false) : el("p", { class: "muted", text: "No access" }));
+          });
+        }
+        if (kind === "accounts") {
+          accessSection.appendChild(el("h3", { text: "Who can reach this account" }));
+          return fetchAll("/accounts/" + encodeURIComponent(id) + "/principals").then(function (items) {
+            accessSection.appendChild(items.length ? accessTable(items, true) : el("p", { class: "muted", text: "Nobody has access" }));
+          });
+        }
+      })
+      .catch(showError);
+  }
+
+  var findingStatuses = ["", "remove", "skip-group", "skip-common-fate", "skip-recently-used"];
+
+  function findingsPage(params) {
+    app.appendChild(el("h2", { text: "Findings" }));
+    var status = el("select");
+    findingStatuses.forEach(function (s) {
+      var o = el("option", { value: s, text: s || "any status" });
+      if (s === (params.has("status") ? params.get("status") : "remove")) o.selected = true;
+      status.appendChild(o);
+    });
+    var sensitivity = el("select");
+    ["", "low", "medium", "high", "critical"].forEach(function (s) {
+      var o = el("option", { value: s, text: s || "any sensitivity" });
+      if (s === (params.get("sensitivity") || "")) o.selected = true;
+      sensitivity.appendChild(o);
+    });
+    var account = el("input", { placeholder: "account ID or name", value: params.get("account") || "" });
+    var user = el("input", { placeholder: "user ID or email", value: params.get("user") || "" });
+    var minRisk = el("input", { type: "number", min: 0, placeholder: "minimum risk", value: params.get("minRisk") || "" });
+    var addAll = el("button", { text: "Add all removable to plan" });
+    var results = el("div");
+    app.appendChild(el("div", { class: "filters" }, status, sensitivity, account, user, minRisk, addAll));
+    app.appendChild(results);
+
+    var current = [];
+    function removalForFinding(f) {
+      if (f.status !== "remove") return null;
+      return {
+        principalType: "USER",
+        principalId: f.userId,
+        principalName: f.userEmail,
+        accountId: f.accountId,
+        accountName: f.accountName,
+        permissionSetArn: f.permissionSetArn,
+        permissionSetName: f.permissionSetName,
+        instance: f.instance,
+      };
+    }
+
+    function load() {
+      var q = new URLSearchParams();
+      if (status.value) q.set("status", status.value);
+      if (sensitivity.value) q.set("sensitivity", sensitivity.value);
+      if (account.value) q.set("account", account.value);
+      if (user.value) q.set("user", user.value);
+      if (minRisk.value) q.set("minRisk", minRisk.value);
+      history.replaceState(null, "", "#/findings?" + q.toString());
+      fetchAll("/findings?" + q.toString())
+        .then(function (items) {
+          current = items;
+          results.textContent = "";
+          results.appendChild(el("p", { class: "muted", text: items.length + " findings" }));
+          results.appendChild(
+            table(
+              ["Risk", "Status", "User", "Account", "Permission set", "Group", "Last used", "Plan"],
+              items.map(function (f) {
+                var removal = removalForFinding(f);
+                return [
+                  el("span", { title: (f.risk.permissionSet.reasons || []).join("\n"), text: f.risk.value + " (" + f.risk.sensitivity + ")" }),
+                  f.status,
+                  resourceLink("User", f.userId, f.userEmail),
+                  resourceLink("Account", f.accountId, f.accountName),
+                  resourceLink("PermissionSet", f.permissionSetArn, f.permissionSetName),
+                  f.groupId ? resourceLink("Group", f.groupId, f.groupName) : "",
+                  f.lastUsed ? new Date(f.lastUsed).toLocaleString() : "",
+                  removal ? planButton(removal) : el("span", { class: "muted", text: "kept" }),
+                ];
+              })
+            )
+          );
+        })
+        .catch(function (err) {
+          results.textContent = "";
+          results.appendChild(el("p", { class: "error", text: err.message }));
+        });
+    }
+
+    addAll.addEventListener("click", function () {
+      current.forEach(function (f) {
+        var r = removalForFinding(f);
+        if (r && !plan.has(r)) plan.toggle(r);
+      });
+      load();
+    });
+    [status, sensitivity].forEach(function (s) {
+      s.addEventListener("change", load);
+    });
+    [account, user, minRisk].forEach(function (i) {
+      var timer = null;
+      i.addEventListener("input", function () {
+        clearTimeout(timer);
+        timer = setTimeout(load, 300);
+      });
+    });
+    load();
+  }
+
+  function planPage() {
+    var items = plan.load();
+    app.appendChild(el("h2", null, "Remediation plan ", el("small", { text: items.length + " account assignments to remove" })));
+    if (items.length === 0) {
+      app.appendChild(el("p", { class: "muted", text: "Add access to the plan from a user or account page, or from the findings list." }));
+      return;
+    }
+    var download = el("button", { class: "primary", text: "Download script" });
+    var clear = el("button", { class: "danger", text: "Clear plan" });
+    var message = el("span", { class: "muted" });
+    app.appendChild(el("div", { class: "filters" }, download, clear, message));
+    app.appendChild(
+      table(
+        ["Principal", "Account", "Permission set", ""],
+        items.map(function (r) {
+          return [
+            resourceLink(r.principalType === "USER" ? "User" : "Group", r.principalId, r.principalType.toLowerCase() + " " + r.principalName),
+            resourceLink("Account", r.accountId, r.accountName),
+            resourceLink("PermissionSet", r.permissionSetArn, r.permissionSetName),
+            el("button", {
+              text: "Remove",
+              onclick: function () {
+                plan.toggle(r);
+                route();
+              },
+            }),
+          ];
+        })
+      )
+    );
+    app.appendChild(el("p", { class: "muted", text: "Removing a group's assignment removes the access for every member of the group. Review the script before running it." }));
+
+    clear.addEventListener("click", function () {
+      if (confirm("Remove every item from the plan?")) {
+        plan.save([]);
+        route();
+      }
+    });
+    download.addEventListener("click", function () {
+      var body = items.map(function (r) {
+        return { principalType: r.principalType, principalId: r.principalId, accountId: r.accountId, permissionSetArn: r.permissionSetArn, instance: r.instance };
+      });
+      fetch("api/remediation-script", { method: "POST", headers: { "Content-Type": "application/json" }, body: JSON.stringify(body) })
+        .then(function (res) {
+          if (!res.ok) {
+            return res.json().then(function (b) {
+              throw new Error(b.error);
+            });
+          }
+          return res.blob();
+        })
+        .then(function (blob) {
+          var a = el("a", { href: URL.createObjectURL(blob), download: "remediation.sh" });
+          document.body.appendChild(a);
+          a.click();
+          a.remove();
+          message.textContent = "downloaded remediation.sh";
+        })
+        .catch(function (err) {
+          message.textContent = err.message;
+        });
+    });
+  }
+
+  // route renders the page for the URL fragment: #/, #/findings, #/plan or #/<kind>/<id>
+  function route() {
+    app.textContent = "";
+    var hash = location.hash.replace(/^#\/?/, "");
+    var q = hash.indexOf("?");
+    var params = new URLSearchParams(q === -1 ? "" : hash.slice(q + 1));
+    var path = q === -1 ? hash : hash.slice(0, q);
+    var slash = path.indexOf("/");
+    var first = slash === -1 ? path : path.slice(0, slash);
+    var rest = slash === -1 ? "" : decodeURIComponent(path.slice(slash + 1));
+
+    document.querySelectorAll("[data-nav]").forEach(function (a) {
+      var nav = a.getAttribute("data-nav");
+      a.classList.toggle("active", nav === first || (nav === "search" && (first === "" || first in kinds)));
+    });
+
+    if (first === "findings") return findingsPage(params);
+    if (first === "plan") return planPage();
+    if (first in kinds && rest) return resourcePage(first, rest);
+    return searchPage(params);
+  }
+
+  window.addEventListener("hashchange", route);
+  plan.save(plan.load());
+  route();
+})();
@@ -0,0 +1,240 @@
+// Package snapshot compares two reports, such as scans of the same provider taken on different days.
+package snapshot
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"sort"
+	"strings"
+
+	"github.com/common-fate/access-inspector/pkg/accessgraph"
+	"github.com/common-fate/access-inspector/pkg/report"
+	"github.com/common-fate/provider-registry-sdk-go/pkg/msg"
+	"github.com/jmoiron/sqlx"
+)
+
+// ResourceChange is a resource which was added, removed or changed between two reports.
+type ResourceChange struct {
+	Type string `json:"type"`
+	ID   string `json:"id"`
+	Name string `json:"name,omitempty"`
+	// Instance is the instance holding the resource. It's empty for the default instance.
+	Instance string `json:"instance,omitempty"`
+	// Fields are the names of the changed fields, for changed resources.
+	Fields []string `json:"fields,omitempty"`
+}
+
+func resourceChange(r msg.Resource, fields []string) ResourceChange {
+	return ResourceChange{Type: r.Type, ID: r.ID, Name: r.Name, Instance: accessgraph.Instance(r), Fields: fields}
+}
+
+// AccessChange is access to a permission set in an account which a user gained or lost.
+type AccessChange struct {
+	UserID          string `json:"userId"`
+	AccountID       string `json:"accountId"`
+	PermissionSetID string `json:"permissionSetId"`
+	// Instance is the instance holding the access. It's empty for the default instance.
+	Instance string `json:"instance,omitempty"`
+}
+
+// Diff is the difference between two reports.
+type Diff struct {
+	Added        []ResourceChange `json:"added"`
+	Removed      []ResourceChange `json:"removed"`
+	Changed      []ResourceChange `json:"changed"`
+	AccessGained []AccessChange   `json:"accessGained"`
+	AccessLost   []AccessChange   `json:"accessLost"`
+	// EntitlementsGained and EntitlementsLost compare the entitlement tables, for any provider.
+	// They are only set if both reports have an entitlement table.
+	EntitlementsGained []report.Entitlement `json:"entitlementsGained,omitempty"`
+	EntitlementsLost   []report.Entitlement `json:"entitlementsLost,omitempty"`
+}
+
+// Compare finds the resources and effective access which differ between two reports.
+func Compare(from, to *sqlx.DB, m accessgraph.Model) (Diff, error) {
+	d := Diff{
+		Added:        []ResourceChange{},
+		Removed:      []ResourceChange{},
+		Changed:      []ResourceChange{},
+		AccessGained: []AccessChange{},
+		AccessLost:   []AccessChange{},
+	}
+
+	before, err := resources(from)
+	if err != nil {
+		return d, err
+	}
+	after, err := resources(to)
+	if err != nil {
+		return d, err
+	}
+
+	for _, k := range sortedKeys(after) {
+		a := after[k]
+		b, ok := before[k]
+		if !ok {
+			d.Added = append(d.Added, resourceChange(a, nil))
+			continue
+		}
+		if fields := changedFields(b, a); len(fields) > 0 {
+			d.Changed = append(d.Changed, resourceChange(a, fields))
+		}
+	}
+	for _, k := range sortedKeys(before) {
+		if _, ok := after[k]; !ok {
+			b := before[k]
+			d.Removed = append(d.Removed, resourceChange(b, nil))
+		}
+	}
+
+	accessBefore, err := effectiveAccess(from, m)
+	if err != nil {
+		return d, err
+	}
+	accessAfter, err := effectiveAccess(to, m)
+	if err != nil {
+		return d, err
+	}
+	for _, k := range sortedKeys(accessAfter) {
+		if _, ok := accessBefore[k]; !ok {
+			d.AccessGained = append(d.AccessGained, accessAfter[k])
+		}
+	}
+	for _, k := range sortedKeys(accessBefore) {
+		if _, ok := accessAfter[k]; !ok {
+			d.AccessLost = append(d.AccessLost, accessBefore[k])
+		}
+	}
+
+	entitlementsBefore, err := entitlements(from)
+	if err != nil {
+		return d, err
+	}
+	entitlementsAfter, err := entitlements(to)
+	if err != nil {
+		return d, err
+	}
+	if entitlementsBefore == nil || entitlementsAfter == nil {
+		// reports scanned before the entitlement table was added are compared by their effective access only
+		return d, nil
+	}
+	d.EntitlementsGained = []report.Entitlement{}
+	d.EntitlementsLost = []report.Entitlement{}
+	for _, k := range sortedKeys(entitlementsAfter) {
+		if _, ok := entitlementsBefore[k]; !ok {
+			d.EntitlementsGained = append(d.EntitlementsGained, entitlementsAfter[k])
+		}
+	}
+	for _, k := range sortedKeys(entitlementsBefore) {
+		if _, ok := entitlementsAfter[k]; !ok {
+			d.EntitlementsLost = append(d.EntitlementsLost, entitlementsBefore[k])
+		}
+	}
+	return d, nil
+}
+
+// entitlements returns the rows of a report's entitlement table, keyed by Entitlement.Key().
+// If the report doesn't have an entitlement table, it returns nil.
+func entitlements(db *sqlx.DB) (map[string]report.Entitlement, error) {
+	list, err := report.Entitlements(db)
+	if err != nil || list == nil {
+		return nil, err
+	}
+	byKey := map[string]report.Entitlement{}
+	for _, e := range list {
+		byKey[e.Key()] = e
+	}
+	return byKey, nil
+}
+
+func resources(db *sqlx.DB) (map[string]msg.Resource, error) {
+	describe, err := report.Describe(db)
+	if err != nil {
+		return nil, err
+	}
+	list, err := report.Resources(db, describe)
+	if err != nil {
+		return nil, err
+	}
+	byKey := map[string]msg.Resource{}
+	for _, r := range list {
+		byKey[accessgraph.Hash(r)] = r
+	}
+	return byKey, nil
+}
+
+func effectiveAccess(db *sqlx.DB, m accessgraph.Model) (map[string]AccessChange, error) {
+	g, _, err := accessgraph.FromReport(db)
+	if err != nil {
+		return nil, err
+	}
+	access, err := accessgraph.AllEffectiveAccess(g, m)
+	if err != nil {
+		return nil, err
+	}
+	byKey := map[string]AccessChange{}
+	for _, a := range access {
+		byKey[a.Key()] = AccessChange{UserID: a.UserID, AccountID: a.AccountID, PermissionSetID: a.PermissionSetID, Instance: a.Instance}
+	}
+	return byKey, nil
+}
+
+// changedFields compares the name and data fields of a resource.
+func changedFields(before, after msg.Resource) []string {
+	var fields []string
+	if before.Name != after.Name {
+		fields = append(fields, "name")
+	}
+	keys := map[string]bool{}
+	for k := range before.Data {
+		keys[k] = true
+	}
+	for k := range after.Data {
+		keys[k] = true
+	}
+	for _, k := range sortedKeys(keys) {
+		if !reflect.DeepEqual(before.Data[k], after.Data[k]) {
+			fields = append(fields, k)
+		}
+	}
+	return fields
+}
+
+func sortedKeys[T any](m map[string]T) []string {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+// List returns the names of the report databases in a directory, without the .db extension.
+func List(dir string) ([]string, error) {
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return nil, err
+	}
+	var names []string
+	for _, e := range entries {
+		if !e.IsDir() && filepath.Ext(e.Name()) == ".db" {
+			names = append(names, strings.TrimSuffix(e.Name(), ".db"))
+		}
+	}
+	sort.Strings(names)
+	return names, nil
+}
+
+// Path returns the path of a named snapshot in a directory. The name must be a plain
+// file name, so that snapshots outside the directory can't be read.
+func Path(dir string, name string) (string, bool) {
+	if name == "" || name != filepath.Base(name) || strings.HasPrefix(name, ".") {
+		return "", false
+	}
+	path := filepath.Join(dir, name+".db")
+	if _, err := os.Stat(path); err != nil {
+		return "", false
+	}
+	return path, true
+}
@@ -0,0 +1,193 @@
+// Package toxic finds users whose combined effective access breaks separation of duties rules,
+// such as holding admin access in both the production and the audit log accounts.
+package toxic
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"sort"
+
+	"github.com/common-fate/access-inspector/pkg/accessgraph"
+	"github.com/common-fate/access-inspector/pkg/policy"
+	"github.com/pkg/errors"
+)
+
+// Match selects entitlements by account and permission set. Patterns may use IAM-style
+// wildcards ('*' and '?'), are case-insensitive, and are matched against both the ID and the
+// name. An empty pattern matches anything.
+type Match struct {
+	Account       string `json:"account,omitempty"`
+	PermissionSet string `json:"permissionSet,omitempty"`
+}
+
+func (m Match) String() string {
+	account, permissionSet := m.Account, m.PermissionSet
+	if account == "" {
+		account = "*"
+	}
+	if permissionSet == "" {
+		permissionSet = "*"
+	}
+	return fmt.Sprintf("%s in %s", permissionSet, account)
+}
+
+// Rule is a forbidden combination of entitlements. A user breaks the rule
+// if they have access matching every part of the combination, with a different
+// entitlement for each part.
+type Rule struct {
+	Name        string  `json:"name"`
+	Description string  `json:"description,omitempty"`
+	Combination []Match `json:"combination"`
+}
+
+// Rules is the format of a rules file.
+type Rules struct {
+	Rules []Rule `json:"rules"`
+}
+
+// LoadRules reads a JSON rules file.
+func LoadRules(path string) (Rules, error) {
+	var rules Rules
+	b, err := os.ReadFile(path)
+	if err != nil {
+		return rules, err
+	}
+	err = json.Unmarshal(b, &rules)
+	if err != nil {
+		return rules, errors.Wrapf(err, "parsing rules file %s", path)
+	}
+	for i, r := range rules.Rules {
+		if r.Name == "" {
+			return rules, fmt.Errorf("rule %d in %s has no name", i+1, path)
+		}
+		if len(r.Combination) < 2 {
+			return rules, fmt.Errorf("rule %s must have at least two entries in its combination", r.Name)
+		}
+		for _, m := range r.Combination {
+			if m.Account == "" && m.PermissionSet == "" {
+				return rules, fmt.Errorf("rule %s has a combination entry with no account or permission set", r.Name)
+			}
+		}
+	}
+	return rules, nil
+}
+
+// Part is one part of a combination held by a user, with every path which grants it.
+type Part struct {
+	Match Match                `json:"match"`
+	Paths []accessgraph.Access `json:"paths"`
+}
+
+// Finding is a user who breaks a rule.
+type Finding struct {
+	Rule        string `json:"rule"`
+	Description string `json:"description,omitempty"`
+	UserID      string `json:"userId"`
+	// Instance is the instance holding the user. It's empty for the default instance.
+	Instance string `json:"instance,omitempty"`
+	Parts    []Part `json:"parts"`
+}
+
+// Check evaluates the rules against the effective access of every user in the graph.
+func Check(g accessgraph.Graph, m accessgraph.Model, rules Rules, access []accessgraph.Access) []Finding {
+	// resolve names once, so that patterns can match IDs or names
+	names := map[string]string{}
+	name := func(key string) string {
+		n, ok := names[key]
+		if !ok {
+			if r, err := g.Vertex(key); err == nil {
+				n = r.Name
+			}
+			names[key] = n
+		}
+		return n
+	}
+
+	matches := func(pattern, id, name string) bool {
+		return pattern == "" || policy.MatchAction(pattern, id) || (name != "" && policy.MatchAction(pattern, name))
+	}
+
+	byUser := map[string][]accessgraph.Access{}
+	var users []string
+	for _, a := range access {
+		user := a.UserKey(m)
+		if _, ok := byUser[user]; !ok {
+			users = append(users, user)
+		}
+		byUser[user] = append(byUser[user], a)
+	}
+	sort.Strings(users)
+
+	var findings []Finding
+	for _, rule := range rules.Rules {
+		for _, u := range users {
+			_, instance, id := accessgraph.SplitKey(u)
+			f := Finding{Rule: rule.Name, Description: rule.Description, UserID: id, Instance: instance}
+			for _, match := range rule.Combination {
+				part := Part{Match: match}
+				for _, a := range byUser[u] {
+					if matches(match.Account, a.AccountID, name(a.AccountKey(m))) &&
+						matches(match.PermissionSet, a.PermissionSetID, name(a.PermissionSetKey(m))) {
+						part.Paths = append(part.Paths, a)
+					}
+				}
+				if len(part.Paths) == 0 {
+					break
+				}
+				f.Parts = append(f.Parts, part)
+			}
+			if len(f.Parts) == len(rule.Combination) && distinct(f.Parts) {
+				findings = append(findings, f)
+			}
+		}
+	}
+	return findings
+}
+
+// entitlement identifies the permission set and account granted by a path.
+func entitlement(a accessgraph.Access) string {
+	return a.AccountID + "+" + a.PermissionSetID
+}
+
+// distinct returns true if every part can be satisfied by a different entitlement, so that a single
+// entitlement matching overlapping patterns, such as *Admin* and AdministratorAccess, isn't reported
+// as a combination. It finds a matching of parts to entitlements using augmenting paths.
+func distinct(parts []Part) bool {
+	candidates := make([][]string, len(parts))
+	for i, p := range parts {
+		seen := map[string]bool{}
+		for _, a := range p.Paths {
+			e := entitlement(a)
+			if !seen[e] {
+				seen[e] = true
+				candidates[i] = append(candidates[i], e)
+			}
+		}
+	}
+
+	// assigned maps each entitlement to the part using it
+	assigned := map[string]int{}
+	var assign func(part int, visited map[string]bool) bool
+	assign = func(part int, visited map[string]bool) bool {
+		for _, e := range candidates[part] {
+			if visited[e] {
+				continue
+			}
+			visited[e] = true
+			other, taken := assigned[e]
+			if !taken || assign(other, visited) {
+				assigned[e] = part
+				return true
+			}
+		}
+		return false
+	}
+
+	for i := range parts {
+		if !assign(i, map[string]bool{}) {
+			return false
+		}
+	}
+	return true
+}
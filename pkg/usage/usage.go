@@ -0,0 +1,274 @@
+// Package usage records when account assignments were last used, based on CloudTrail activity.
+package usage
+
+import (
+	"strings"
+	"time"
+
+	"github.com/common-fate/access-inspector/pkg/cloudtrail"
+	"github.com/common-fate/access-inspector/pkg/report"
+	"github.com/jmoiron/sqlx"
+	"github.com/pkg/errors"
+)
+
+// Usage is how often a user has used a permission set in an account.
+type Usage struct {
+	UserID           string    `db:"user" json:"user"`
+	AccountID        string    `db:"account" json:"account"`
+	PermissionSetARN string    `db:"permission_set" json:"permissionSet"`
+	LastUsed         time.Time `db:"last_used" json:"lastUsed"`
+	// UseCount is the number of times the user signed in with the permission set.
+	UseCount int `db:"use_count" json:"useCount"`
+}
+
+// Key identifies the user, account and permission set.
+func (u Usage) Key() string {
+	return Key(u.UserID, u.AccountID, u.PermissionSetARN)
+}
+
+// Key combines a user ID, account ID and permission set ARN into a single string,
+// so that usage can be looked up in a map.
+func Key(userID, accountID, permissionSetARN string) string {
+	return userID + "+" + accountID + "+" + permissionSetARN
+}
+
+// Resolver maps the identifiers found in CloudTrail events to the IDs of resources in a report.
+// Users and permission sets are looked up in the instance holding the account of the event, as
+// the same user name or permission set name may be used in several instances.
+type Resolver struct {
+	// users are keyed by instance and lower cased user ID, user name and email
+	users map[string]string
+	// permissionSets are keyed by instance and permission set name
+	permissionSets map[string]string
+	// instances maps account IDs to the instance holding the account
+	instances map[string]string
+	// only is the instance of reports with a single instance, which is used for accounts which aren't in the report
+	only string
+}
+
+func instanceKey(instance, k string) string {
+	return instance + "+" + k
+}
+
+// NewResolver builds a resolver from the users, accounts and permission sets in a report.
+func NewResolver(db *sqlx.DB) (*Resolver, error) {
+	r := Resolver{
+		users:          map[string]string{},
+		permissionSets: map[string]string{},
+		instances:      map[string]string{},
+	}
+
+	users, err := report.Users(db)
+	if err != nil {
+		return nil, err
+	}
+	instances := map[string]bool{}
+	for _, u := range users {
+		instances[u.Instance] = true
+		for _, k := range []string{u.ID, u.Name, u.Email} {
+			if k != "" {
+				r.users[instanceKey(u.Instance, strings.ToLower(k))] = u.ID
+			}
+		}
+	}
+	if len(instances) == 1 {
+		for instance := range instances {
+			r.only = instance
+		}
+	}
+
+	accounts, err := report.NamedResources(db, "account")
+	if err != nil {
+		return nil, err
+	}
+	for _, a := range accounts {
+		r.instances[a.ID] = a.Instance
+	}
+
+	permissionSets, err := report.NamedResources(db, "permissionset")
+	if err != nil {
+		return nil, err
+	}
+	for _, ps := range permissionSets {
+		r.permissionSets[instanceKey(ps.Instance, ps.Name)] = ps.ID
+	}
+	return &r, nil
+}
+
+// Resolve returns the user ID and permission set ARN for an activity.
+// It returns false if the user or permission set isn't in the report.
+func (r *Resolver) Resolve(a cloudtrail.Activity) (userID string, permissionSetARN string, ok bool) {
+	instance, ok := r.instances[a.AccountID]
+	if !ok {
+		instance = r.only
+	}
+	userID, ok = r.users[instanceKey(instance, strings.ToLower(a.User))]
+	if !ok {
+		return "", "", false
+	}
+	permissionSetARN, ok = r.permissionSets[instanceKey(instance, a.PermissionSetName)]
+	if !ok {
+		return "", "", false
+	}
+	return userID, permissionSetARN, true
+}
+
+// Result is the usage computed from a set of CloudTrail events.
+type Result struct {
+	Usage []Usage
+	// Unmatched counts Identity Center events whose user or permission set couldn't be found in the report.
+	Unmatched int
+	// EventIDs are the IDs of the events counted in Usage, which Save records so that they aren't counted again.
+	EventIDs []string
+}
+
+// Compute attributes CloudTrail events to users, accounts and permission sets in the report.
+func Compute(r *Resolver, events []cloudtrail.Event) Result {
+	var res Result
+	byKey := map[string]*Usage{}
+	var keys []string
+	seen := map[string]bool{}
+
+	for _, e := range events {
+		// the same event may be in more than one of the files loaded
+		if e.EventID != "" && seen[e.EventID] {
+			continue
+		}
+		a, ok := e.Activity()
+		if !ok {
+			continue
+		}
+		userID, psARN, ok := r.Resolve(a)
+		if !ok {
+			res.Unmatched++
+			continue
+		}
+		if e.EventID != "" {
+			seen[e.EventID] = true
+			res.EventIDs = append(res.EventIDs, e.EventID)
+		}
+
+		key := Key(userID, a.AccountID, psARN)
+		u, ok := byKey[key]
+		if !ok {
+			u = &Usage{UserID: userID, AccountID: a.AccountID, PermissionSetARN: psARN}
+			byKey[key] = u
+			keys = append(keys, key)
+		}
+		if a.SignIn {
+			u.UseCount++
+		}
+		if a.Time.After(u.LastUsed) {
+			u.LastUsed = a.Time
+		}
+	}
+
+	for _, k := range keys {
+		res.Usage = append(res.Usage, *byKey[k])
+	}
+	return res
+}
+
+// Table is the name of the table which usage is stored in.
+const Table = "assignment_usage"
+
+// EventsTable is the name of the table holding the IDs of the CloudTrail events counted in the usage table.
+const EventsTable = "assignment_usage_events"
+
+// Unprocessed drops the events which were counted by an earlier Save, so that logs which
+// are loaded more than once are only counted once. Events without an ID are always kept.
+func Unprocessed(db *sqlx.DB, events []cloudtrail.Event) ([]cloudtrail.Event, error) {
+	exists, err := report.TableExists(db, EventsTable)
+	if err != nil || !exists {
+		return events, err
+	}
+
+	var ids []string
+	err = db.Select(&ids, `SELECT "event_id" FROM `+EventsTable)
+	if err != nil {
+		return nil, err
+	}
+	processed := map[string]bool{}
+	for _, id := range ids {
+		processed[id] = true
+	}
+
+	var unprocessed []cloudtrail.Event
+	for _, e := range events {
+		if e.EventID != "" && processed[e.EventID] {
+			continue
+		}
+		unprocessed = append(unprocessed, e)
+	}
+	return unprocessed, nil
+}
+
+// Save adds usage to the usage stored in the report, so that logs can be loaded in batches.
+// Usage already stored keeps the later last used time, and the use counts are added together.
+// The IDs of the events counted are saved too, so that Unprocessed can drop them from later batches.
+func Save(db *sqlx.DB, res Result) error {
+	tx, err := db.Beginx()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	_, err = tx.Exec(`CREATE TABLE IF NOT EXISTS ` + Table + ` ("user" TEXT, "account" TEXT, "permission_set" TEXT, "last_used" TIMESTAMP, "use_count" INTEGER, PRIMARY KEY ("user", "account", "permission_set"))`)
+	if err != nil {
+		return errors.Wrapf(err, "creating table %s", Table)
+	}
+
+	_, err = tx.Exec(`CREATE TABLE IF NOT EXISTS ` + EventsTable + ` ("event_id" TEXT PRIMARY KEY)`)
+	if err != nil {
+		return errors.Wrapf(err, "creating table %s", EventsTable)
+	}
+
+	for _, id := range res.EventIDs {
+		_, err = tx.Exec(`INSERT OR IGNORE INTO `+EventsTable+` ("event_id") VALUES ($1)`, id)
+		if err != nil {
+			return errors.Wrapf(err, "inserting event %s", id)
+		}
+	}
+
+	for _, u := range res.Usage {
+		var existing []Usage
+		err = tx.Select(&existing, `SELECT "user", "account", "permission_set", "last_used", "use_count" FROM `+Table+` WHERE "user" = $1 AND "account" = $2 AND "permission_set" = $3`, u.UserID, u.AccountID, u.PermissionSetARN)
+		if err != nil {
+			return errors.Wrapf(err, "querying usage %+v", u)
+		}
+		for _, e := range existing {
+			if e.LastUsed.After(u.LastUsed) {
+				u.LastUsed = e.LastUsed
+			}
+			u.UseCount += e.UseCount
+		}
+		u.LastUsed = u.LastUsed.UTC()
+
+		_, err = tx.NamedExec(`INSERT OR REPLACE INTO `+Table+` ("user", "account", "permission_set", "last_used", "use_count") VALUES (:user, :account, :permission_set, :last_used, :use_count)`, u)
+		if err != nil {
+			return errors.Wrapf(err, "inserting usage %+v", u)
+		}
+	}
+	return tx.Commit()
+}
+
+// Load returns the usage stored in the report, keyed by Usage.Key().
+// If the usage command hasn't been run against the report, the map is nil.
+func Load(db *sqlx.DB) (map[string]Usage, error) {
+	exists, err := report.TableExists(db, Table)
+	if err != nil || !exists {
+		return nil, err
+	}
+
+	var usage []Usage
+	err = db.Select(&usage, `SELECT "user", "account", "permission_set", "last_used", "use_count" FROM `+Table)
+	if err != nil {
+		return nil, err
+	}
+
+	m := map[string]Usage{}
+	for _, u := range usage {
+		m[u.Key()] = u
+	}
+	return m, nil
+}
@@ -0,0 +1,207 @@
+package usage
+
+import (
+	"sort"
+	"testing"
+	"time"
+
+	"github.com/common-fate/access-inspector/internal/reporttest"
+	"github.com/common-fate/access-inspector/pkg/cloudtrail"
+	"github.com/common-fate/provider-registry-sdk-go/pkg/msg"
+	"github.com/jmoiron/sqlx"
+)
+
+const (
+	adminARN    = "arn:aws:sso:::permissionSet/ssoins-1/ps-admin"
+	readOnlyARN = "arn:aws:sso:::permissionSet/ssoins-1/ps-ro"
+	iamAdminARN = "arn:aws:sso:::permissionSet/ssoins-1/ps-iam"
+)
+
+// testReport creates a report with the users and permission sets the fixture logs refer to.
+func testReport(t *testing.T) *sqlx.DB {
+	t.Helper()
+	return reporttest.New(t, reporttest.Default(
+		reporttest.User("u-alice", "Alice", "alice@example.com"),
+		reporttest.User("u-bob", "Bob", "bob@example.com"),
+		reporttest.User("u-carol", "Carol", "carol@example.com"),
+		reporttest.Account("111111111111", "prod", nil),
+		reporttest.Account("222222222222", "dev", nil),
+		reporttest.PermissionSet(adminARN, "AdministratorAccess"),
+		reporttest.PermissionSet(readOnlyARN, "ReadOnlyAccess"),
+		reporttest.PermissionSet(iamAdminARN, "IAMAdmin"),
+	))
+}
+
+func date(s string) time.Time {
+	t, err := time.Parse(time.RFC3339, s)
+	if err != nil {
+		panic(err)
+	}
+	return t
+}
+
+func sortUsage(usage []Usage) {
+	sort.Slice(usage, func(i, j int) bool { return usage[i].Key() < usage[j].Key() })
+}
+
+func assertUsage(t *testing.T, got []Usage, want []Usage) {
+	t.Helper()
+	sortUsage(got)
+	sortUsage(want)
+	if len(got) != len(want) {
+		t.Fatalf("got %d usage rows, want %d: %+v", len(got), len(want), got)
+	}
+	for i := range want {
+		g, w := got[i], want[i]
+		if g.Key() != w.Key() || !g.LastUsed.Equal(w.LastUsed) || g.UseCount != w.UseCount {
+			t.Errorf("usage %d: got %+v, want %+v", i, g, w)
+		}
+	}
+}
+
+func TestComputeFromFixtures(t *testing.T) {
+	db := testReport(t)
+
+	events, err := cloudtrail.Load("testdata/cloudtrail")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(events) != 9 {
+		t.Fatalf("loaded %d events from the plain and gzipped logs, want 9", len(events))
+	}
+
+	r, err := NewResolver(db)
+	if err != nil {
+		t.Fatal(err)
+	}
+	res := Compute(r, events)
+
+	assertUsage(t, res.Usage, []Usage{
+		// two portal sign-ins and an API call made with the role, which updates the last used time but isn't a sign-in
+		{UserID: "u-alice", AccountID: "111111111111", PermissionSetARN: adminARN, LastUsed: date("2023-03-03T09:00:00Z"), UseCount: 2},
+		{UserID: "u-bob", AccountID: "222222222222", PermissionSetARN: readOnlyARN, LastUsed: date("2023-02-15T08:30:00Z"), UseCount: 1},
+		{UserID: "u-carol", AccountID: "111111111111", PermissionSetARN: iamAdminARN, LastUsed: date("2023-03-02T12:00:00Z"), UseCount: 1},
+	})
+	if res.Unmatched != 1 {
+		t.Errorf("got %d unmatched events, want 1 for the user who isn't in the report", res.Unmatched)
+	}
+}
+
+func TestSaveMergesBatches(t *testing.T) {
+	db := testReport(t)
+
+	err := Save(db, Result{Usage: []Usage{
+		{UserID: "u-alice", AccountID: "111111111111", PermissionSetARN: adminARN, LastUsed: date("2023-03-03T09:00:00Z"), UseCount: 2},
+		{UserID: "u-bob", AccountID: "222222222222", PermissionSetARN: readOnlyARN, LastUsed: date("2023-02-15T08:30:00Z"), UseCount: 1},
+	}})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// a batch of older logs for alice and newer logs for bob
+	err = Save(db, Result{Usage: []Usage{
+		{UserID: "u-alice", AccountID: "111111111111", PermissionSetARN: adminARN, LastUsed: date("2023-01-10T09:00:00Z"), UseCount: 3},
+		{UserID: "u-bob", AccountID: "222222222222", PermissionSetARN: readOnlyARN, LastUsed: date("2023-04-01T08:00:00Z"), UseCount: 1},
+		{UserID: "u-carol", AccountID: "111111111111", PermissionSetARN: iamAdminARN, LastUsed: date("2023-03-02T12:00:00Z"), UseCount: 1},
+	}})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	stored, err := Load(db)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var got []Usage
+	for _, u := range stored {
+		got = append(got, u)
+	}
+	assertUsage(t, got, []Usage{
+		{UserID: "u-alice", AccountID: "111111111111", PermissionSetARN: adminARN, LastUsed: date("2023-03-03T09:00:00Z"), UseCount: 5},
+		{UserID: "u-bob", AccountID: "222222222222", PermissionSetARN: readOnlyARN, LastUsed: date("2023-04-01T08:00:00Z"), UseCount: 2},
+		{UserID: "u-carol", AccountID: "111111111111", PermissionSetARN: iamAdminARN, LastUsed: date("2023-03-02T12:00:00Z"), UseCount: 1},
+	})
+}
+
+func TestSaveSkipsProcessedEvents(t *testing.T) {
+	db := testReport(t)
+
+	events, err := cloudtrail.Load("testdata/cloudtrail")
+	if err != nil {
+		t.Fatal(err)
+	}
+	r, err := NewResolver(db)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// load the same logs twice, with a copy of an event in the second run
+	for _, batch := range [][]cloudtrail.Event{events, append(events, events[0])} {
+		unprocessed, err := Unprocessed(db, batch)
+		if err != nil {
+			t.Fatal(err)
+		}
+		err = Save(db, Compute(r, unprocessed))
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	stored, err := Load(db)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var got []Usage
+	for _, u := range stored {
+		got = append(got, u)
+	}
+	assertUsage(t, got, []Usage{
+		{UserID: "u-alice", AccountID: "111111111111", PermissionSetARN: adminARN, LastUsed: date("2023-03-03T09:00:00Z"), UseCount: 2},
+		{UserID: "u-bob", AccountID: "222222222222", PermissionSetARN: readOnlyARN, LastUsed: date("2023-02-15T08:30:00Z"), UseCount: 1},
+		{UserID: "u-carol", AccountID: "111111111111", PermissionSetARN: iamAdminARN, LastUsed: date("2023-03-02T12:00:00Z"), UseCount: 1},
+	})
+}
+
+func TestResolveWithinInstance(t *testing.T) {
+	// both instances have a user named alice and an AdministratorAccess permission set
+	db := reporttest.New(t,
+		reporttest.Instance{Name: "org-a", Resources: []msg.Resource{
+			reporttest.User("u-alice-a", "alice", "alice@a.example.com"),
+			reporttest.Account("111111111111", "prod-a", nil),
+			reporttest.PermissionSet("arn:aws:sso:::permissionSet/ssoins-a/ps-admin", "AdministratorAccess"),
+		}},
+		reporttest.Instance{Name: "org-b", Resources: []msg.Resource{
+			reporttest.User("u-alice-b", "alice", "alice@b.example.com"),
+			reporttest.Account("333333333333", "prod-b", nil),
+			reporttest.PermissionSet("arn:aws:sso:::permissionSet/ssoins-b/ps-admin", "AdministratorAccess"),
+		}},
+	)
+
+	r, err := NewResolver(db)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		account   string
+		wantUser  string
+		wantARN   string
+		wantFound bool
+	}{
+		{account: "111111111111", wantUser: "u-alice-a", wantARN: "arn:aws:sso:::permissionSet/ssoins-a/ps-admin", wantFound: true},
+		{account: "333333333333", wantUser: "u-alice-b", wantARN: "arn:aws:sso:::permissionSet/ssoins-b/ps-admin", wantFound: true},
+		// the instance of an account which isn't in the report is unknown
+		{account: "999999999999", wantFound: false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.account, func(t *testing.T) {
+			userID, arn, ok := r.Resolve(cloudtrail.Activity{User: "alice", AccountID: tt.account, PermissionSetName: "AdministratorAccess"})
+			if ok != tt.wantFound {
+				t.Fatalf("got found %v, want %v", ok, tt.wantFound)
+			}
+			if userID != tt.wantUser || arn != tt.wantARN {
+				t.Errorf("got %s %s, want %s %s", userID, arn, tt.wantUser, tt.wantARN)
+			}
+		})
+	}
+}